@@ -3,6 +3,11 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb/ddl"
 	"github.com/pingcap/tidb/session"
@@ -15,6 +20,12 @@ import (
 	"github.com/pingcap/br/pkg/utils"
 )
 
+const (
+	flagKeepLast   = "keep-last"
+	flagKeepWithin = "keep-within"
+	flagDryRun     = "dry-run"
+)
+
 func runBackupCommand(command *cobra.Command, cmdName string) error {
 	cfg := task.BackupConfig{Config: task.Config{LogProgress: HasLogFile()}}
 	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
@@ -68,6 +79,9 @@ func NewBackupCommand() *cobra.Command {
 		newDbBackupCommand(),
 		newTableBackupCommand(),
 		newRawBackupCommand(),
+		newCopyBackupCommand(),
+		newListBackupsCommand(),
+		newPruneBackupsCommand(),
 	)
 
 	task.DefineBackupFlags(command.PersistentFlags())
@@ -128,3 +142,148 @@ func newRawBackupCommand() *cobra.Command {
 	task.DefineRawBackupFlags(command)
 	return command
 }
+
+// newCopyBackupCommand return a backup copy subcommand.
+func newCopyBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "copy",
+		Short: "copy a backup to another storage, and verify the sha256 of the files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg := task.CopyConfig{Config: task.Config{LogProgress: HasLogFile()}}
+			if err := cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+			result, err := task.RunBackupCopy(GetDefaultContext(), &cfg)
+			if err != nil {
+				log.Error("failed to copy backup", zap.Error(err))
+				return err
+			}
+			cmd.Printf("Copied %d of %d files (%s), the others are already in the destination\n",
+				result.Copied, result.Files, utils.FormatBytes(result.CopiedBytes))
+			return nil
+		},
+	}
+	task.DefineCopyFlags(command.Flags())
+	return command
+}
+
+// newListBackupsCommand return a backup list subcommand.
+func newListBackupsCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "list the backups in the storage",
+		Long: "List the backups found by their backupmeta at any depth of --storage,\n" +
+			"with the TS ranges, sizes, modes, and the parents of incremental backups.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+			_, s, err := task.GetStorage(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			catalog, err := task.ListBackups(ctx, s)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON {
+				return catalog.PrintJSON(os.Stdout)
+			}
+			return catalog.PrintTable(os.Stdout)
+		},
+	}
+	command.Flags().StringP(flagOutput, "o", outputTable, "The output format, table or json")
+	return command
+}
+
+// newPruneBackupsCommand return a backup prune subcommand.
+func newPruneBackupsCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "prune",
+		Short: "delete the expired backups in the storage",
+		Long: "Delete the backups in --storage not retained by --keep-last or --keep-within.\n" +
+			"The backups needed by a retained incremental backup are always retained,\n" +
+			"and the raw kv backups are never deleted.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var policy task.RetentionPolicy
+			policy.KeepLast, err = cmd.Flags().GetInt(flagKeepLast)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			keepWithin, err := cmd.Flags().GetString(flagKeepWithin)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if keepWithin != "" {
+				if policy.KeepWithin, err = task.ParseRetentionDuration(keepWithin); err != nil {
+					cmd.SilenceUsage = false
+					return err
+				}
+			}
+			dryRun, err := cmd.Flags().GetBool(flagDryRun)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+
+			_, s, err := task.GetStorage(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			catalog, err := task.ListBackups(ctx, s)
+			if err != nil {
+				return err
+			}
+			plan, err := task.PlanPrune(catalog, policy, time.Now())
+			if err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+			if output == outputJSON {
+				err = plan.PrintJSON(os.Stdout)
+			} else {
+				err = plan.PrintTable(os.Stdout)
+			}
+			if err != nil || dryRun {
+				return err
+			}
+			if err = task.ExecutePrune(ctx, s, plan); err != nil {
+				log.Error("failed to prune backups", zap.Error(err))
+				return err
+			}
+			if output == outputTable {
+				cmd.Printf("Deleted %d backups.\n", len(plan.Deleted()))
+			}
+			return nil
+		},
+	}
+	command.Flags().StringP(flagOutput, "o", outputTable, "The output format, table or json")
+	command.Flags().Int(flagKeepLast, 0, "The number of the latest backups to retain")
+	command.Flags().String(flagKeepWithin, "", `The age of the backups to retain, eg, "7d" or "12h"`)
+	command.Flags().Bool(flagDryRun, false, "Only print the backups to delete")
+	return command
+}
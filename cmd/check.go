@@ -0,0 +1,118 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pingcap/br/pkg/gluetikv"
+	"github.com/pingcap/br/pkg/task"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// The exit codes of the check commands.
+const (
+	// ExitCheckFailed is the exit code if any check fails.
+	ExitCheckFailed = 1
+	// ExitCheckWarned is the exit code if no check fails but some checks warn.
+	ExitCheckWarned = 2
+)
+
+// exitError is an error with the exit code of BR.
+type exitError struct {
+	error
+	code int
+}
+
+// ExitCode returns the exit code of BR for the error returned by a command.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if e, ok := errors.Cause(err).(*exitError); ok {
+		return e.code
+	}
+	return 1
+}
+
+func printCheckReport(report *task.CheckReport) error {
+	if err := report.Print(os.Stdout); err != nil {
+		return err
+	}
+	switch {
+	case report.Failed():
+		return &exitError{error: errors.New("preflight check failed"), code: ExitCheckFailed}
+	case report.Warned():
+		return &exitError{error: errors.New("preflight check passed with warnings"), code: ExitCheckWarned}
+	}
+	return nil
+}
+
+func runCheckBackupCommand(command *cobra.Command) error {
+	cfg := task.BackupConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return err
+	}
+	return printCheckReport(task.RunCheckBackup(GetDefaultContext(), gluetikv.Glue{}, &cfg))
+}
+
+func runCheckRestoreCommand(command *cobra.Command) error {
+	cfg := task.RestoreConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return err
+	}
+	return printCheckReport(task.RunCheckRestore(GetDefaultContext(), gluetikv.Glue{}, &cfg))
+}
+
+// NewCheckCommand returns a check subcommand.
+func NewCheckCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:          "check",
+		Short:        "run the preflight checks of backup or restore without moving data",
+		SilenceUsage: true,
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			if err := Init(c); err != nil {
+				return err
+			}
+			utils.LogBRInfo()
+			task.LogArguments(c)
+			return nil
+		},
+	}
+	command.AddCommand(
+		newCheckBackupCommand(),
+		newCheckRestoreCommand(),
+	)
+	return command
+}
+
+func newCheckBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "backup",
+		Short: "check whether the backup can run",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runCheckBackupCommand(cmd)
+		},
+	}
+	task.DefineBackupFlags(command.Flags())
+	task.DefineFilterFlags(command)
+	return command
+}
+
+func newCheckRestoreCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "restore",
+		Short: "check whether the restore can run",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runCheckRestoreCommand(cmd)
+		},
+	}
+	task.DefineRestoreFlags(command.Flags())
+	task.DefineFilterFlags(command)
+	return command
+}
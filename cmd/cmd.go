@@ -14,10 +14,13 @@ import (
 
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb/util/logutil"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/gluemysql"
 	"github.com/pingcap/br/pkg/gluetidb"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/task"
@@ -28,10 +31,13 @@ var (
 	initOnce        = sync.Once{}
 	defaultContext  context.Context
 	hasLogFile      uint64
-	tidbGlue        = gluetidb.Glue{}
 	envLogToTermKey = "BR_LOG_TO_TERM"
 )
 
+// tidbGlue is the glue of the commands using TiDB, it's replaced in Init if
+// a TiDB server is specified.
+var tidbGlue glue.Glue = gluetidb.Glue{}
+
 const (
 	// FlagLogLevel is the name of log-level flag.
 	FlagLogLevel = "log-level"
@@ -43,6 +49,22 @@ const (
 	FlagStatusAddr = "status-addr"
 	// FlagSlowLogFile is the name of slow-log-file flag.
 	FlagSlowLogFile = "slow-log-file"
+	// FlagProgressFile is the name of progress-file flag.
+	FlagProgressFile = "progress-file"
+	// FlagLogDecodeKeys is the name of log-decode-keys flag.
+	FlagLogDecodeKeys = "log-decode-keys"
+	// FlagTiDBAddr is the name of tidb-addr flag.
+	FlagTiDBAddr = "tidb-addr"
+	// FlagTiDBUser is the name of tidb-user flag.
+	FlagTiDBUser = "tidb-user"
+	// FlagTiDBPassword is the name of tidb-password flag.
+	FlagTiDBPassword = "tidb-password"
+	// FlagTiDBCA is the name of tidb-ca flag.
+	FlagTiDBCA = "tidb-ca"
+	// FlagTiDBCert is the name of tidb-cert flag.
+	FlagTiDBCert = "tidb-cert"
+	// FlagTiDBKey is the name of tidb-key flag.
+	FlagTiDBKey = "tidb-key"
 
 	flagVersion      = "version"
 	flagVersionShort = "V"
@@ -64,8 +86,22 @@ func AddFlags(cmd *cobra.Command) {
 		"Set the log file path. If not set, logs will output to temp file")
 	cmd.PersistentFlags().String(FlagLogFormat, "text",
 		"Set the log format")
+	cmd.PersistentFlags().Bool(FlagLogDecodeKeys, false,
+		"Decode the keys in the error logs, e.g. the table ID and the row ID of a record key")
 	cmd.PersistentFlags().String(FlagStatusAddr, "",
 		"Set the HTTP listening address for the status report service. Set to empty string to disable")
+	cmd.PersistentFlags().String(FlagProgressFile, "",
+		"Set the file path to write the progress events as JSON lines. If not set, no events are written")
+	cmd.PersistentFlags().String(FlagTiDBAddr, "",
+		"Set the address of a TiDB server to execute the DDLs through the MySQL protocol. "+
+			"If not set, the DDLs are executed by a TiDB embedded in BR")
+	cmd.PersistentFlags().String(FlagTiDBUser, "root", "Set the user to connect to the TiDB server")
+	cmd.PersistentFlags().String(FlagTiDBPassword, "", "Set the password to connect to the TiDB server")
+	task.RedactFlag(cmd.PersistentFlags(), FlagTiDBPassword)
+	cmd.PersistentFlags().String(FlagTiDBCA, "",
+		"CA certificate path for TLS connection to the TiDB server. If not set, TLS is not used")
+	cmd.PersistentFlags().String(FlagTiDBCert, "", "Certificate path for TLS connection to the TiDB server")
+	cmd.PersistentFlags().String(FlagTiDBKey, "", "Private key path for TLS connection to the TiDB server")
 	task.DefineCommonFlags(cmd.PersistentFlags())
 
 	cmd.PersistentFlags().StringP(FlagSlowLogFile, "", "",
@@ -76,6 +112,11 @@ func AddFlags(cmd *cobra.Command) {
 // Init ...
 func Init(cmd *cobra.Command) (err error) {
 	initOnce.Do(func() {
+		// Apply the config file before reading any flags.
+		if err = task.LoadConfigFile(cmd.Flags()); err != nil {
+			return
+		}
+
 		// Initialize the logger.
 		conf := new(log.Config)
 		conf.Level, err = cmd.Flags().GetString(FlagLogLevel)
@@ -106,6 +147,12 @@ func Init(cmd *cobra.Command) (err error) {
 			return
 		}
 		log.ReplaceGlobals(lg, p)
+		decodeKeys, e := cmd.Flags().GetBool(FlagLogDecodeKeys)
+		if e != nil {
+			err = e
+			return
+		}
+		utils.SetLogDecodedKeys(decodeKeys)
 
 		slowLogFilename, e := cmd.Flags().GetString(FlagSlowLogFile)
 		if e != nil {
@@ -128,19 +175,42 @@ func Init(cmd *cobra.Command) (err error) {
 			return
 		}
 
-		// Initialize the pprof server.
+		progressFile, e := cmd.Flags().GetString(FlagProgressFile)
+		if e != nil {
+			err = e
+			return
+		}
+		if len(progressFile) != 0 {
+			f, e := os.OpenFile(progressFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if e != nil {
+				err = e
+				return
+			}
+			// The file is kept open until BR exits.
+			utils.AddProgressEventWriter(f)
+		}
+
+		if err = initGlue(cmd); err != nil {
+			return
+		}
+
+		// Initialize the status server, which serves pprof, metrics, status
+		// and progress events.
 		statusAddr, e := cmd.Flags().GetString(FlagStatusAddr)
 		if e != nil {
 			err = e
 			return
 		}
+		http.Handle("/progress", utils.ProgressEventHandler())
+		http.Handle("/status", utils.StatusHandler())
+		http.Handle("/metrics", promhttp.Handler())
 		go func() {
 			// Make sure pprof is registered.
 			_ = pprof.Handler
 			if len(statusAddr) != 0 {
-				log.Info("start pprof", zap.String("addr", statusAddr))
+				log.Info("start status server", zap.String("addr", statusAddr))
 				if e := http.ListenAndServe(statusAddr, nil); e != nil {
-					log.Warn("fail to start pprof", zap.String("addr", statusAddr), zap.Error(e))
+					log.Warn("fail to start status server", zap.String("addr", statusAddr), zap.Error(e))
 				}
 			}
 		}()
@@ -148,6 +218,46 @@ func Init(cmd *cobra.Command) (err error) {
 	return err
 }
 
+// initGlue uses a TiDB server to execute the DDLs if --tidb-addr is set.
+func initGlue(cmd *cobra.Command) error {
+	addr, err := cmd.Flags().GetString(FlagTiDBAddr)
+	if err != nil || len(addr) == 0 {
+		return err
+	}
+	user, err := cmd.Flags().GetString(FlagTiDBUser)
+	if err != nil {
+		return err
+	}
+	password, err := cmd.Flags().GetString(FlagTiDBPassword)
+	if err != nil {
+		return err
+	}
+	tlsCfg := task.TLSConfig{}
+	if tlsCfg.CA, err = cmd.Flags().GetString(FlagTiDBCA); err != nil {
+		return err
+	}
+	if tlsCfg.Cert, err = cmd.Flags().GetString(FlagTiDBCert); err != nil {
+		return err
+	}
+	if tlsCfg.Key, err = cmd.Flags().GetString(FlagTiDBKey); err != nil {
+		return err
+	}
+	glueCfg := gluemysql.Config{Addr: addr, User: user, Password: password}
+	if tlsCfg.IsEnabled() {
+		if glueCfg.TLS, err = tlsCfg.ToTLSConfig(); err != nil {
+			return err
+		}
+	}
+	log.Info("execute DDLs on TiDB server",
+		zap.String("addr", addr), zap.String("user", user), zap.Bool("tls", glueCfg.TLS != nil))
+	g, err := gluemysql.New(glueCfg)
+	if err != nil {
+		return err
+	}
+	tidbGlue = g
+	return nil
+}
+
 // HasLogFile returns whether we set a log file.
 func HasLogFile() bool {
 	return atomic.LoadUint64(&hasLogFile) != uint64(0)
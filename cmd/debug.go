@@ -0,0 +1,280 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pingcap/br/pkg/task"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagOutput = "output"
+	flagOther  = "other"
+	// flagKeyFormat is the same as the one of raw backup and restore.
+	flagKeyFormat = "format"
+	// flagDeleteOrphans is the flag of debug gc-check.
+	flagDeleteOrphans = "delete-orphans"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	// ExitDiffFound is the exit code if the backups compared by debug diff are
+	// different.
+	ExitDiffFound = 3
+	// ExitGCCheckFailed is the exit code if debug gc-check finds missing files
+	// or orphan files not deleted.
+	ExitGCCheckFailed = 5
+)
+
+// NewDebugCommand returns a debug subcommand.
+func NewDebugCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:          "debug <subcommand>",
+		Short:        "commands to inspect backup data",
+		SilenceUsage: true,
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			if err := Init(c); err != nil {
+				return err
+			}
+			utils.LogBRInfo()
+			task.LogArguments(c)
+			return nil
+		},
+	}
+	command.AddCommand(
+		newListBackupCommand(),
+		newDiffBackupCommand(),
+		newDecodeKeyCommand(),
+		newGCCheckCommand(),
+	)
+	return command
+}
+
+func newListBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "ls",
+		Short: "list the databases and tables in the backup",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+
+			_, _, backupMeta, err := task.ReadBackupMeta(ctx, utils.MetaFile, &cfg)
+			if err != nil {
+				return err
+			}
+			info, err := task.NewBackupInfo(backupMeta, cfg.TableFilter)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON {
+				return info.PrintJSON(os.Stdout)
+			}
+			return info.PrintTable(os.Stdout)
+		},
+	}
+	command.Flags().StringP(flagOutput, "o", outputTable, "The output format, table or json")
+	task.DefineFilterFlags(command)
+	return command
+}
+
+func newDiffBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "diff",
+		Short: "compare the tables and DDL jobs in the backup with the other backup",
+		Long: "Compare the tables and DDL jobs in the backup with the other backup.\n" +
+			"The exit code is 3 if the backups are different.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+			otherCfg := cfg
+			if otherCfg.Storage, err = cmd.Flags().GetString(flagOther); err != nil {
+				return errors.Trace(err)
+			}
+			if otherCfg.Storage == "" {
+				cmd.SilenceUsage = false
+				return errors.Errorf("the other backup is not specified by --%s", flagOther)
+			}
+
+			_, _, backupMeta, err := task.ReadBackupMeta(ctx, utils.MetaFile, &cfg)
+			if err != nil {
+				return err
+			}
+			_, _, otherMeta, err := task.ReadBackupMeta(ctx, utils.MetaFile, &otherCfg)
+			if err != nil {
+				return errors.Annotate(err, "read the other backup failed")
+			}
+			diff, err := task.DiffBackups(backupMeta, otherMeta, cfg.TableFilter)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON {
+				err = diff.PrintJSON(os.Stdout)
+			} else {
+				err = diff.PrintTable(os.Stdout)
+			}
+			if err != nil {
+				return err
+			}
+			if !diff.Equal() {
+				return &exitError{error: errors.New("the backups are different"), code: ExitDiffFound}
+			}
+			return nil
+		},
+	}
+	command.Flags().StringP(flagOutput, "o", outputTable, "The output format, table or json")
+	command.Flags().String(flagOther, "", "The url of the other backup to compare with")
+	task.RedactURLFlag(command.Flags(), flagOther)
+	task.DefineFilterFlags(command)
+	return command
+}
+
+func newDecodeKeyCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "decode-key <key>...",
+		Short: "decode the table, index and row keys",
+		Long: "Decode the table, index and row keys, which may be memcomparable encoded.\n" +
+			"The table and index IDs are resolved to names if the backup is specified by --storage.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			format, err := cmd.Flags().GetString(flagKeyFormat)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			keys := make([][]byte, 0, len(args))
+			for _, arg := range args {
+				key, err := utils.ParseKey(format, arg)
+				if err != nil {
+					cmd.SilenceUsage = false
+					return errors.Annotatef(err, "invalid key %s", arg)
+				}
+				keys = append(keys, key)
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+
+			var formatter *utils.KeyFormatter
+			if cfg.Storage != "" {
+				_, _, backupMeta, err := task.ReadBackupMeta(ctx, utils.MetaFile, &cfg)
+				if err != nil {
+					return err
+				}
+				if formatter, err = utils.NewKeyFormatter(backupMeta); err != nil {
+					return err
+				}
+			}
+			for _, key := range keys {
+				fmt.Println(formatter.Format(key))
+			}
+			return nil
+		},
+	}
+	command.Flags().String(flagKeyFormat, "hex", "The format of the keys, support raw|escaped|hex")
+	return command
+}
+
+func newGCCheckCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "gc-check",
+		Short: "find the orphan and missing backup files in the storage",
+		Long: "Find the backup files not referenced by any backupmeta, and the files referenced but missing.\n" +
+			"Every backupmeta under --storage is checked with the files in its directory.\n" +
+			"The exit code is 5 if there are missing files, or orphan files not deleted.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			deleteOrphans, err := cmd.Flags().GetBool(flagDeleteOrphans)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				cmd.SilenceUsage = false
+				return err
+			}
+
+			_, s, err := task.GetStorage(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			report, err := task.CheckBackupGC(ctx, s)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON {
+				err = report.PrintJSON(os.Stdout)
+			} else {
+				err = report.PrintTable(os.Stdout)
+			}
+			if err != nil {
+				return err
+			}
+			if deleteOrphans {
+				if err = task.DeleteOrphans(ctx, s, report); err != nil {
+					return err
+				}
+				if len(report.Orphans) > 0 {
+					fmt.Printf("Deleted %d orphan files.\n", len(report.Orphans))
+				}
+				report.Orphans = nil
+			}
+			if !report.Clean() {
+				return &exitError{error: errors.New("orphan or missing backup files are found"), code: ExitGCCheckFailed}
+			}
+			return nil
+		},
+	}
+	command.Flags().StringP(flagOutput, "o", outputTable, "The output format, table or json")
+	command.Flags().Bool(flagDeleteOrphans, false,
+		"Delete the orphan files, make sure that no backup is writing to the storage")
+	return command
+}
+
+func getOutputFormat(cmd *cobra.Command) (string, error) {
+	output, err := cmd.Flags().GetString(flagOutput)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if output != outputTable && output != outputJSON {
+		cmd.SilenceUsage = false
+		return "", errors.Errorf("unknown output format %s, it must be %s or %s", output, outputTable, outputJSON)
+	}
+	return output, nil
+}
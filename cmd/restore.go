@@ -56,6 +56,20 @@ func runRestoreTiflashReplicaCommand(command *cobra.Command, cmdName string) err
 	return nil
 }
 
+func runRestoreRecoverClusterCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.RestoreConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return err
+	}
+
+	if err := task.RunRestoreRecoverCluster(GetDefaultContext(), gluetikv.Glue{}, cmdName, &cfg); err != nil {
+		log.Error("failed to recover cluster", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 // NewRestoreCommand returns a restore subcommand.
 func NewRestoreCommand() *cobra.Command {
 	command := &cobra.Command{
@@ -82,6 +96,7 @@ func NewRestoreCommand() *cobra.Command {
 		newTableRestoreCommand(),
 		newRawRestoreCommand(),
 		newTiflashReplicaRestoreCommand(),
+		newRecoverClusterCommand(),
 	)
 	task.DefineRestoreFlags(command.PersistentFlags())
 
@@ -135,6 +150,18 @@ func newTiflashReplicaRestoreCommand() *cobra.Command {
 	return command
 }
 
+func newRecoverClusterCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use: "recover-cluster",
+		Short: "recover the cluster config changed by an interrupted restore, " +
+			"it must only be used after the last restore exited abnormally",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runRestoreRecoverClusterCommand(cmd, "Recover cluster")
+		},
+	}
+	return command
+}
+
 func newRawRestoreCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "raw",
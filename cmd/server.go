@@ -0,0 +1,74 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/ddl"
+	"github.com/pingcap/tidb/session"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/server"
+	"github.com/pingcap/br/pkg/task"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagServerAddr = "addr"
+)
+
+func runServerCommand(command *cobra.Command) error {
+	addr, err := command.Flags().GetString(flagServerAddr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	ctx, cancel := context.WithCancel(GetDefaultContext())
+	defer cancel()
+
+	s := server.NewServer(ctx, tidbGlue)
+	defer s.Close()
+	httpServer := &http.Server{Addr: addr, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Close()
+	}()
+
+	log.Info("start BR server", zap.String("addr", addr))
+	if err = httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Error("failed to run BR server", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// NewServerCommand returns a server subcommand.
+func NewServerCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:          "server",
+		Short:        "run BR as a server accepting backup and restore tasks through HTTP",
+		SilenceUsage: true,
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			if err := Init(c); err != nil {
+				return err
+			}
+			utils.LogBRInfo()
+			task.LogArguments(c)
+
+			// Do not run ddl worker in BR.
+			ddl.RunWorker = false
+			// Do not run stat worker in BR.
+			session.DisableStats4Test()
+			return nil
+		},
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runServerCommand(command)
+		},
+	}
+	command.Flags().String(flagServerAddr, "127.0.0.1:8290", "The HTTP listening address of the task API")
+	return command
+}
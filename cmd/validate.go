@@ -3,10 +3,7 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
@@ -26,6 +23,8 @@ import (
 	"github.com/pingcap/br/pkg/utils"
 )
 
+const flagReadable = "readable"
+
 // NewValidateCommand return a debug subcommand.
 func NewValidateCommand() *cobra.Command {
 	meta := &cobra.Command{
@@ -104,18 +103,8 @@ func newCheckSumCommand() *cobra.Command {
 						zap.Binary("endKey", file.GetEndKey()),
 					)
 
-					var data []byte
-					data, err = s.Read(ctx, file.Name)
-					if err != nil {
-						return errors.Trace(err)
-					}
-					s := sha256.Sum256(data)
-					if !bytes.Equal(s[:], file.Sha256) {
-						return errors.Errorf(`
-backup data checksum failed: %s may be changed
-calculated sha256 is %s,
-origin sha256 is %s`,
-							file.Name, hex.EncodeToString(s[:]), hex.EncodeToString(file.Sha256))
+					if err = restore.CheckFileSHA256(ctx, s, file); err != nil {
+						return err
 					}
 				}
 				log.Info("table info", zap.Stringer("table", tblInfo.Name),
@@ -247,7 +236,16 @@ func decodeBackupMetaCommand() *cobra.Command {
 				return err
 			}
 
-			backupMetaJSON, err := json.Marshal(backupMeta)
+			readable, err := cmd.Flags().GetBool(flagReadable)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var backupMetaJSON []byte
+			if readable {
+				backupMetaJSON, err = utils.MarshalBackupMetaReadable(backupMeta)
+			} else {
+				backupMetaJSON, err = json.Marshal(backupMeta)
+			}
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -273,6 +271,8 @@ func decodeBackupMetaCommand() *cobra.Command {
 	}
 
 	decodeBackupMetaCmd.Flags().String("field", "", "decode specified field")
+	decodeBackupMetaCmd.Flags().Bool(flagReadable, false,
+		"decode schemas and DDL jobs as json and keys as hex, which can be encoded by encode --readable")
 
 	return decodeBackupMetaCmd
 }
@@ -299,8 +299,16 @@ func encodeBackupMetaCommand() *cobra.Command {
 				return errors.Trace(err)
 			}
 
+			readable, err := cmd.Flags().GetBool(flagReadable)
+			if err != nil {
+				return errors.Trace(err)
+			}
 			backupMetaJSON := &backup.BackupMeta{}
-			err = json.Unmarshal(metaData, backupMetaJSON)
+			if readable {
+				backupMetaJSON, err = utils.UnmarshalBackupMetaReadable(metaData)
+			} else {
+				err = json.Unmarshal(metaData, backupMetaJSON)
+			}
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -321,5 +329,7 @@ func encodeBackupMetaCommand() *cobra.Command {
 			return nil
 		},
 	}
+	encodeBackupMetaCmd.Flags().Bool(flagReadable, false,
+		"encode the backupmeta json file decoded by decode --readable")
 	return encodeBackupMetaCmd
 }
@@ -49,9 +49,12 @@ func main() {
 		cmd.NewValidateCommand(),
 		cmd.NewBackupCommand(),
 		cmd.NewRestoreCommand(),
+		cmd.NewServerCommand(),
+		cmd.NewCheckCommand(),
+		cmd.NewDebugCommand(),
 	)
 	rootCmd.SetArgs(os.Args[1:])
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -169,6 +169,29 @@ func (bc *Client) SaveBackupMeta(ctx context.Context, ddlJobs []*model.Job) erro
 	return bc.storage.Write(ctx, utils.MetaFile, backupMetaData)
 }
 
+// CollectTableSummary collects the size and checksum of the backed up tables
+// for the task report.
+func (bc *Client) CollectTableSummary() error {
+	for _, schema := range bc.backupMeta.Schemas {
+		dbInfo := &model.DBInfo{}
+		if err := json.Unmarshal(schema.Db, dbInfo); err != nil {
+			return errors.Trace(err)
+		}
+		tblInfo := &model.TableInfo{}
+		if err := json.Unmarshal(schema.Table, tblInfo); err != nil {
+			return errors.Trace(err)
+		}
+		summary.CollectTable(summary.TableReport{
+			DB:         dbInfo.Name.O,
+			Table:      tblInfo.Name.O,
+			Crc64Xor:   schema.Crc64Xor,
+			TotalKVs:   schema.TotalKvs,
+			TotalBytes: schema.TotalBytes,
+		})
+	}
+	return nil
+}
+
 // BuildTableRanges returns the key ranges encompassing the entire table,
 // and its partitions if exists.
 func BuildTableRanges(tbl *model.TableInfo) ([]kv.KeyRange, error) {
@@ -207,13 +230,14 @@ func appendRanges(tbl *model.TableInfo, tblID int64) ([]kv.KeyRange, error) {
 }
 
 // BuildBackupRangeAndSchema gets the range and schema of tables.
+// The schema is loaded from the storage directly if the domain is nil.
 func BuildBackupRangeAndSchema(
 	dom *domain.Domain,
 	storage kv.Storage,
 	tableFilter filter.Filter,
 	backupTS uint64,
 ) ([]rtree.Range, *Schemas, error) {
-	info, err := dom.GetSnapshotInfoSchema(backupTS)
+	info, err := utils.GetSnapshotInfoSchema(dom, storage, backupTS)
 	if err != nil {
 		return nil, nil, errors.Trace(err)
 	}
@@ -316,12 +340,12 @@ func BuildBackupRangeAndSchema(
 }
 
 // GetBackupDDLJobs returns the ddl jobs are done in (lastBackupTS, backupTS].
-func GetBackupDDLJobs(dom *domain.Domain, lastBackupTS, backupTS uint64) ([]*model.Job, error) {
-	snapMeta, err := dom.GetSnapshotMeta(backupTS)
+func GetBackupDDLJobs(storage kv.Storage, lastBackupTS, backupTS uint64) ([]*model.Job, error) {
+	snapMeta, err := utils.GetSnapshotMeta(storage, backupTS)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	lastSnapMeta, err := dom.GetSnapshotMeta(lastBackupTS)
+	lastSnapMeta, err := utils.GetSnapshotMeta(storage, lastBackupTS)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -624,7 +648,8 @@ func (bc *Client) fineGrainedBackup(
 				rangeTree.Put(resp.StartKey, resp.EndKey, resp.Files)
 
 				// Update progress
-				updateCh.Inc()
+				kvs, bytes := filesWeight(resp.Files)
+				glue.IncWithWeight(updateCh, kvs, bytes)
 			}
 		}
 
@@ -737,6 +762,10 @@ func (bc *Client) handleFineGrained(
 		log.Error("fail to connect store", zap.Uint64("StoreID", storeID))
 		return 0, errors.Trace(err)
 	}
+	startTime := time.Now()
+	defer func() {
+		backupRegionHistogram.Observe(time.Since(startTime).Seconds())
+	}()
 	err = SendBackup(
 		ctx, storeID, client, req,
 		// Handle responses with the same backoffer.
@@ -850,6 +879,21 @@ func (bc *Client) ArchiveSize() uint64 {
 	return utils.ArchiveSize(&bc.backupMeta)
 }
 
+// KVSize returns the total size of the kv pairs in the backed up files.
+func (bc *Client) KVSize() uint64 {
+	_, bytes := filesWeight(bc.backupMeta.Files)
+	return bytes
+}
+
+// filesWeight returns the total kvs and bytes of the files.
+func filesWeight(files []*kvproto.File) (kvs, bytes uint64) {
+	for _, file := range files {
+		kvs += file.GetTotalKvs()
+		bytes += file.GetTotalBytes()
+	}
+	return
+}
+
 // CollectFileInfo collects ungrouped file summary information, like kv count and size.
 func (bc *Client) CollectFileInfo() {
 	for _, file := range bc.backupMeta.Files {
@@ -86,28 +86,34 @@ func (push *pushDown) pushBackup(
 				return res, nil
 			}
 			if resp.GetError() == nil {
+				backupRegionCounters.WithLabelValues("success").Inc()
 				// None error means range has been backuped successfully.
 				res.Put(
 					resp.GetStartKey(), resp.GetEndKey(), resp.GetFiles())
 
 				// Update progress
-				updateCh.Inc()
+				kvs, bytes := filesWeight(resp.GetFiles())
+				glue.IncWithWeight(updateCh, kvs, bytes)
 			} else {
 				errPb := resp.GetError()
 				switch v := errPb.Detail.(type) {
 				case *backup.Error_KvError:
+					backupRegionCounters.WithLabelValues("kv_error").Inc()
 					log.Error("backup occur kv error", zap.Reflect("error", v))
 
 				case *backup.Error_RegionError:
+					backupRegionCounters.WithLabelValues("region_error").Inc()
 					log.Error("backup occur region error",
 						zap.Reflect("error", v))
 
 				case *backup.Error_ClusterIdError:
+					backupRegionCounters.WithLabelValues("cluster_id_error").Inc()
 					log.Error("backup occur cluster ID error",
 						zap.Reflect("error", v))
 					return res, errors.Errorf("%v", errPb)
 
 				default:
+					backupRegionCounters.WithLabelValues("unknown_error").Inc()
 					log.Error("backup occur unknown error",
 						zap.String("error", errPb.GetMsg()))
 					return res, errors.Errorf("%v", errPb)
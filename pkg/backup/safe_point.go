@@ -17,9 +17,9 @@ const (
 	DefaultBRGCSafePointTTL = 5 * 60
 )
 
-// getGCSafePoint returns the current gc safe point.
+// GetGCSafePoint returns the current gc safe point.
 // TODO: Some cluster may not enable distributed GC.
-func getGCSafePoint(ctx context.Context, pdClient pd.Client) (uint64, error) {
+func GetGCSafePoint(ctx context.Context, pdClient pd.Client) (uint64, error) {
 	safePoint, err := pdClient.UpdateGCSafePoint(ctx, 0)
 	if err != nil {
 		return 0, err
@@ -31,7 +31,7 @@ func getGCSafePoint(ctx context.Context, pdClient pd.Client) (uint64, error) {
 // Note: It ignores errors other than exceed GC safepoint.
 func CheckGCSafePoint(ctx context.Context, pdClient pd.Client, ts uint64) error {
 	// TODO: use PDClient.GetGCSafePoint instead once PD client exports it.
-	safePoint, err := getGCSafePoint(ctx, pdClient)
+	safePoint, err := GetGCSafePoint(ctx, pdClient)
 	if err != nil {
 		log.Warn("fail to get GC safe point", zap.Error(err))
 		return nil
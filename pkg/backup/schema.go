@@ -83,7 +83,7 @@ func (pending *Schemas) Start(
 					return
 				}
 				checksumResp, err := calculateChecksum(
-					ctx, &table, store.GetClient(), backupTS)
+					ctx, &table, store.GetClient(), backupTS, updateCh)
 				if err != nil {
 					pending.errCh <- err
 					return
@@ -136,13 +136,14 @@ func calculateChecksum(
 	table *model.TableInfo,
 	client kv.Client,
 	backupTS uint64,
+	updateCh glue.Progress,
 ) (*tipb.ChecksumResponse, error) {
 	exe, err := checksum.NewExecutorBuilder(table, backupTS).Build()
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	checksumResp, err := exe.Execute(ctx, client, func() {
-		// TODO: update progress here.
+	checksumResp, err := exe.Execute(ctx, client, func(resp *tipb.ChecksumResponse) {
+		glue.AddWeight(updateCh, resp.TotalKvs, resp.TotalBytes)
 	})
 	if err != nil {
 		return nil, errors.Trace(err)
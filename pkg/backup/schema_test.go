@@ -122,4 +122,14 @@ func (s *testBackupSchemaSuite) TestBuildBackupRangeAndSchema(c *C) {
 	c.Assert(schemas[1].Crc64Xor, Not(Equals), 0, Commentf("%v", schemas[1]))
 	c.Assert(schemas[1].TotalKvs, Not(Equals), 0, Commentf("%v", schemas[1]))
 	c.Assert(schemas[1].TotalBytes, Not(Equals), 0, Commentf("%v", schemas[1]))
+
+	// The schemas are loaded from the meta in the storage without domain.
+	ranges, backupSchemas, err := backup.BuildBackupRangeAndSchema(
+		nil, s.mock.Storage, noFilter, math.MaxUint64)
+	c.Assert(err, IsNil)
+	c.Assert(backupSchemas.Len(), Equals, 2)
+	expectedRanges, _, err := backup.BuildBackupRangeAndSchema(
+		s.mock.Domain, s.mock.Storage, noFilter, math.MaxUint64)
+	c.Assert(err, IsNil)
+	c.Assert(ranges, DeepEquals, expectedRanges)
 }
@@ -265,11 +265,12 @@ func (exec *Executor) RawRequests() ([]*tipb.ChecksumRequest, error) {
 	return res, nil
 }
 
-// Execute executes a checksum executor.
+// Execute executes a checksum executor. updateFn is called with the response
+// of every request once it's done.
 func (exec *Executor) Execute(
 	ctx context.Context,
 	client kv.Client,
-	updateFn func(),
+	updateFn func(resp *tipb.ChecksumResponse),
 ) (*tipb.ChecksumResponse, error) {
 	checksumResp := &tipb.ChecksumResponse{}
 	for _, req := range exec.reqs {
@@ -278,7 +279,7 @@ func (exec *Executor) Execute(
 			return nil, err
 		}
 		updateChecksumResponse(checksumResp, resp)
-		updateFn()
+		updateFn(resp)
 	}
 	return checksumResp, nil
 }
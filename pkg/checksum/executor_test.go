@@ -11,6 +11,7 @@ import (
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/util/testkit"
 	"github.com/pingcap/tidb/util/testleak"
+	"github.com/pingcap/tipb/go-tipb"
 
 	"github.com/pingcap/br/pkg/checksum"
 	"github.com/pingcap/br/pkg/mock"
@@ -61,7 +62,7 @@ func (s *testChecksumSuite) TestChecksum(c *C) {
 	exe1, err := checksum.NewExecutorBuilder(tableInfo1, math.MaxUint64).Build()
 	c.Assert(err, IsNil)
 	c.Assert(exe1.Len(), Equals, 1)
-	resp, err := exe1.Execute(context.TODO(), s.mock.Storage.GetClient(), func() {})
+	resp, err := exe1.Execute(context.TODO(), s.mock.Storage.GetClient(), func(*tipb.ChecksumResponse) {})
 	c.Assert(err, IsNil)
 	// Cluster returns a dummy checksum (all fields are 1).
 	c.Assert(resp.Checksum, Equals, uint64(1), Commentf("%v", resp))
@@ -76,7 +77,7 @@ func (s *testChecksumSuite) TestChecksum(c *C) {
 	exe2, err := checksum.NewExecutorBuilder(tableInfo2, math.MaxUint64).Build()
 	c.Assert(err, IsNil)
 	c.Assert(exe2.Len(), Equals, 2, Commentf("%v", tableInfo2))
-	resp2, err := exe2.Execute(context.TODO(), s.mock.Storage.GetClient(), func() {})
+	resp2, err := exe2.Execute(context.TODO(), s.mock.Storage.GetClient(), func(*tipb.ChecksumResponse) {})
 	c.Assert(err, IsNil)
 	c.Assert(resp2.Checksum, Equals, uint64(0), Commentf("%v", resp2))
 	c.Assert(resp2.TotalKvs, Equals, uint64(2), Commentf("%v", resp2))
@@ -96,7 +97,7 @@ func (s *testChecksumSuite) TestChecksum(c *C) {
 	for _, rawReq := range rawReqs {
 		c.Assert(rawReq.Rule, NotNil)
 	}
-	resp2, err = exe2.Execute(context.TODO(), s.mock.Storage.GetClient(), func() {})
+	resp2, err = exe2.Execute(context.TODO(), s.mock.Storage.GetClient(), func(*tipb.ChecksumResponse) {})
 	c.Assert(err, IsNil)
 	c.Assert(resp2, NotNil)
 }
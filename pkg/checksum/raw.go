@@ -0,0 +1,131 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"hash/crc64"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/tidb/store/tikv"
+	"github.com/pingcap/tidb/store/tikv/tikvrpc"
+)
+
+const (
+	rawScanBatchSize  = 1024
+	rawScanMaxBackoff = 20000
+	rawScanTimeout    = time.Minute
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// RawChecksum is the checksum of the key-value pairs in a raw kv range,
+// it's calculated in the same way as the checksum of tables.
+type RawChecksum struct {
+	Crc64Xor   uint64 `json:"crc64xor"`
+	TotalKvs   uint64 `json:"total-kvs"`
+	TotalBytes uint64 `json:"total-bytes"`
+}
+
+// Update adds a key-value pair to the checksum.
+func (c *RawChecksum) Update(key, value []byte) {
+	digest := crc64.Update(0, crc64Table, key)
+	digest = crc64.Update(digest, crc64Table, value)
+	c.Crc64Xor ^= digest
+	c.TotalKvs++
+	c.TotalBytes += uint64(len(key) + len(value))
+}
+
+// Merge adds the key-value pairs of another checksum to the checksum.
+func (c *RawChecksum) Merge(other RawChecksum) {
+	c.Crc64Xor ^= other.Crc64Xor
+	c.TotalKvs += other.TotalKvs
+	c.TotalBytes += other.TotalBytes
+}
+
+// RawKeyRewriter rewrites a scanned key before it's added to the checksum,
+// so that the keys restored with a new prefix can be compared with the keys
+// backed up.
+type RawKeyRewriter func(key []byte) []byte
+
+// CalculateRawChecksum scans the raw kv range [startKey, endKey) of the cf and
+// calculates its checksum. An empty endKey means there is no upper bound.
+func CalculateRawChecksum(
+	ctx context.Context,
+	store tikv.Storage,
+	startKey, endKey []byte,
+	cf string,
+	rewrite RawKeyRewriter,
+) (RawChecksum, error) {
+	var checksum RawChecksum
+	key := startKey
+	for len(endKey) == 0 || bytes.Compare(key, endKey) < 0 {
+		pairs, nextKey, err := rawScan(ctx, store, key, endKey, cf)
+		if err != nil {
+			return RawChecksum{}, errors.Trace(err)
+		}
+		for _, pair := range pairs {
+			k := pair.Key
+			if rewrite != nil {
+				k = rewrite(k)
+			}
+			checksum.Update(k, pair.Value)
+		}
+		if len(nextKey) == 0 {
+			break
+		}
+		key = nextKey
+	}
+	return checksum, nil
+}
+
+// rawScan scans a batch of key-value pairs from the region containing the
+// start key, and returns the key to continue scanning. An empty next key
+// means the scan reaches the end of the key space.
+func rawScan(
+	ctx context.Context,
+	store tikv.Storage,
+	startKey, endKey []byte,
+	cf string,
+) ([]*kvrpcpb.KvPair, []byte, error) {
+	bo := tikv.NewBackoffer(ctx, rawScanMaxBackoff)
+	for {
+		loc, err := store.GetRegionCache().LocateKey(bo, startKey)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		req := tikvrpc.NewRequest(tikvrpc.CmdRawScan, &kvrpcpb.RawScanRequest{
+			StartKey: startKey,
+			EndKey:   endKey,
+			Limit:    rawScanBatchSize,
+			Cf:       cf,
+		})
+		resp, err := store.SendReq(bo, req, loc.Region, rawScanTimeout)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		regionErr, err := resp.GetRegionError()
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		if regionErr != nil {
+			if err = bo.Backoff(tikv.BoRegionMiss, errors.New(regionErr.String())); err != nil {
+				return nil, nil, errors.Trace(err)
+			}
+			continue
+		}
+		if resp.Resp == nil {
+			return nil, nil, errors.Trace(tikv.ErrBodyMissing)
+		}
+		pairs := resp.Resp.(*kvrpcpb.RawScanResponse).GetKvs()
+		if len(pairs) == rawScanBatchSize {
+			// There may be more pairs in the region, continue after the last key.
+			lastKey := pairs[len(pairs)-1].GetKey()
+			return pairs, append(append([]byte{}, lastKey...), 0), nil
+		}
+		return pairs, loc.EndKey, nil
+	}
+}
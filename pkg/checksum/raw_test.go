@@ -0,0 +1,73 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package checksum_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/store/mockstore/mocktikv"
+	"github.com/pingcap/tidb/store/tikv"
+
+	"github.com/pingcap/br/pkg/checksum"
+)
+
+var _ = Suite(&testRawChecksumSuite{})
+
+type testRawChecksumSuite struct{}
+
+func (s *testRawChecksumSuite) TestRawChecksumMerge(c *C) {
+	var whole, left, right checksum.RawChecksum
+	whole.Update([]byte("a"), []byte("1"))
+	whole.Update([]byte("b"), []byte("22"))
+	left.Update([]byte("a"), []byte("1"))
+	right.Update([]byte("b"), []byte("22"))
+	c.Assert(whole.TotalKvs, Equals, uint64(2))
+	c.Assert(whole.TotalBytes, Equals, uint64(5))
+	c.Assert(left, Not(Equals), right)
+
+	left.Merge(right)
+	c.Assert(left, Equals, whole)
+}
+
+func (s *testRawChecksumSuite) TestCalculateRawChecksum(c *C) {
+	client, cluster, pdClient, err := mocktikv.NewTiKVAndPDClient("")
+	c.Assert(err, IsNil)
+	// Split into several regions to check the scan across regions.
+	mocktikv.BootstrapWithMultiRegions(cluster, []byte("k2"), []byte("k5"))
+	kvStore, err := tikv.NewTestTiKVStore(client, pdClient, nil, nil, 0)
+	c.Assert(err, IsNil)
+	defer kvStore.Close()
+	store := kvStore.(tikv.Storage)
+
+	rawKV := client.MvccStore.(mocktikv.RawKV)
+	var expected, expectedRewritten checksum.RawChecksum
+	for i := 0; i < 3000; i++ {
+		key := []byte(fmt.Sprintf("k%04d", i))
+		value := []byte(fmt.Sprintf("v%d", i))
+		rawKV.RawPut(key, value)
+		if i >= 100 && i < 2500 {
+			expected.Update(key, value)
+			expectedRewritten.Update(append([]byte("old"), key...), value)
+		}
+	}
+
+	ctx := context.Background()
+	actual, err := checksum.CalculateRawChecksum(
+		ctx, store, []byte("k0100"), []byte("k2500"), "default", nil)
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, expected)
+	c.Assert(actual.TotalKvs, Equals, uint64(2400))
+
+	rewritten, err := checksum.CalculateRawChecksum(
+		ctx, store, []byte("k0100"), []byte("k2500"), "default", func(key []byte) []byte {
+			return append([]byte("old"), key...)
+		})
+	c.Assert(err, IsNil)
+	c.Assert(rewritten, Equals, expectedRewritten)
+
+	all, err := checksum.CalculateRawChecksum(ctx, store, []byte{}, []byte{}, "default", nil)
+	c.Assert(err, IsNil)
+	c.Assert(all.TotalKvs, Equals, uint64(3000))
+}
@@ -3,7 +3,6 @@
 package conn
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -22,6 +21,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	pd "github.com/pingcap/pd/v4/client"
+	"github.com/pingcap/pd/v4/pkg/typeutil"
 	"github.com/pingcap/tidb/domain"
 	"github.com/pingcap/tidb/store/tikv"
 	"github.com/pingcap/tidb/util/codec"
@@ -42,15 +42,14 @@ const (
 	schdulerPrefix       = "pd/api/v1/schedulers"
 	maxMsgSize           = int(128 * utils.MB) // pd.ScanRegion may return a large response
 	scheduleConfigPrefix = "pd/api/v1/config/schedule"
+	storesPrefix         = "pd/api/v1/stores"
+	replicateCfgPrefix   = "pd/api/v1/config/replicate"
 )
 
 // Mgr manages connections to a TiDB cluster.
 type Mgr struct {
 	pdClient pd.Client
-	pdHTTP   struct {
-		addrs []string
-		cli   *http.Client
-	}
+	pdHTTP   pdHTTPClient
 	tlsConf  *tls.Config
 	dom      *domain.Domain
 	storage  tikv.Storage
@@ -63,6 +62,27 @@ type Mgr struct {
 
 type pdHTTPRequest func(context.Context, string, string, *http.Client, string, io.Reader) ([]byte, error)
 
+// pdStatusError is the error of a PD request whose response isn't 200 OK.
+type pdStatusError struct {
+	code int
+	body []byte
+	url  string
+}
+
+func (e *pdStatusError) Error() string {
+	return fmt.Sprintf("[%d] %s %s", e.code, e.body, e.url)
+}
+
+// isRetryablePDError returns whether the request may succeed on another PD
+// member or later. The requests rejected by PD, e.g. 4xx responses, fail the
+// same way everywhere, so only the transport errors and 5xx are retryable.
+func isRetryablePDError(err error) bool {
+	if e, ok := errors.Cause(err).(*pdStatusError); ok {
+		return e.code >= http.StatusInternalServerError
+	}
+	return true
+}
+
 func pdRequest(
 	ctx context.Context,
 	addr string, prefix string,
@@ -83,7 +103,7 @@ func pdRequest(
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		res, _ := ioutil.ReadAll(resp.Body)
-		return nil, errors.Errorf("[%d] %s %s", resp.StatusCode, res, url)
+		return nil, errors.Trace(&pdStatusError{code: resp.StatusCode, body: res, url: url})
 	}
 
 	r, err := ioutil.ReadAll(resp.Body)
@@ -170,7 +190,7 @@ func NewMgr(
 
 	processedAddrs := make([]string, 0, len(addrs))
 	for _, addr := range addrs {
-		if addr != "" && !strings.HasPrefix("http", addr) {
+		if addr != "" && !strings.HasPrefix(addr, "http") {
 			if tlsConf != nil {
 				addr = "https://" + addr
 			} else {
@@ -178,14 +198,14 @@ func NewMgr(
 			}
 		}
 		processedAddrs = append(processedAddrs, addr)
-		_, failure = pdRequest(ctx, addr, clusterVersionPrefix, cli, http.MethodGet, nil)
-		if failure == nil {
-			break
-		}
 	}
+	pdHTTP := pdHTTPClient{cli: cli, addrs: processedAddrs}
+	_, failure = pdHTTP.do(ctx, pdRequest, clusterVersionPrefix, http.MethodGet, nil)
 	if failure != nil {
 		return nil, errors.Annotatef(failure, "pd address (%s) not available, please check network", pdAddrs)
 	}
+	// Find the leader and the other members.
+	pdHTTP.refresh(ctx)
 
 	maxCallMsgSize := []grpc.DialOption{
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
@@ -237,15 +257,15 @@ func NewMgr(
 		tlsConf:     tlsConf,
 		ownsStorage: g.OwnsStorage(),
 	}
-	mgr.pdHTTP.addrs = processedAddrs
 	mgr.pdHTTP.cli = cli
+	mgr.pdHTTP.addrs = pdHTTP.getAddrs()
 	mgr.grpcClis.clis = make(map[uint64]*grpc.ClientConn)
 	return mgr, nil
 }
 
 // SetPDHTTP set pd addrs and cli for test.
 func (mgr *Mgr) SetPDHTTP(addrs []string, cli *http.Client) {
-	mgr.pdHTTP.addrs = addrs
+	mgr.pdHTTP.setAddrs(addrs)
 	mgr.pdHTTP.cli = cli
 }
 
@@ -260,17 +280,11 @@ func (mgr *Mgr) GetClusterVersion(ctx context.Context) (string, error) {
 }
 
 func (mgr *Mgr) getClusterVersionWith(ctx context.Context, get pdHTTPRequest) (string, error) {
-	var err error
-	for _, addr := range mgr.pdHTTP.addrs {
-		v, e := get(ctx, addr, clusterVersionPrefix, mgr.pdHTTP.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		return string(v), nil
+	v, err := mgr.pdHTTP.do(ctx, get, clusterVersionPrefix, http.MethodGet, nil)
+	if err != nil {
+		return "", err
 	}
-
-	return "", err
+	return string(v), nil
 }
 
 // GetRegionCount returns the region count in the specified range.
@@ -287,24 +301,19 @@ func (mgr *Mgr) getRegionCountWith(
 	if len(endKey) != 0 { // Empty end key means the max.
 		end = url.QueryEscape(string(codec.EncodeBytes(nil, endKey)))
 	}
-	var err error
-	for _, addr := range mgr.pdHTTP.addrs {
-		query := fmt.Sprintf(
-			"%s?start_key=%s&end_key=%s",
-			regionCountPrefix, start, end)
-		v, e := get(ctx, addr, query, mgr.pdHTTP.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		regionsMap := make(map[string]interface{})
-		err = json.Unmarshal(v, &regionsMap)
-		if err != nil {
-			return 0, err
-		}
-		return int(regionsMap["count"].(float64)), nil
+	query := fmt.Sprintf(
+		"%s?start_key=%s&end_key=%s",
+		regionCountPrefix, start, end)
+	v, err := mgr.pdHTTP.do(ctx, get, query, http.MethodGet, nil)
+	if err != nil {
+		return 0, err
 	}
-	return 0, err
+	regionsMap := make(map[string]interface{})
+	err = json.Unmarshal(v, &regionsMap)
+	if err != nil {
+		return 0, err
+	}
+	return int(regionsMap["count"].(float64)), nil
 }
 
 func (mgr *Mgr) getGrpcConnLocked(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
@@ -392,15 +401,9 @@ func (mgr *Mgr) RemoveScheduler(ctx context.Context, scheduler string) error {
 	return mgr.removeSchedulerWith(ctx, scheduler, pdRequest)
 }
 
-func (mgr *Mgr) removeSchedulerWith(ctx context.Context, scheduler string, delete pdHTTPRequest) (err error) {
-	for _, addr := range mgr.pdHTTP.addrs {
-		prefix := fmt.Sprintf("%s/%s", schdulerPrefix, scheduler)
-		_, err = delete(ctx, addr, prefix, mgr.pdHTTP.cli, http.MethodDelete, nil)
-		if err != nil {
-			continue
-		}
-		return nil
-	}
+func (mgr *Mgr) removeSchedulerWith(ctx context.Context, scheduler string, delete pdHTTPRequest) error {
+	prefix := fmt.Sprintf("%s/%s", schdulerPrefix, scheduler)
+	_, err := mgr.pdHTTP.do(ctx, delete, prefix, http.MethodDelete, nil)
 	return err
 }
 
@@ -409,16 +412,40 @@ func (mgr *Mgr) AddScheduler(ctx context.Context, scheduler string) error {
 	return mgr.addSchedulerWith(ctx, scheduler, pdRequest)
 }
 
-func (mgr *Mgr) addSchedulerWith(ctx context.Context, scheduler string, post pdHTTPRequest) (err error) {
-	for _, addr := range mgr.pdHTTP.addrs {
-		body := bytes.NewBuffer([]byte(`{"name":"` + scheduler + `"}`))
-		_, err = post(ctx, addr, schdulerPrefix, mgr.pdHTTP.cli, http.MethodPost, body)
+func (mgr *Mgr) addSchedulerWith(ctx context.Context, scheduler string, post pdHTTPRequest) error {
+	body := []byte(`{"name":"` + scheduler + `"}`)
+	_, err := mgr.pdHTTP.do(ctx, post, schdulerPrefix, http.MethodPost, body)
+	return err
+}
+
+// PauseSchedulers pauses the pd schedulers for the given duration. PD resumes
+// the schedulers automatically after that, so the caller should pause them
+// again before the duration expires to keep them paused.
+func (mgr *Mgr) PauseSchedulers(ctx context.Context, schedulers []string, delay time.Duration) error {
+	return mgr.pauseSchedulersWith(ctx, schedulers, delay, pdRequest)
+}
+
+// ResumeSchedulers resumes the paused pd schedulers.
+func (mgr *Mgr) ResumeSchedulers(ctx context.Context, schedulers []string) error {
+	return mgr.pauseSchedulersWith(ctx, schedulers, 0, pdRequest)
+}
+
+func (mgr *Mgr) pauseSchedulersWith(
+	ctx context.Context, schedulers []string, delay time.Duration, post pdHTTPRequest,
+) error {
+	// PD resumes the scheduler when delay is 0.
+	body, err := json.Marshal(map[string]int64{"delay": int64(delay.Seconds())})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, scheduler := range schedulers {
+		prefix := fmt.Sprintf("%s/%s", schdulerPrefix, scheduler)
+		_, err = mgr.pdHTTP.do(ctx, post, prefix, http.MethodPost, body)
 		if err != nil {
-			continue
+			return errors.Annotatef(err, "failed to pause scheduler %s", scheduler)
 		}
-		return nil
 	}
-	return err
+	return nil
 }
 
 // ListSchedulers list all pd scheduler.
@@ -427,21 +454,16 @@ func (mgr *Mgr) ListSchedulers(ctx context.Context) ([]string, error) {
 }
 
 func (mgr *Mgr) listSchedulersWith(ctx context.Context, get pdHTTPRequest) ([]string, error) {
-	var err error
-	for _, addr := range mgr.pdHTTP.addrs {
-		v, e := get(ctx, addr, schdulerPrefix, mgr.pdHTTP.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		d := make([]string, 0)
-		err = json.Unmarshal(v, &d)
-		if err != nil {
-			return nil, err
-		}
-		return d, nil
+	v, err := mgr.pdHTTP.do(ctx, get, schdulerPrefix, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := make([]string, 0)
+	err = json.Unmarshal(v, &d)
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	return d, nil
 }
 
 // GetPDScheduleConfig returns PD schedule config value associated with the key.
@@ -449,40 +471,102 @@ func (mgr *Mgr) listSchedulersWith(ctx context.Context, get pdHTTPRequest) ([]st
 func (mgr *Mgr) GetPDScheduleConfig(
 	ctx context.Context,
 ) (map[string]interface{}, error) {
-	var err error
-	for _, addr := range mgr.pdHTTP.addrs {
-		v, e := pdRequest(
-			ctx, addr, scheduleConfigPrefix, mgr.pdHTTP.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		cfg := make(map[string]interface{})
-		err = json.Unmarshal(v, &cfg)
-		if err != nil {
-			return nil, err
-		}
-		return cfg, nil
+	v, err := mgr.pdHTTP.do(ctx, pdRequest, scheduleConfigPrefix, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg := make(map[string]interface{})
+	err = json.Unmarshal(v, &cfg)
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	return cfg, nil
 }
 
 // UpdatePDScheduleConfig updates PD schedule config value associated with the key.
 func (mgr *Mgr) UpdatePDScheduleConfig(
 	ctx context.Context, cfg map[string]interface{},
 ) error {
-	for _, addr := range mgr.pdHTTP.addrs {
-		reqData, err := json.Marshal(cfg)
-		if err != nil {
-			return err
+	reqData, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = mgr.pdHTTP.do(ctx, pdRequest, scheduleConfigPrefix, http.MethodPost, reqData)
+	return errors.Annotate(err, "update PD schedule config failed")
+}
+
+// StoreStatus is the status of a store reported by PD.
+type StoreStatus struct {
+	ID        uint64
+	Address   string
+	State     metapb.StoreState
+	TiFlash   bool
+	Capacity  uint64
+	Available uint64
+}
+
+// GetStoresStatus returns the status of all stores which are not tombstone.
+func (mgr *Mgr) GetStoresStatus(ctx context.Context) ([]StoreStatus, error) {
+	return mgr.getStoresStatusWith(ctx, pdRequest)
+}
+
+func (mgr *Mgr) getStoresStatusWith(ctx context.Context, get pdHTTPRequest) ([]StoreStatus, error) {
+	v, err := mgr.pdHTTP.do(ctx, get, storesPrefix, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stores struct {
+		Stores []struct {
+			Store  *metapb.Store `json:"store"`
+			Status struct {
+				Capacity  typeutil.ByteSize `json:"capacity"`
+				Available typeutil.ByteSize `json:"available"`
+			} `json:"status"`
+		} `json:"stores"`
+	}
+	if err = json.Unmarshal(v, &stores); err != nil {
+		return nil, errors.Trace(err)
+	}
+	status := make([]StoreStatus, 0, len(stores.Stores))
+	for _, s := range stores.Stores {
+		if s.Store == nil || s.Store.GetState() == metapb.StoreState_Tombstone {
+			continue
 		}
-		_, e := pdRequest(ctx, addr, scheduleConfigPrefix,
-			mgr.pdHTTP.cli, http.MethodPost, bytes.NewBuffer(reqData))
-		if e == nil {
-			return nil
+		var tiflash bool
+		for _, label := range s.Store.GetLabels() {
+			if label.Key == "engine" && label.Value == "tiflash" {
+				tiflash = true
+			}
 		}
+		status = append(status, StoreStatus{
+			ID:        s.Store.GetId(),
+			Address:   s.Store.GetAddress(),
+			State:     s.Store.GetState(),
+			TiFlash:   tiflash,
+			Capacity:  uint64(s.Status.Capacity),
+			Available: uint64(s.Status.Available),
+		})
+	}
+	return status, nil
+}
+
+// GetMaxReplicas returns the number of replicas of each region.
+func (mgr *Mgr) GetMaxReplicas(ctx context.Context) (uint64, error) {
+	return mgr.getMaxReplicasWith(ctx, pdRequest)
+}
+
+func (mgr *Mgr) getMaxReplicasWith(ctx context.Context, get pdHTTPRequest) (uint64, error) {
+	v, err := mgr.pdHTTP.do(ctx, get, replicateCfgPrefix, http.MethodGet, nil)
+	if err != nil {
+		return 0, err
+	}
+	var cfg struct {
+		MaxReplicas uint64 `json:"max-replicas"`
+	}
+	if err = json.Unmarshal(v, &cfg); err != nil {
+		return 0, errors.Trace(err)
 	}
-	return errors.New("update PD schedule config failed")
+	return cfg.MaxReplicas, nil
 }
 
 // Close closes all client in Mgr.
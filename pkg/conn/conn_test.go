@@ -8,9 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/errors"
@@ -99,6 +101,44 @@ func (s *testClientSuite) TestScheduler(c *C) {
 	c.Assert(schedulers[0], Equals, scheduler)
 }
 
+func (s *testClientSuite) TestPauseSchedulers(c *C) {
+	ctx := context.Background()
+	s.mgr.pdHTTP.addrs = []string{"", ""} // two endpoints
+
+	var requests []string
+	mock := func(_ context.Context, _ string, prefix string, _ *http.Client, method string, body io.Reader) ([]byte, error) {
+		data, err := ioutil.ReadAll(body)
+		c.Assert(err, IsNil)
+		requests = append(requests, fmt.Sprintf("%s %s %s", method, prefix, data))
+		if len(requests)%2 == 1 {
+			// The first endpoint is always unavailable.
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	}
+	schedulers := []string{"balance-leader-scheduler", "balance-region-scheduler"}
+	err := s.mgr.pauseSchedulersWith(ctx, schedulers, 5*time.Minute, mock)
+	c.Assert(err, IsNil)
+	c.Assert(requests, DeepEquals, []string{
+		`POST pd/api/v1/schedulers/balance-leader-scheduler {"delay":300}`,
+		`POST pd/api/v1/schedulers/balance-leader-scheduler {"delay":300}`,
+		`POST pd/api/v1/schedulers/balance-region-scheduler {"delay":300}`,
+		`POST pd/api/v1/schedulers/balance-region-scheduler {"delay":300}`,
+	})
+
+	// Resume schedulers by setting delay to 0.
+	requests = nil
+	err = s.mgr.pauseSchedulersWith(ctx, schedulers[:1], 0, mock)
+	c.Assert(err, IsNil)
+	c.Assert(requests[1], Equals, `POST pd/api/v1/schedulers/balance-leader-scheduler {"delay":0}`)
+
+	mock = func(context.Context, string, string, *http.Client, string, io.Reader) ([]byte, error) {
+		return nil, errors.New("failed")
+	}
+	err = s.mgr.pauseSchedulersWith(ctx, schedulers, time.Minute, mock)
+	c.Assert(err, ErrorMatches, "failed to pause scheduler balance-leader-scheduler: failed")
+}
+
 func (s *testClientSuite) TestRegionCount(c *C) {
 	s.regions.SetRegion(core.NewRegionInfo(&metapb.Region{
 		Id:          1,
@@ -249,3 +289,34 @@ func (s *testClientSuite) TestGetAllTiKVStores(c *C) {
 		c.Assert(foundStores, DeepEquals, testCase.expectedStores)
 	}
 }
+
+func (s *testClientSuite) TestGetStoresStatus(c *C) {
+	ctx := context.Background()
+	s.mgr.pdHTTP.addrs = []string{""}
+	mock := func(_ context.Context, _ string, prefix string, _ *http.Client, _ string, _ io.Reader) ([]byte, error) {
+		switch prefix {
+		case storesPrefix:
+			return []byte(`{"count":3,"stores":[
+				{"store":{"id":1,"address":"tikv-1:20160","state":0},
+				 "status":{"capacity":"100GiB","available":"40GiB"}},
+				{"store":{"id":2,"address":"tiflash-1:3930","state":0,"labels":[{"key":"engine","value":"tiflash"}]},
+				 "status":{"capacity":"1TiB","available":"1TiB"}},
+				{"store":{"id":3,"address":"tikv-2:20160","state":2},
+				 "status":{"capacity":"0B","available":"0B"}}]}`), nil
+		case replicateCfgPrefix:
+			return []byte(`{"max-replicas":5,"location-labels":""}`), nil
+		}
+		return nil, errors.New("unexpected request")
+	}
+
+	stores, err := s.mgr.getStoresStatusWith(ctx, mock)
+	c.Assert(err, IsNil)
+	c.Assert(stores, DeepEquals, []StoreStatus{
+		{ID: 1, Address: "tikv-1:20160", State: metapb.StoreState_Up, Capacity: 100 << 30, Available: 40 << 30},
+		{ID: 2, Address: "tiflash-1:3930", State: metapb.StoreState_Up, TiFlash: true, Capacity: 1 << 40, Available: 1 << 40},
+	})
+
+	replicas, err := s.mgr.getMaxReplicasWith(ctx, mock)
+	c.Assert(err, IsNil)
+	c.Assert(replicas, Equals, uint64(5))
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package conn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+const (
+	membersPrefix = "pd/api/v1/members"
+	healthPrefix  = "pd/api/v1/health"
+
+	pdRequestRetryTimes = 3
+)
+
+var pdRequestRetryInterval = 500 * time.Millisecond
+
+// pdHTTPClient sends requests to the HTTP API of PD. It discovers the members
+// of PD, sends the requests to the leader first, and fails over to the other
+// members when the leader is unavailable.
+type pdHTTPClient struct {
+	cli *http.Client
+
+	mu sync.Mutex
+	// addrs are the addresses of the PD members, the leader is the first one
+	// and the unhealthy members are the last ones.
+	addrs []string
+}
+
+type pdMember struct {
+	Name       string   `json:"name"`
+	MemberID   uint64   `json:"member_id"`
+	ClientURLs []string `json:"client_urls"`
+}
+
+type pdMembers struct {
+	Members []pdMember `json:"members"`
+	Leader  *pdMember  `json:"leader"`
+}
+
+type pdMemberHealth struct {
+	MemberID uint64 `json:"member_id"`
+	Health   bool   `json:"health"`
+}
+
+// httpClient returns the client for the requests sent by pdHTTPClient itself.
+func (c *pdHTTPClient) httpClient() *http.Client {
+	if c.cli == nil {
+		return http.DefaultClient
+	}
+	return c.cli
+}
+
+func (c *pdHTTPClient) getAddrs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.addrs...)
+}
+
+func (c *pdHTTPClient) setAddrs(addrs []string) {
+	c.mu.Lock()
+	c.addrs = addrs
+	c.mu.Unlock()
+}
+
+// moveToFront makes the address the first one to try.
+func (c *pdHTTPClient) moveToFront(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addrs := make([]string, 0, len(c.addrs))
+	addrs = append(addrs, addr)
+	for _, a := range c.addrs {
+		if a != addr {
+			addrs = append(addrs, a)
+		}
+	}
+	c.addrs = addrs
+}
+
+// do sends the request to the PD members one by one until it succeeds. If all
+// members fail, the members are refreshed and the request is retried, since
+// the leader may be transferred or the members may be changed. The request
+// isn't retried if it's rejected by PD, see isRetryablePDError.
+func (c *pdHTTPClient) do(
+	ctx context.Context, send pdHTTPRequest, prefix, method string, body []byte,
+) ([]byte, error) {
+	var err error
+	for i := 0; i < pdRequestRetryTimes; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, errors.Trace(ctx.Err())
+			case <-time.After(pdRequestRetryInterval):
+			}
+			c.refresh(ctx)
+		}
+		for j, addr := range c.getAddrs() {
+			var reader io.Reader
+			if body != nil {
+				reader = bytes.NewReader(body)
+			}
+			v, e := send(ctx, addr, prefix, c.cli, method, reader)
+			if e != nil && !isRetryablePDError(e) {
+				return nil, e
+			}
+			if e != nil {
+				log.Warn("pd request failed, try next member",
+					zap.String("addr", addr), zap.String("prefix", prefix), zap.Error(e))
+				err = e
+				continue
+			}
+			if j > 0 || i > 0 {
+				// The leader is unavailable. Prefer the available member,
+				// and find the new leader if it has been elected.
+				c.moveToFront(addr)
+				c.refresh(ctx)
+			}
+			return v, nil
+		}
+	}
+	return nil, err
+}
+
+// refresh discovers the members of PD from any available member. The addresses
+// are kept if no member is available.
+func (c *pdHTTPClient) refresh(ctx context.Context) {
+	for _, addr := range c.getAddrs() {
+		v, err := pdRequest(ctx, addr, membersPrefix, c.httpClient(), http.MethodGet, nil)
+		if err != nil {
+			continue
+		}
+		var members pdMembers
+		if err = json.Unmarshal(v, &members); err != nil {
+			log.Warn("failed to decode pd members", zap.String("addr", addr), zap.Error(err))
+			continue
+		}
+		// The health is unknown if the request fails, then all members are
+		// treated as healthy.
+		health := make(map[uint64]bool)
+		if v, err = pdRequest(ctx, addr, healthPrefix, c.httpClient(), http.MethodGet, nil); err == nil {
+			var healths []pdMemberHealth
+			if json.Unmarshal(v, &healths) == nil {
+				for _, h := range healths {
+					health[h.MemberID] = h.Health
+				}
+			}
+		}
+		addrs := sortMemberAddrs(members, health)
+		if len(addrs) == 0 {
+			continue
+		}
+		log.Debug("refresh pd members", zap.Strings("addrs", addrs))
+		c.setAddrs(addrs)
+		return
+	}
+	log.Warn("failed to refresh pd members, keep the addresses", zap.Strings("addrs", c.getAddrs()))
+}
+
+// sortMemberAddrs returns the client URLs of the members, the leader first and
+// the unhealthy members last.
+func sortMemberAddrs(members pdMembers, health map[uint64]bool) []string {
+	var leader, healthy, unhealthy []string
+	for _, m := range members.Members {
+		if len(m.ClientURLs) == 0 {
+			continue
+		}
+		isHealthy, ok := health[m.MemberID]
+		switch {
+		case members.Leader != nil && m.MemberID == members.Leader.MemberID:
+			leader = append(leader, m.ClientURLs[0])
+		case !ok || isHealthy:
+			healthy = append(healthy, m.ClientURLs[0])
+		default:
+			unhealthy = append(unhealthy, m.ClientURLs[0])
+		}
+	}
+	addrs := append(leader, healthy...)
+	return append(addrs, unhealthy...)
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package conn
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testPDHTTPSuite{})
+
+type testPDHTTPSuite struct {
+	retryInterval time.Duration
+}
+
+func (s *testPDHTTPSuite) SetUpSuite(c *C) {
+	s.retryInterval = pdRequestRetryInterval
+	pdRequestRetryInterval = time.Millisecond
+}
+
+func (s *testPDHTTPSuite) TearDownSuite(c *C) {
+	pdRequestRetryInterval = s.retryInterval
+}
+
+// fakePDCluster is a PD cluster whose members are httptest servers.
+type fakePDCluster struct {
+	mu       sync.Mutex
+	servers  []*httptest.Server
+	leader   int
+	down     map[int]bool
+	unhealth map[int]bool
+	// failures is the number of scheduler requests to fail.
+	failures int
+	// reject is true means the scheduler requests are rejected as bad requests.
+	reject   bool
+	requests []string
+}
+
+func newFakePDCluster(n int) *fakePDCluster {
+	cluster := &fakePDCluster{down: make(map[int]bool), unhealth: make(map[int]bool)}
+	for i := 0; i < n; i++ {
+		id := i
+		cluster.servers = append(cluster.servers, httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				cluster.handle(id, w, r)
+			})))
+	}
+	return cluster
+}
+
+func (f *fakePDCluster) close() {
+	for _, server := range f.servers {
+		server.Close()
+	}
+}
+
+func (f *fakePDCluster) addr(i int) string {
+	return f.servers[i].URL
+}
+
+func (f *fakePDCluster) handle(id int, w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down[id] {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.URL.Path == "/"+membersPrefix:
+		var members pdMembers
+		for i := range f.servers {
+			m := pdMember{MemberID: uint64(i + 1), ClientURLs: []string{f.addr(i)}}
+			members.Members = append(members.Members, m)
+			if i == f.leader {
+				leader := m
+				members.Leader = &leader
+			}
+		}
+		_ = json.NewEncoder(w).Encode(members)
+	case r.URL.Path == "/"+healthPrefix:
+		healths := make([]pdMemberHealth, 0, len(f.servers))
+		for i := range f.servers {
+			healths = append(healths, pdMemberHealth{MemberID: uint64(i + 1), Health: !f.unhealth[i]})
+		}
+		_ = json.NewEncoder(w).Encode(healths)
+	case strings.HasPrefix(r.URL.Path, "/"+schdulerPrefix):
+		if f.reject {
+			f.requests = append(f.requests, f.addr(id))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.failures > 0 {
+			f.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.requests = append(f.requests, f.addr(id))
+		_, _ = w.Write([]byte(`[]`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakePDCluster) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func (s *testPDHTTPSuite) TestLeaderFirst(c *C) {
+	cluster := newFakePDCluster(3)
+	defer cluster.close()
+	cluster.leader = 2
+	cluster.unhealth[0] = true
+
+	mgr := &Mgr{}
+	mgr.SetPDHTTP([]string{cluster.addr(0)}, &http.Client{})
+	ctx := context.Background()
+	mgr.pdHTTP.refresh(ctx)
+	c.Assert(mgr.pdHTTP.getAddrs(), DeepEquals,
+		[]string{cluster.addr(2), cluster.addr(1), cluster.addr(0)})
+
+	c.Assert(mgr.AddScheduler(ctx, "balance-leader-scheduler"), IsNil)
+	c.Assert(cluster.takeRequests(), DeepEquals, []string{cluster.addr(2)})
+}
+
+func (s *testPDHTTPSuite) TestFailover(c *C) {
+	cluster := newFakePDCluster(3)
+	defer cluster.close()
+
+	mgr := &Mgr{}
+	mgr.SetPDHTTP([]string{cluster.addr(0)}, &http.Client{})
+	ctx := context.Background()
+	mgr.pdHTTP.refresh(ctx)
+	c.Assert(mgr.pdHTTP.getAddrs()[0], Equals, cluster.addr(0))
+
+	// The leader is down, and member 1 becomes the new leader.
+	cluster.mu.Lock()
+	cluster.down[0] = true
+	cluster.leader = 1
+	cluster.mu.Unlock()
+	c.Assert(mgr.RemoveScheduler(ctx, "balance-leader-scheduler"), IsNil)
+	c.Assert(cluster.takeRequests(), DeepEquals, []string{cluster.addr(1)})
+	c.Assert(mgr.pdHTTP.getAddrs()[0], Equals, cluster.addr(1))
+
+	// The requests are sent to the new leader directly.
+	_, err := mgr.ListSchedulers(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(cluster.takeRequests(), DeepEquals, []string{cluster.addr(1)})
+}
+
+func (s *testPDHTTPSuite) TestRetry(c *C) {
+	cluster := newFakePDCluster(2)
+	defer cluster.close()
+
+	mgr := &Mgr{}
+	mgr.SetPDHTTP([]string{cluster.addr(0), cluster.addr(1)}, &http.Client{})
+	ctx := context.Background()
+
+	// All members fail in the first round.
+	cluster.failures = 2
+	c.Assert(mgr.PauseSchedulers(ctx, []string{"balance-leader-scheduler"}, time.Minute), IsNil)
+	c.Assert(cluster.takeRequests(), DeepEquals, []string{cluster.addr(0)})
+
+	// All members fail in all rounds.
+	cluster.failures = 2 * pdRequestRetryTimes
+	err := mgr.AddScheduler(ctx, "balance-leader-scheduler")
+	c.Assert(err, ErrorMatches, ".*500.*")
+	c.Assert(cluster.takeRequests(), HasLen, 0)
+
+	// All members are down.
+	cluster.mu.Lock()
+	cluster.down[0] = true
+	cluster.down[1] = true
+	cluster.mu.Unlock()
+	_, err = mgr.ListSchedulers(ctx)
+	c.Assert(err, ErrorMatches, ".*503.*")
+	c.Assert(mgr.pdHTTP.getAddrs(), DeepEquals, []string{cluster.addr(0), cluster.addr(1)})
+}
+
+func (s *testPDHTTPSuite) TestNoRetryOnRejected(c *C) {
+	cluster := newFakePDCluster(2)
+	defer cluster.close()
+
+	mgr := &Mgr{}
+	mgr.SetPDHTTP([]string{cluster.addr(0), cluster.addr(1)}, &http.Client{})
+	ctx := context.Background()
+
+	// The rejected request is sent only once.
+	cluster.reject = true
+	err := mgr.AddScheduler(ctx, "balance-leader-scheduler")
+	c.Assert(err, ErrorMatches, ".*400.*")
+	c.Assert(cluster.takeRequests(), DeepEquals, []string{cluster.addr(0)})
+}
@@ -44,3 +44,36 @@ type Progress interface {
 	// called.
 	Close()
 }
+
+// WeightedProgress is an optional interface of Progress, which tracks the
+// processed KVs and bytes besides the units, so the remaining time can be
+// estimated by bytes when the sizes of the units vary.
+type WeightedProgress interface {
+	Progress
+	// SetTotalBytes sets the total bytes to process.
+	SetTotalBytes(total uint64)
+	// AddWeight records the processed KVs and bytes without increasing the
+	// units. This method must be goroutine-safe.
+	AddWeight(kvs, bytes uint64)
+}
+
+// SetTotalBytes sets the total bytes of the progress if it's a WeightedProgress.
+func SetTotalBytes(p Progress, total uint64) {
+	if wp, ok := p.(WeightedProgress); ok {
+		wp.SetTotalBytes(total)
+	}
+}
+
+// AddWeight records the processed KVs and bytes if the progress is a
+// WeightedProgress.
+func AddWeight(p Progress, kvs, bytes uint64) {
+	if wp, ok := p.(WeightedProgress); ok {
+		wp.AddWeight(kvs, bytes)
+	}
+}
+
+// IncWithWeight increases the progress by a unit containing the KVs and bytes.
+func IncWithWeight(p Progress, kvs, bytes uint64) {
+	AddWeight(p, kvs, bytes)
+	p.Inc()
+}
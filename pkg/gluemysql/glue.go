@@ -0,0 +1,197 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package gluemysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	tmysql "github.com/pingcap/parser/mysql"
+	pd "github.com/pingcap/pd/v4/client"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/executor"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/meta/autoid"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/util/mock"
+
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/gluetikv"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// Config is the config to connect to a TiDB server.
+type Config struct {
+	// Addr is the address of the TiDB server, e.g. "127.0.0.1:4000".
+	Addr     string
+	User     string
+	Password string
+	// TLS is the TLS config to connect to the TiDB server, TLS is not used if
+	// it is nil.
+	TLS *tls.Config
+}
+
+// tlsConfigName is the name of the TLS config registered to the MySQL driver.
+const tlsConfigName = "br-tidb"
+
+// Glue is an implementation of glue.Glue which executes the DDLs on a TiDB
+// server through the MySQL protocol, instead of a TiDB embedded in BR.
+//
+// It has no domain, so the schemas are loaded from the meta in TiKV.
+type Glue struct {
+	tikvGlue gluetikv.Glue
+	dsn      string
+}
+
+type mysqlSession struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// New creates a glue connecting to the TiDB server.
+func New(cfg Config) (Glue, error) {
+	mysqlCfg := mysql.NewConfig()
+	mysqlCfg.User = cfg.User
+	mysqlCfg.Passwd = cfg.Password
+	mysqlCfg.Net = "tcp"
+	mysqlCfg.Addr = cfg.Addr
+	// The default values of timestamp columns are generated in UTC.
+	mysqlCfg.Params = map[string]string{"time_zone": "'+00:00'"}
+	if cfg.TLS != nil {
+		// The driver only accepts the TLS config by the name registered.
+		if err := mysql.RegisterTLSConfig(tlsConfigName, cfg.TLS); err != nil {
+			return Glue{}, errors.Trace(err)
+		}
+		mysqlCfg.TLSConfig = tlsConfigName
+	}
+	return Glue{dsn: mysqlCfg.FormatDSN()}, nil
+}
+
+// GetDomain implements glue.Glue.
+func (Glue) GetDomain(store kv.Storage) (*domain.Domain, error) {
+	return nil, nil
+}
+
+// CreateSession implements glue.Glue.
+func (g Glue) CreateSession(store kv.Storage) (glue.Session, error) {
+	db, err := sql.Open("mysql", g.dsn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Use a single connection, since the session variables, e.g. sql_mode
+	// and the current database, must be kept between the statements.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, errors.Trace(err)
+	}
+	return &mysqlSession{db: db, conn: conn}, nil
+}
+
+// Open implements glue.Glue.
+func (g Glue) Open(path string, option pd.SecurityOption) (kv.Storage, error) {
+	return g.tikvGlue.Open(path, option)
+}
+
+// OwnsStorage implements glue.Glue.
+func (Glue) OwnsStorage() bool {
+	return true
+}
+
+// StartProgress implements glue.Glue.
+func (g Glue) StartProgress(ctx context.Context, cmdName string, total int64, redirectLog bool) glue.Progress {
+	return g.tikvGlue.StartProgress(ctx, cmdName, total, redirectLog)
+}
+
+// Record implements glue.Glue.
+func (g Glue) Record(name string, value uint64) {
+	g.tikvGlue.Record(name, value)
+}
+
+// Execute implements glue.Session.
+func (gs *mysqlSession) Execute(ctx context.Context, sql string) error {
+	_, err := gs.conn.ExecContext(ctx, sql)
+	return errors.Trace(err)
+}
+
+// CreateDatabase implements glue.Session.
+func (gs *mysqlSession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
+	query, err := CreateDatabaseSQL(schema)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return gs.Execute(ctx, query)
+}
+
+// CreateTable implements glue.Session.
+func (gs *mysqlSession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
+	query, err := CreateTableSQL(table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = gs.Execute(ctx, fmt.Sprintf("use %s;", utils.EncloseName(dbName.O)))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = gs.Execute(ctx, query)
+	// Ignore the existing table, like the TiDB embedded in BR does.
+	if mysqlErr, ok := errors.Cause(err).(*mysql.MySQLError); ok && mysqlErr.Number == tmysql.ErrTableExists {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// Close implements glue.Session.
+func (gs *mysqlSession) Close() {
+	gs.conn.Close()
+	gs.db.Close()
+}
+
+// CreateDatabaseSQL returns the SQL to create the database if not exists.
+func CreateDatabaseSQL(schema *model.DBInfo) (string, error) {
+	schema = schema.Clone()
+	if len(schema.Charset) == 0 {
+		schema.Charset = tmysql.DefaultCharset
+	}
+	var buf bytes.Buffer
+	if err := executor.ConstructResultOfShowCreateDatabase(newSQLContext(), schema, true, &buf); err != nil {
+		return "", errors.Trace(err)
+	}
+	return buf.String(), nil
+}
+
+// CreateTableSQL returns the SQL to create the table, view or sequence, like
+// the result of `SHOW CREATE TABLE`.
+func CreateTableSQL(table *model.TableInfo) (string, error) {
+	var buf bytes.Buffer
+	alloc := autoIncIDAllocator{next: table.AutoIncID}
+	if err := executor.ConstructResultOfShowCreateTable(newSQLContext(), table, alloc, &buf); err != nil {
+		return "", errors.Trace(err)
+	}
+	return buf.String(), nil
+}
+
+func newSQLContext() sessionctx.Context {
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().TimeZone = time.UTC
+	return ctx
+}
+
+// autoIncIDAllocator returns the auto increment ID of the table in the backup
+// instead of the one in the storage.
+type autoIncIDAllocator struct {
+	autoid.Allocator
+	next int64
+}
+
+// NextGlobalAutoID implements autoid.Allocator.
+func (a autoIncIDAllocator) NextGlobalAutoID(tableID int64) (int64, error) {
+	return a.next, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package gluemysql
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/ddl"
+	"github.com/pingcap/tidb/util/mock"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testGlueSuite{})
+
+type testGlueSuite struct{}
+
+func buildTableInfo(c *C, sql string) *model.TableInfo {
+	stmt, err := parser.New().ParseOneStmt(sql, "", "")
+	c.Assert(err, IsNil)
+	info, err := ddl.MockTableInfo(mock.NewContext(), stmt.(*ast.CreateTableStmt), 1)
+	c.Assert(err, IsNil)
+	return info
+}
+
+func (s *testGlueSuite) TestCreateTableSQL(c *C) {
+	info := buildTableInfo(c,
+		"create table t (id int primary key auto_increment, name varchar(20) default 'x', key idx_name (name))")
+	info.AutoIncID = 42
+	sql, err := CreateTableSQL(info)
+	c.Assert(err, IsNil)
+	c.Assert(sql, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` int(11) NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(20) DEFAULT 'x',\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name` (`name`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin AUTO_INCREMENT=42")
+
+	// The created SQL can be parsed again.
+	_, err = parser.New().ParseOneStmt(sql, "", "")
+	c.Assert(err, IsNil)
+}
+
+func (s *testGlueSuite) TestCreateDatabaseSQL(c *C) {
+	sql, err := CreateDatabaseSQL(&model.DBInfo{Name: model.NewCIStr("db")})
+	c.Assert(err, IsNil)
+	c.Assert(sql, Equals, "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `db` /*!40100 DEFAULT CHARACTER SET utf8mb4 */")
+}
+
+func (s *testGlueSuite) TestNew(c *C) {
+	g, err := New(Config{Addr: "127.0.0.1:4000", User: "root", Password: "pass"})
+	c.Assert(err, IsNil)
+	c.Assert(g.dsn, Equals, "root:pass@tcp(127.0.0.1:4000)/?time_zone=%27%2B00%3A00%27")
+	dom, err := g.GetDomain(nil)
+	c.Assert(err, IsNil)
+	c.Assert(dom, IsNil)
+}
+
+func (s *testGlueSuite) TestNewWithTLS(c *C) {
+	g, err := New(Config{Addr: "127.0.0.1:4000", User: "root", TLS: &tls.Config{}})
+	c.Assert(err, IsNil)
+	c.Assert(g.dsn, Equals, "root@tcp(127.0.0.1:4000)/?tls=br-tidb&time_zone=%27%2B00%3A00%27")
+	cfg, err := mysql.ParseDSN(g.dsn)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TLSConfig, Equals, tlsConfigName)
+}
@@ -47,22 +47,40 @@ func (Glue) OwnsStorage() bool {
 
 // StartProgress implements glue.Glue.
 func (Glue) StartProgress(ctx context.Context, cmdName string, total int64, redirectLog bool) glue.Progress {
-	return progress{ch: utils.StartProgress(ctx, cmdName, total, redirectLog)}
+	return progress{
+		printer: utils.StartProgressPrinter(ctx, cmdName, total, redirectLog),
+		tracker: utils.StartProgressTracker(ctx, cmdName, total),
+	}
 }
 
 // Record implements glue.Glue.
 func (Glue) Record(string, uint64) {}
 
 type progress struct {
-	ch chan<- struct{}
+	printer *utils.ProgressPrinter
+	tracker *utils.ProgressTracker
 }
 
 // Inc implements glue.Progress.
 func (p progress) Inc() {
-	p.ch <- struct{}{}
+	p.tracker.Inc()
+	p.printer.UpdateCh() <- struct{}{}
+}
+
+// SetTotalBytes implements glue.WeightedProgress.
+func (p progress) SetTotalBytes(total uint64) {
+	p.printer.SetTotalBytes(total)
+	p.tracker.SetTotalBytes(total)
+}
+
+// AddWeight implements glue.WeightedProgress.
+func (p progress) AddWeight(kvs, bytes uint64) {
+	p.printer.AddBytes(bytes)
+	p.tracker.AddWeight(kvs, bytes)
 }
 
 // Close implements glue.Progress.
 func (p progress) Close() {
-	close(p.ch)
+	p.tracker.Close()
+	close(p.printer.UpdateCh())
 }
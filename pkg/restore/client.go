@@ -24,10 +24,12 @@ import (
 	pd "github.com/pingcap/pd/v4/client"
 	"github.com/pingcap/pd/v4/server/schedule/placement"
 	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/infoschema"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/store/tikv/oracle"
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/util/codec"
+	"github.com/pingcap/tipb/go-tipb"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
@@ -52,6 +54,7 @@ type Client struct {
 	cancel context.CancelFunc
 
 	pdClient     pd.Client
+	store        kv.Storage
 	toolClient   SplitClient
 	fileImporter FileImporter
 	workerPool   *utils.WorkerPool
@@ -93,6 +96,7 @@ func NewRestoreClient(
 		pdClient:   pdClient,
 		toolClient: NewSplitClient(pdClient, tlsConf),
 		db:         db,
+		store:      store,
 		tlsConf:    tlsConf,
 	}, nil
 }
@@ -164,11 +168,13 @@ func (rc *Client) IsRawKvMode() bool {
 }
 
 // GetFilesInRawRange gets all files that are in the given range or intersects with the given range.
+// The given range may span several backed up ranges of the cf, but it must be fully covered by them.
 func (rc *Client) GetFilesInRawRange(startKey []byte, endKey []byte, cf string) ([]*backup.File, error) {
 	if !rc.IsRawKvMode() {
 		return nil, errors.New("the backup data is not in raw kv mode")
 	}
 
+	rawRanges := make([]*backup.RawRange, 0)
 	for _, rawRange := range rc.backupMeta.RawRanges {
 		// First check whether the given range is backup-ed. If not, we cannot perform the restore.
 		if rawRange.Cf != cf {
@@ -180,40 +186,56 @@ func (rc *Client) GetFilesInRawRange(startKey []byte, endKey []byte, cf string)
 			// The restoring range is totally out of the current range. Skip it.
 			continue
 		}
+		rawRanges = append(rawRanges, rawRange)
+	}
+	if len(rawRanges) == 0 {
+		return nil, errors.New("no backup data in the range")
+	}
 
-		if bytes.Compare(startKey, rawRange.StartKey) < 0 ||
-			utils.CompareEndKey(endKey, rawRange.EndKey) > 0 {
-			// Only partial of the restoring range is in the current backup-ed range. So the given range can't be fully
-			// restored.
-			return nil, errors.New("the given range to restore is not fully covered by the range that was backed up")
+	// Check whether the backed up ranges cover the restoring range without any gap.
+	sort.Slice(rawRanges, func(i, j int) bool {
+		return bytes.Compare(rawRanges[i].StartKey, rawRanges[j].StartKey) < 0
+	})
+	covered, coveredToEnd := startKey, false
+	for _, rawRange := range rawRanges {
+		if bytes.Compare(covered, rawRange.StartKey) < 0 {
+			// There is a gap between the backed up ranges.
+			break
 		}
+		if len(rawRange.EndKey) == 0 {
+			coveredToEnd = true
+			break
+		}
+		if bytes.Compare(rawRange.EndKey, covered) > 0 {
+			covered = rawRange.EndKey
+		}
+	}
+	if !coveredToEnd && utils.CompareEndKey(endKey, covered) > 0 {
+		// Only partial of the restoring range is in the backup-ed ranges. So the given range can't be fully
+		// restored.
+		return nil, errors.New("the given range to restore is not fully covered by the range that was backed up")
+	}
 
-		// We have found the range that contains the given range. Find all necessary files.
-		files := make([]*backup.File, 0)
-
-		for _, file := range rc.backupMeta.Files {
-			if file.Cf != cf {
-				continue
-			}
-
-			if len(file.EndKey) > 0 && bytes.Compare(file.EndKey, startKey) < 0 {
-				// The file is before the range to be restored.
-				continue
-			}
-			if len(endKey) > 0 && bytes.Compare(endKey, file.StartKey) <= 0 {
-				// The file is after the range to be restored.
-				// The specified endKey is exclusive, so when it equals to a file's startKey, the file is still skipped.
-				continue
-			}
+	// We have found the ranges that contain the given range. Find all necessary files.
+	files := make([]*backup.File, 0)
+	for _, file := range rc.backupMeta.Files {
+		if file.Cf != cf {
+			continue
+		}
 
-			files = append(files, file)
+		if len(file.EndKey) > 0 && bytes.Compare(file.EndKey, startKey) < 0 {
+			// The file is before the range to be restored.
+			continue
+		}
+		if len(endKey) > 0 && bytes.Compare(endKey, file.StartKey) <= 0 {
+			// The file is after the range to be restored.
+			// The specified endKey is exclusive, so when it equals to a file's startKey, the file is still skipped.
+			continue
 		}
 
-		// There should be at most one backed up range that covers the restoring range.
-		return files, nil
+		files = append(files, file)
 	}
-
-	return nil, errors.New("no backup data in the range")
+	return files, nil
 }
 
 // SetConcurrency sets the concurrency of dbs tables files.
@@ -292,7 +314,32 @@ func (rc *Client) GetTableSchema(
 	dbName model.CIStr,
 	tableName model.CIStr,
 ) (*model.TableInfo, error) {
-	info := dom.InfoSchema()
+	info, err := rc.latestInfoSchema(dom)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return getTableSchema(info, dbName, tableName)
+}
+
+// latestInfoSchema returns the latest info schema of the domain, or loads it
+// from the storage if the domain is nil.
+func (rc *Client) latestInfoSchema(dom *domain.Domain) (infoschema.InfoSchema, error) {
+	if dom != nil {
+		return dom.InfoSchema(), nil
+	}
+	ver, err := rc.store.CurrentVersion()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	info, err := utils.GetSnapshotInfoSchema(nil, rc.store, ver.Ver)
+	return info, errors.Trace(err)
+}
+
+func getTableSchema(
+	info infoschema.InfoSchema,
+	dbName model.CIStr,
+	tableName model.CIStr,
+) (*model.TableInfo, error) {
 	table, err := info.TableByName(dbName, tableName)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -320,16 +367,28 @@ func (rc *Client) CreateTables(
 		Data:  make([]*import_sstpb.RewriteRule, 0),
 	}
 	newTables := make([]*model.TableInfo, 0, len(tables))
-	for _, table := range tables {
-		if rc.IsSkipCreateSQL() {
-			log.Info("skip create table and alter autoIncID", zap.Stringer("table", table.Info.Name))
-		} else {
+	if rc.IsSkipCreateSQL() {
+		log.Info("skip create tables and alter autoIncID", zap.Int("tables", len(tables)))
+	} else {
+		// Views and sequences must be created after the objects they depend on.
+		sortedTables, err := SortTablesByDependency(tables)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		for _, table := range sortedTables {
 			err := rc.db.CreateTable(rc.ctx, table)
 			if err != nil {
 				return nil, nil, err
 			}
 		}
-		newTableInfo, err := rc.GetTableSchema(dom, table.Db.Name, table.Info.Name)
+	}
+	info, err := rc.latestInfoSchema(dom)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	// The new tables must be in the same order as the given tables.
+	for _, table := range tables {
+		newTableInfo, err := getTableSchema(info, table.Db.Name, table.Info.Name)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -487,7 +546,7 @@ func (rc *Client) RestoreFiles(
 				case <-rc.ctx.Done():
 					errCh <- rc.ctx.Err()
 				case errCh <- rc.fileImporter.Import(fileReplica, rejectStoreMap, rewriteRules):
-					updateCh.Inc()
+					glue.IncWithWeight(updateCh, fileReplica.TotalKvs, fileReplica.TotalBytes)
 				}
 			})
 	}
@@ -508,7 +567,15 @@ func (rc *Client) RestoreFiles(
 }
 
 // RestoreRaw tries to restore raw keys in the specified range.
-func (rc *Client) RestoreRaw(startKey []byte, endKey []byte, files []*backup.File, updateCh glue.Progress) error {
+// If rewriteRules is not nil, the prefix of the keys is rewritten by the rules,
+// see NewRawRewriteRules.
+func (rc *Client) RestoreRaw(
+	startKey []byte,
+	endKey []byte,
+	files []*backup.File,
+	rewriteRules *RewriteRules,
+	updateCh glue.Progress,
+) error {
 	start := time.Now()
 	defer func() {
 		elapsed := time.Since(start)
@@ -521,12 +588,14 @@ func (rc *Client) RestoreRaw(startKey []byte, endKey []byte, files []*backup.Fil
 	wg := new(sync.WaitGroup)
 	defer close(errCh)
 
-	err := rc.fileImporter.SetRawRange(startKey, endKey)
+	err := rc.fileImporter.SetRawRange(RewriteRawRange(startKey, endKey, rewriteRules))
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	emptyRules := &RewriteRules{}
+	if rewriteRules == nil {
+		rewriteRules = &RewriteRules{}
+	}
 	for _, file := range files {
 		wg.Add(1)
 		fileReplica := file
@@ -536,8 +605,8 @@ func (rc *Client) RestoreRaw(startKey []byte, endKey []byte, files []*backup.Fil
 				select {
 				case <-rc.ctx.Done():
 					errCh <- rc.ctx.Err()
-				case errCh <- rc.fileImporter.Import(fileReplica, nil, emptyRules):
-					updateCh.Inc()
+				case errCh <- rc.fileImporter.Import(fileReplica, nil, rewriteRules):
+					glue.IncWithWeight(updateCh, fileReplica.TotalKvs, fileReplica.TotalBytes)
 				}
 			})
 	}
@@ -665,8 +734,8 @@ func (rc *Client) ValidateChecksum(
 					errCh <- errors.Trace(err)
 					return
 				}
-				checksumResp, err := exe.Execute(ctx, kvClient, func() {
-					// TODO: update progress here.
+				checksumResp, err := exe.Execute(ctx, kvClient, func(resp *tipb.ChecksumResponse) {
+					glue.AddWeight(updateCh, resp.TotalKvs, resp.TotalBytes)
 				})
 				if err != nil {
 					errCh <- errors.Trace(err)
@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/types"
@@ -105,3 +106,54 @@ func (s *testRestoreClientSuite) TestIsOnline(c *C) {
 	client.EnableOnline()
 	c.Assert(client.IsOnline(), IsTrue)
 }
+
+func (s *testRestoreClientSuite) TestGetFilesInRawRange(c *C) {
+	c.Assert(s.mock.Start(), IsNil)
+	defer s.mock.Stop()
+
+	client, err := restore.NewRestoreClient(context.Background(), gluetidb.Glue{}, s.mock.PDClient, s.mock.Storage, nil)
+	c.Assert(err, IsNil)
+	backupMeta := &backup.BackupMeta{
+		IsRawKv: true,
+		RawRanges: []*backup.RawRange{
+			{StartKey: []byte("c"), EndKey: []byte("e"), Cf: "default"},
+			{StartKey: []byte("a"), EndKey: []byte("c"), Cf: "default"},
+			{StartKey: []byte("g"), EndKey: []byte("h"), Cf: "default"},
+			{StartKey: []byte("a"), EndKey: []byte("h"), Cf: "write"},
+		},
+		Files: []*backup.File{
+			{Name: "1", StartKey: []byte("a"), EndKey: []byte("c"), Cf: "default"},
+			{Name: "2", StartKey: []byte("c"), EndKey: []byte("e"), Cf: "default"},
+			{Name: "3", StartKey: []byte("g"), EndKey: []byte("h"), Cf: "default"},
+			{Name: "4", StartKey: []byte("a"), EndKey: []byte("h"), Cf: "write"},
+		},
+	}
+	c.Assert(client.InitBackupMeta(backupMeta, nil), IsNil)
+
+	fileNames := func(files []*backup.File) []string {
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	// The range spans two backed up ranges.
+	files, err := client.GetFilesInRawRange([]byte("b"), []byte("d"), "default")
+	c.Assert(err, IsNil)
+	c.Assert(fileNames(files), DeepEquals, []string{"1", "2"})
+
+	files, err = client.GetFilesInRawRange([]byte("g"), []byte("h"), "default")
+	c.Assert(err, IsNil)
+	c.Assert(fileNames(files), DeepEquals, []string{"3"})
+
+	files, err = client.GetFilesInRawRange([]byte("b"), []byte("d"), "write")
+	c.Assert(err, IsNil)
+	c.Assert(fileNames(files), DeepEquals, []string{"4"})
+
+	// There is a gap between [a, e) and [g, h).
+	_, err = client.GetFilesInRawRange([]byte("a"), []byte("h"), "default")
+	c.Assert(err, ErrorMatches, "the given range to restore is not fully covered.*")
+	_, err = client.GetFilesInRawRange([]byte("x"), []byte("z"), "default")
+	c.Assert(err, ErrorMatches, "no backup data in the range")
+}
@@ -115,7 +115,7 @@ func (s *testRestoreSchemaSuite) TestFilterDDLJobs(c *C) {
 
 	ts, err := s.mock.GetOracle().GetTimestamp(context.Background())
 	c.Assert(err, IsNil, Commentf("Error get ts: %s", err))
-	allDDLJobs, err := backup.GetBackupDDLJobs(s.mock.Domain, lastTs, ts)
+	allDDLJobs, err := backup.GetBackupDDLJobs(s.mock.Storage, lastTs, ts)
 	c.Assert(err, IsNil, Commentf("Error get ddl jobs: %s", err))
 	infoSchema, err := s.mock.Domain.GetSnapshotInfoSchema(ts)
 	c.Assert(err, IsNil, Commentf("Error get snapshot info schema: %s", err))
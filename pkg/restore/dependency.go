@@ -0,0 +1,186 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	_ "github.com/pingcap/tidb/types/parser_driver" // for parser driver
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// tableKind decides the order of tables without dependency between each other,
+// sequences are created first, then base tables, and views at last.
+type tableKind int
+
+const (
+	kindSequence tableKind = iota
+	kindBaseTable
+	kindView
+)
+
+func getTableKind(info *model.TableInfo) tableKind {
+	switch {
+	case info.IsSequence():
+		return kindSequence
+	case info.IsView():
+		return kindView
+	default:
+		return kindBaseTable
+	}
+}
+
+type tableName struct {
+	db    string
+	table string
+}
+
+func (n tableName) String() string {
+	return utils.EncloseName(n.db) + "." + utils.EncloseName(n.table)
+}
+
+func newTableName(db, table model.CIStr) tableName {
+	return tableName{db: db.L, table: table.L}
+}
+
+// tableNameCollector collects all table names referenced in an AST.
+type tableNameCollector struct {
+	defaultDB model.CIStr
+	names     []tableName
+}
+
+// Enter implements ast.Visitor.
+func (c *tableNameCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if tn, ok := n.(*ast.TableName); ok {
+		db := tn.Schema
+		if db.L == "" {
+			db = c.defaultDB
+		}
+		c.names = append(c.names, newTableName(db, tn.Name))
+	}
+	return n, false
+}
+
+// Leave implements ast.Visitor.
+func (c *tableNameCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// getTableDependencies returns the names of the tables, views and sequences
+// that must exist before the given table can be created. Views depend on the
+// objects used in their definition, and tables depend on the sequences used
+// in the default values of their columns.
+func getTableDependencies(p *parser.Parser, table *utils.Table) ([]tableName, error) {
+	collector := &tableNameCollector{defaultDB: table.Db.Name}
+	if table.Info.IsView() {
+		stmt, err := p.ParseOneStmt(table.Info.View.SelectStmt, "", "")
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to parse definition of view %s.%s",
+				table.Db.Name, table.Info.Name)
+		}
+		stmt.Accept(collector)
+	}
+	for _, col := range table.Info.Columns {
+		if !col.DefaultIsExpr {
+			continue
+		}
+		expr, ok := col.GetDefaultValue().(string)
+		if !ok || !strings.HasPrefix(strings.ToLower(expr), ast.NextVal) {
+			continue
+		}
+		stmt, err := p.ParseOneStmt("SELECT "+expr, mysql.DefaultCharset, mysql.DefaultCollationName)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to parse default value of column %s.%s.%s",
+				table.Db.Name, table.Info.Name, col.Name)
+		}
+		stmt.Accept(collector)
+	}
+	return collector.names, nil
+}
+
+// SortTablesByDependency sorts the tables so that every table is created after
+// the sequences and views it depends on. Sequences and base tables come first,
+// followed by views in dependency order. Dependencies on tables which are not
+// in the given list are ignored, since they may already exist in the cluster.
+// A circular dependency is reported as an error.
+func SortTablesByDependency(tables []*utils.Table) ([]*utils.Table, error) {
+	p := parser.New()
+	byName := make(map[tableName]int, len(tables))
+	for i, table := range tables {
+		byName[newTableName(table.Db.Name, table.Info.Name)] = i
+	}
+	deps := make([][]int, len(tables))
+	for i, table := range tables {
+		names, err := getTableDependencies(p, table)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, name := range names {
+			if j, ok := byName[name]; ok && j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	order := make([]int, len(tables))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return getTableKind(tables[order[i]].Info) < getTableKind(tables[order[j]].Info)
+	})
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := make([]int, len(tables))
+	sorted := make([]*utils.Table, 0, len(tables))
+	// path holds the tables being visited, used to report the cycle.
+	path := make([]int, 0)
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			// The tables in path from i depend on each other in turn, and the
+			// last one depends on i again.
+			cycle := make([]string, 0, len(path)+1)
+			for k := len(path) - 1; k >= 0 && path[k] != i; k-- {
+				cycle = append(cycle, newTableName(tables[path[k]].Db.Name, tables[path[k]].Info.Name).String())
+			}
+			name := newTableName(tables[i].Db.Name, tables[i].Info.Name).String()
+			cycle = append(cycle, name)
+			for l, r := 0, len(cycle)-1; l < r; l, r = l+1, r-1 {
+				cycle[l], cycle[r] = cycle[r], cycle[l]
+			}
+			cycle = append(cycle, name)
+			return errors.Errorf("circular dependency found between tables: %s", strings.Join(cycle, " -> "))
+		}
+		states[i] = visiting
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+		sorted = append(sorted, tables[i])
+		return nil
+	}
+	for _, i := range order {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testDependencySuite{})
+
+type testDependencySuite struct{}
+
+func newDependencyTestTable(db string, info *model.TableInfo) *utils.Table {
+	return &utils.Table{
+		Db:   &model.DBInfo{Name: model.NewCIStr(db)},
+		Info: info,
+	}
+}
+
+func newTestView(name string, selectStmt string) *model.TableInfo {
+	return &model.TableInfo{
+		Name: model.NewCIStr(name),
+		View: &model.ViewInfo{SelectStmt: selectStmt},
+	}
+}
+
+func tableNames(tables []*utils.Table) []string {
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Db.Name.O+"."+table.Info.Name.O)
+	}
+	return names
+}
+
+func (s *testDependencySuite) TestSortTablesByDependency(c *C) {
+	seqDefault := &model.ColumnInfo{
+		Name:          model.NewCIStr("id"),
+		DefaultIsExpr: true,
+	}
+	c.Assert(seqDefault.SetDefaultValue("nextval(`test`.`seq`)"), IsNil)
+	tables := []*utils.Table{
+		newDependencyTestTable("test", newTestView("v2", "SELECT `a` FROM `v1` JOIN `other`.`t2`")),
+		newDependencyTestTable("test", newTestView("v1", "SELECT `a` FROM `test`.`t1`")),
+		newDependencyTestTable("test", &model.TableInfo{
+			Name:    model.NewCIStr("t1"),
+			Columns: []*model.ColumnInfo{seqDefault},
+		}),
+		newDependencyTestTable("other", &model.TableInfo{Name: model.NewCIStr("t2")}),
+		newDependencyTestTable("test", &model.TableInfo{
+			Name:     model.NewCIStr("seq"),
+			Sequence: &model.SequenceInfo{},
+		}),
+		// The referenced table is not restored, so it's ignored.
+		newDependencyTestTable("test", newTestView("v3", "SELECT * FROM `missing`")),
+	}
+	sorted, err := restore.SortTablesByDependency(tables)
+	c.Assert(err, IsNil)
+	c.Assert(tableNames(sorted), DeepEquals, []string{
+		"test.seq", "test.t1", "other.t2", "test.v1", "test.v2", "test.v3",
+	})
+}
+
+func (s *testDependencySuite) TestSortTablesByDependencyCycle(c *C) {
+	tables := []*utils.Table{
+		newDependencyTestTable("test", &model.TableInfo{Name: model.NewCIStr("t1")}),
+		newDependencyTestTable("test", newTestView("v1", "SELECT * FROM `v3`, `t1`")),
+		newDependencyTestTable("test", newTestView("v2", "SELECT * FROM `v1`")),
+		newDependencyTestTable("test", newTestView("v3", "SELECT * FROM `v2`")),
+	}
+	_, err := restore.SortTablesByDependency(tables)
+	c.Assert(err, ErrorMatches,
+		"circular dependency found between tables: `test`.`v1` -> `test`.`v3` -> `test`.`v2` -> `test`.`v1`")
+
+	tables = []*utils.Table{
+		newDependencyTestTable("test", newTestView("v1", "SELECT FROM WHERE")),
+	}
+	_, err = restore.SortTablesByDependency(tables)
+	c.Assert(err, ErrorMatches, "failed to parse definition of view test.v1.*")
+}
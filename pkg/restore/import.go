@@ -190,8 +190,11 @@ func (importer *FileImporter) Import(
 	var startKey, endKey []byte
 	var err error
 	if importer.isRawKvMode {
-		startKey = file.StartKey
-		endKey = file.EndKey
+		if IsEmptyRawRange(file.GetStartKey(), file.GetEndKey(), rewriteRules) {
+			log.Debug("file is out of the restoring range, skip it", zap.Stringer("file", file))
+			return nil
+		}
+		startKey, endKey = RewriteRawRange(file.GetStartKey(), file.GetEndKey(), rewriteRules)
 	} else {
 		startKey, endKey, err = rewriteFileKeys(file, rewriteRules)
 		// if not truncateRowKey here, if will scan one more region
@@ -209,7 +212,12 @@ func (importer *FileImporter) Import(
 
 	needReject := len(rejectStoreMap) > 0
 
+	importAttempt := 0
 	err = utils.WithRetry(importer.ctx, func() error {
+		if importAttempt > 0 {
+			restoreRetryCounters.WithLabelValues("import").Inc()
+		}
+		importAttempt++
 		ctx, cancel := context.WithTimeout(importer.ctx, importScanRegionTime)
 		defer cancel()
 		// Scan regions covered by the file range
@@ -242,10 +250,15 @@ func (importer *FileImporter) Import(
 			info := regionInfo
 			// Try to download file.
 			var downloadMeta *import_sstpb.SSTMeta
+			downloadAttempt := 0
 			errDownload := utils.WithRetry(importer.ctx, func() error {
+				if downloadAttempt > 0 {
+					restoreRetryCounters.WithLabelValues("download").Inc()
+				}
+				downloadAttempt++
 				var e error
 				if importer.isRawKvMode {
-					downloadMeta, e = importer.downloadRawKVSST(info, file)
+					downloadMeta, e = importer.downloadRawKVSST(info, file, rewriteRules)
 				} else {
 					downloadMeta, e = importer.downloadSST(info, file, rewriteRules)
 				}
@@ -259,8 +272,8 @@ func (importer *FileImporter) Import(
 						log.Error("download file skipped",
 							zap.Stringer("file", file),
 							zap.Stringer("region", info.Region),
-							zap.Binary("startKey", startKey),
-							zap.Binary("endKey", endKey),
+							utils.ZapKey("startKey", startKey),
+							utils.ZapKey("endKey", endKey),
 							zap.Error(e))
 						continue regionLoop
 					}
@@ -268,8 +281,8 @@ func (importer *FileImporter) Import(
 				log.Error("download file failed",
 					zap.Stringer("file", file),
 					zap.Stringer("region", info.Region),
-					zap.Binary("startKey", startKey),
-					zap.Binary("endKey", endKey),
+					utils.ZapKey("startKey", startKey),
+					utils.ZapKey("endKey", endKey),
 					zap.Error(errDownload))
 				return errDownload
 			}
@@ -307,6 +320,7 @@ func (importer *FileImporter) Import(
 						errIngest = errors.AddStack(ErrEpochNotMatch)
 						break ingestRetry
 					}
+					restoreRetryCounters.WithLabelValues("ingest").Inc()
 					ingestResp, errIngest = importer.ingestSST(downloadMeta, newInfo)
 				case errPb.EpochNotMatch != nil:
 					// TODO handle epoch not match error
@@ -337,6 +351,9 @@ func (importer *FileImporter) Import(
 		}
 		return nil
 	}, newImportSSTBackoffer())
+	if err == nil {
+		restoreIngestedBytes.Add(float64(file.TotalBytes))
+	}
 	return err
 }
 
@@ -385,7 +402,10 @@ func (importer *FileImporter) downloadSST(
 	)
 	var resp *import_sstpb.DownloadResponse
 	for _, peer := range regionInfo.Region.GetPeers() {
+		startTime := time.Now()
 		resp, err = importer.importClient.DownloadSST(importer.ctx, peer.GetStoreId(), req)
+		restoreDownloadHistogram.WithLabelValues(storeLabel(peer.GetStoreId())).
+			Observe(time.Since(startTime).Seconds())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -404,13 +424,21 @@ func (importer *FileImporter) downloadSST(
 func (importer *FileImporter) downloadRawKVSST(
 	regionInfo *RegionInfo,
 	file *backup.File,
+	rewriteRules *RewriteRules,
 ) (*import_sstpb.SSTMeta, error) {
 	id, err := uuid.New().MarshalBinary()
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	// Empty rule
+	// Raw kv keys are not encoded, so the prefixes are used as is.
+	// The rule is empty if the keys are restored without rewriting.
 	var rule import_sstpb.RewriteRule
+	if regionRule := rawRewriteRule(rewriteRules); regionRule != nil {
+		rule = import_sstpb.RewriteRule{
+			OldKeyPrefix: regionRule.GetOldKeyPrefix(),
+			NewKeyPrefix: regionRule.GetNewKeyPrefix(),
+		}
+	}
 	sstMeta := GetSSTMetaFromFile(id, file, regionInfo.Region, &rule)
 
 	// Cut the SST file's range to fit in the restoring range.
@@ -439,7 +467,10 @@ func (importer *FileImporter) downloadRawKVSST(
 	)
 	var resp *import_sstpb.DownloadResponse
 	for _, peer := range regionInfo.Region.GetPeers() {
+		startTime := time.Now()
 		resp, err = importer.importClient.DownloadSST(importer.ctx, peer.GetStoreId(), req)
+		restoreDownloadHistogram.WithLabelValues(storeLabel(peer.GetStoreId())).
+			Observe(time.Since(startTime).Seconds())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -473,7 +504,10 @@ func (importer *FileImporter) ingestSST(
 		Sst:     sstMeta,
 	}
 	log.Debug("ingest SST", zap.Stringer("sstMeta", sstMeta), zap.Reflect("leader", leader))
+	startTime := time.Now()
 	resp, err := importer.importClient.IngestSST(importer.ctx, leader.GetStoreId(), req)
+	restoreIngestHistogram.WithLabelValues(storeLabel(leader.GetStoreId())).
+		Observe(time.Since(startTime).Seconds())
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
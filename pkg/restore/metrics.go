@@ -0,0 +1,76 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	restoreSplitHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "split_region_seconds",
+			Help:      "Split region latency distributions.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		})
+
+	restoreScatterHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "scatter_region_seconds",
+			Help:      "Wait for scattering region latency distributions.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		})
+
+	restoreDownloadHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "download_sst_seconds",
+			Help:      "Download SST latency distributions of each store.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"store"})
+
+	restoreIngestHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "ingest_sst_seconds",
+			Help:      "Ingest SST latency distributions of each store.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"store"})
+
+	restoreRetryCounters = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "retry",
+			Help:      "Restore retry statistic.",
+		}, []string{"type"})
+
+	restoreIngestedBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "br",
+			Subsystem: "restore",
+			Name:      "ingested_bytes",
+			Help:      "The bytes of ingested files.",
+		})
+)
+
+func init() { // nolint:gochecknoinits
+	prometheus.MustRegister(restoreSplitHistogram)
+	prometheus.MustRegister(restoreScatterHistogram)
+	prometheus.MustRegister(restoreDownloadHistogram)
+	prometheus.MustRegister(restoreIngestHistogram)
+	prometheus.MustRegister(restoreRetryCounters)
+	prometheus.MustRegister(restoreIngestedBytes)
+}
+
+func storeLabel(storeID uint64) string {
+	return strconv.FormatUint(storeID, 10)
+}
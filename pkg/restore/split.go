@@ -17,6 +17,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/rtree"
+	"github.com/pingcap/br/pkg/utils"
 )
 
 // Constants for split retry machinery.
@@ -74,6 +75,10 @@ func (rs *RegionSplitter) Split(
 	if errSplit != nil {
 		return errors.Trace(errSplit)
 	}
+	if rewriteRules == nil {
+		// The ranges are split without rewriting, e.g. raw kv.
+		rewriteRules = &RewriteRules{}
+	}
 	minKey := codec.EncodeBytes([]byte{}, sortedRanges[0].StartKey)
 	maxKey := codec.EncodeBytes([]byte{}, sortedRanges[len(sortedRanges)-1].EndKey)
 	for _, rule := range rewriteRules.Table {
@@ -117,12 +122,13 @@ SplitRegions:
 				if strings.Contains(errSplit.Error(), "no valid key") {
 					for _, key := range keys {
 						log.Error("no valid key",
-							zap.Binary("startKey", region.Region.StartKey),
-							zap.Binary("endKey", region.Region.EndKey),
-							zap.Binary("key", codec.EncodeBytes([]byte{}, key)))
+							utils.ZapKey("startKey", region.Region.StartKey),
+							utils.ZapKey("endKey", region.Region.EndKey),
+							utils.ZapKey("key", codec.EncodeBytes([]byte{}, key)))
 					}
 					return errors.Trace(errSplit)
 				}
+				restoreRetryCounters.WithLabelValues("split").Inc()
 				interval = 2 * interval
 				if interval > SplitMaxRetryInterval {
 					interval = SplitMaxRetryInterval
@@ -221,6 +227,10 @@ var retryTimes = new(retryTimeKey)
 func (rs *RegionSplitter) waitForScatterRegion(ctx context.Context, regionInfo *RegionInfo) {
 	interval := ScatterWaitInterval
 	regionID := regionInfo.Region.GetId()
+	startTime := time.Now()
+	defer func() {
+		restoreScatterHistogram.Observe(time.Since(startTime).Seconds())
+	}()
 	for i := 0; i < ScatterWaitMaxRetryTimes; i++ {
 		ctx1 := context.WithValue(ctx, retryTimes, i)
 		ok, err := rs.isScatterRegionFinished(ctx1, regionID)
@@ -243,7 +253,9 @@ func (rs *RegionSplitter) waitForScatterRegion(ctx context.Context, regionInfo *
 func (rs *RegionSplitter) splitAndScatterRegions(
 	ctx context.Context, regionInfo *RegionInfo, keys [][]byte,
 ) ([]*RegionInfo, error) {
+	startTime := time.Now()
 	newRegions, err := rs.client.BatchSplitRegions(ctx, regionInfo, keys)
+	restoreSplitHistogram.Observe(time.Since(startTime).Seconds())
 	if err != nil {
 		return nil, err
 	}
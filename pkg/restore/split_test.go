@@ -5,6 +5,7 @@ package restore_test
 import (
 	"bytes"
 	"context"
+	"sort"
 	"sync"
 
 	. "github.com/pingcap/check"
@@ -215,6 +216,44 @@ func (s *testRestoreUtilSuite) TestSplit(c *C) {
 	}
 }
 
+// region: [, aay), [aay, bba), [bba, bbh), [bbh, cca), [cca, )
+// raw range: [aaa, aae), [ccd, ccf), [ccf, ccj)
+// raw rewrite rule: cc -> bb
+// expected regions after split:
+//   [, aay), [aay, bba), [bba, bbf), [bbf, bbh), [bbh, bbj), [bbj, cca), [cca, )
+func (s *testRestoreUtilSuite) TestSplitRewrittenRawRanges(c *C) {
+	client := initTestClient()
+	ranges := []rtree.Range{
+		{StartKey: []byte("aaa"), EndKey: []byte("aae")},
+		{StartKey: []byte("ccd"), EndKey: []byte("ccf")},
+		{StartKey: []byte("ccf"), EndKey: []byte("ccj")},
+	}
+	rules := restore.NewRawRewriteRules([]byte("cc"), []byte("bb"))
+	ranges = restore.RewriteRawRanges(ranges, rules)
+	c.Assert(ranges, DeepEquals, []rtree.Range{
+		{StartKey: []byte("bbd"), EndKey: []byte("bbf")},
+		{StartKey: []byte("bbf"), EndKey: []byte("bbj")},
+	})
+
+	var splitKeys [][]byte
+	err := restore.NewRegionSplitter(client).Split(context.Background(), ranges, nil, func(keys [][]byte) {
+		splitKeys = append(splitKeys, keys...)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(splitKeys, HasLen, 2)
+	var boundaries []string
+	for _, region := range client.GetAllRegions() {
+		if len(region.Region.StartKey) == 0 {
+			continue
+		}
+		_, key, err := codec.DecodeBytes(region.Region.StartKey, nil)
+		c.Assert(err, IsNil)
+		boundaries = append(boundaries, string(key))
+	}
+	sort.Strings(boundaries)
+	c.Assert(boundaries, DeepEquals, []string{"aay", "bba", "bbf", "bbh", "bbj", "cca"})
+}
+
 // region: [, aay), [aay, bba), [bba, bbh), [bbh, cca), [cca, )
 func initTestClient() *testClient {
 	peers := make([]*metapb.Peer, 1)
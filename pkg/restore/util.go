@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/rtree"
 	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
 )
 
 var recordPrefixSep = []byte("_r")
@@ -294,6 +295,99 @@ func truncateTS(key []byte) []byte {
 	return key[:len(key)-8]
 }
 
+// NewRawRewriteRules returns the rewrite rules which replace the prefix of
+// raw kv keys from oldPrefix to newPrefix.
+// Raw kv restore supports a single prefix rule only.
+func NewRawRewriteRules(oldPrefix, newPrefix []byte) *RewriteRules {
+	return &RewriteRules{
+		Data: []*import_sstpb.RewriteRule{{
+			OldKeyPrefix: oldPrefix,
+			NewKeyPrefix: newPrefix,
+		}},
+	}
+}
+
+func rawRewriteRule(rewriteRules *RewriteRules) *import_sstpb.RewriteRule {
+	if rewriteRules == nil || len(rewriteRules.Data) == 0 {
+		return nil
+	}
+	return rewriteRules.Data[0]
+}
+
+// prefixEnd returns the smallest key which is greater than all keys with the
+// prefix. An empty key means there is no upper bound.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return []byte{}
+}
+
+// ClipRawRange limits the raw kv range [startKey, endKey) to the keys which
+// have the old prefix of the rewrite rules, since only those keys are restored.
+func ClipRawRange(startKey, endKey []byte, rewriteRules *RewriteRules) ([]byte, []byte) {
+	rule := rawRewriteRule(rewriteRules)
+	if rule == nil {
+		return startKey, endKey
+	}
+	if bytes.Compare(startKey, rule.GetOldKeyPrefix()) < 0 {
+		startKey = rule.GetOldKeyPrefix()
+	}
+	if oldEnd := prefixEnd(rule.GetOldKeyPrefix()); utils.CompareEndKey(endKey, oldEnd) > 0 {
+		endKey = oldEnd
+	}
+	return startKey, endKey
+}
+
+// IsEmptyRawRange returns whether no key in the raw kv range is restored by
+// the rewrite rules.
+func IsEmptyRawRange(startKey, endKey []byte, rewriteRules *RewriteRules) bool {
+	startKey, endKey = ClipRawRange(startKey, endKey, rewriteRules)
+	return utils.CompareEndKey(endKey, startKey) <= 0
+}
+
+// RewriteRawRange clips the raw kv range [startKey, endKey) by ClipRawRange,
+// and then replaces the old prefix of its keys with the new prefix.
+// Unlike rewriteRawKey, the keys returned are not encoded. The clipped range
+// should be checked by IsEmptyRawRange, since an empty one can't be rewritten.
+func RewriteRawRange(startKey, endKey []byte, rewriteRules *RewriteRules) ([]byte, []byte) {
+	rule := rawRewriteRule(rewriteRules)
+	if rule == nil {
+		return startKey, endKey
+	}
+	startKey, endKey = ClipRawRange(startKey, endKey, rewriteRules)
+	rewrite := func(key []byte) []byte {
+		if !bytes.HasPrefix(key, rule.GetOldKeyPrefix()) {
+			// The key is the end of the old prefix.
+			return prefixEnd(rule.GetNewKeyPrefix())
+		}
+		return append(append([]byte{}, rule.GetNewKeyPrefix()...), key[len(rule.GetOldKeyPrefix()):]...)
+	}
+	return rewrite(startKey), rewrite(endKey)
+}
+
+// RewriteRawRanges rewrites the raw kv ranges by RewriteRawRange, so that the
+// regions are split on the restored keys. The ranges without any key to
+// restore are dropped.
+func RewriteRawRanges(ranges []rtree.Range, rewriteRules *RewriteRules) []rtree.Range {
+	if rawRewriteRule(rewriteRules) == nil {
+		return ranges
+	}
+	newRanges := make([]rtree.Range, 0, len(ranges))
+	for _, rg := range ranges {
+		if IsEmptyRawRange(rg.StartKey, rg.EndKey, rewriteRules) {
+			continue
+		}
+		rg.StartKey, rg.EndKey = RewriteRawRange(rg.StartKey, rg.EndKey, rewriteRules)
+		newRanges = append(newRanges, rg)
+	}
+	return newRanges
+}
+
 // SplitRanges splits region by
 // 1. data range after rewrite.
 // 2. rewrite rules.
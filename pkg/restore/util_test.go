@@ -213,3 +213,32 @@ func (s *testRestoreUtilSuite) TestPaginateScanRegion(c *C) {
 	_, err = restore.PaginateScanRegion(ctx, newTestClient(stores, regionMap, 0), []byte{2}, []byte{1}, 3)
 	c.Assert(err, ErrorMatches, "startKey >= endKey.*")
 }
+
+func (s *testRestoreUtilSuite) TestRewriteRawRange(c *C) {
+	// Without rewrite rules, the range is unchanged.
+	start, end := restore.RewriteRawRange([]byte("a"), []byte("z"), nil)
+	c.Assert(start, DeepEquals, []byte("a"))
+	c.Assert(end, DeepEquals, []byte("z"))
+
+	rules := restore.NewRawRewriteRules([]byte("old"), []byte("new"))
+	start, end = restore.ClipRawRange([]byte("a"), []byte{}, rules)
+	c.Assert(start, DeepEquals, []byte("old"))
+	c.Assert(end, DeepEquals, []byte("ole"))
+	start, end = restore.RewriteRawRange([]byte("a"), []byte{}, rules)
+	c.Assert(start, DeepEquals, []byte("new"))
+	c.Assert(end, DeepEquals, []byte("nex"))
+
+	c.Assert(restore.IsEmptyRawRange([]byte("a"), []byte("b"), rules), IsTrue)
+	c.Assert(restore.IsEmptyRawRange([]byte("p"), []byte{}, rules), IsTrue)
+	c.Assert(restore.IsEmptyRawRange([]byte("a"), []byte("old"), rules), IsTrue)
+	c.Assert(restore.IsEmptyRawRange([]byte("a"), []byte("old1"), rules), IsFalse)
+	start, end = restore.RewriteRawRange([]byte("old1"), []byte("old9"), rules)
+	c.Assert(start, DeepEquals, []byte("new1"))
+	c.Assert(end, DeepEquals, []byte("new9"))
+
+	// The end of prefix with trailing 0xff.
+	rules = restore.NewRawRewriteRules([]byte{'a', 0xff}, []byte{0xff})
+	start, end = restore.RewriteRawRange([]byte{}, []byte{}, rules)
+	c.Assert(start, DeepEquals, []byte{0xff})
+	c.Assert(end, DeepEquals, []byte{})
+}
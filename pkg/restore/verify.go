@@ -0,0 +1,107 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// VerifyBackupFiles checks that the files exist in the storage and have the
+// sizes recorded in the backupmeta, and also the sha256 if checkSHA256 is
+// true. All files are checked, and all missing or corrupt files are reported
+// in the returned error.
+func VerifyBackupFiles(
+	ctx context.Context,
+	s storage.ExternalStorage,
+	files []*backup.File,
+	concurrency uint,
+	checkSHA256 bool,
+) error {
+	start := time.Now()
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	pool := utils.NewWorkerPool(concurrency, "verify backup files")
+	var (
+		mu       sync.Mutex
+		problems []string
+	)
+	wg := new(sync.WaitGroup)
+	for _, file := range files {
+		wg.Add(1)
+		fileReplica := file
+		pool.Apply(func() {
+			defer wg.Done()
+			if err := verifyBackupFile(ctx, s, fileReplica, checkSHA256); err != nil {
+				mu.Lock()
+				problems = append(problems, err.Error())
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.Errorf("%d of %d backup files are missing or corrupt:\n%s",
+			len(problems), len(files), strings.Join(problems, "\n"))
+	}
+	log.Info("verify backup files",
+		zap.Int("files", len(files)),
+		zap.Bool("sha256", checkSHA256),
+		zap.Duration("take", time.Since(start)))
+	return nil
+}
+
+func verifyBackupFile(ctx context.Context, s storage.ExternalStorage, file *backup.File, checkSHA256 bool) error {
+	size, err := s.FileSize(ctx, file.Name)
+	if err != nil {
+		if exists, existsErr := s.FileExists(ctx, file.Name); existsErr == nil && !exists {
+			return errors.Errorf("%s is missing", file.Name)
+		}
+		return errors.Annotatef(err, "cannot get the size of %s", file.Name)
+	}
+	// The size is not recorded by the old versions of TiKV.
+	if file.Size_ != 0 && uint64(size) != file.Size_ {
+		return errors.Errorf("%s has %d bytes, but %d bytes are recorded in backupmeta",
+			file.Name, size, file.Size_)
+	}
+	if checkSHA256 {
+		return CheckFileSHA256(ctx, s, file)
+	}
+	return nil
+}
+
+// CheckFileSHA256 reads the file from the storage and checks whether its
+// sha256 is the same as the one recorded in backupmeta.
+func CheckFileSHA256(ctx context.Context, s storage.ExternalStorage, file *backup.File) error {
+	data, err := s.Read(ctx, file.Name)
+	if err != nil {
+		return errors.Annotatef(err, "cannot read %s", file.Name)
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], file.Sha256) {
+		return errors.Errorf("backup data checksum failed: %s may be changed, "+
+			"calculated sha256 is %s, origin sha256 is %s",
+			file.Name, hex.EncodeToString(sum[:]), hex.EncodeToString(file.Sha256))
+	}
+	return nil
+}
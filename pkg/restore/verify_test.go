@@ -0,0 +1,57 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"context"
+	"crypto/sha256"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+var _ = Suite(&testVerifySuite{})
+
+type testVerifySuite struct{}
+
+func (s *testVerifySuite) TestVerifyBackupFiles(c *C) {
+	ctx := context.Background()
+	backend, err := storage.ParseBackend("local://"+c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	stg, err := storage.Create(ctx, backend, false)
+	c.Assert(err, IsNil)
+
+	newFile := func(name string, data []byte) *backup.File {
+		c.Assert(stg.Write(ctx, name, data), IsNil)
+		sum := sha256.Sum256(data)
+		return &backup.File{Name: name, Sha256: sum[:], Size_: uint64(len(data))}
+	}
+	good := newFile("1_write.sst", []byte("good data"))
+	// The size is not recorded by the old versions of TiKV.
+	noSize := newFile("2_write.sst", []byte("no size"))
+	noSize.Size_ = 0
+	files := []*backup.File{good, noSize}
+	c.Assert(restore.VerifyBackupFiles(ctx, stg, files, 2, true), IsNil)
+
+	truncated := newFile("3_write.sst", []byte("truncated data"))
+	c.Assert(stg.Write(ctx, truncated.Name, []byte("truncated")), IsNil)
+	changed := newFile("4_write.sst", []byte("changed data"))
+	c.Assert(stg.Write(ctx, changed.Name, []byte("CHANGED data")), IsNil)
+	missing := &backup.File{Name: "5_write.sst", Size_: 10}
+	files = append(files, truncated, changed, missing)
+
+	err = restore.VerifyBackupFiles(ctx, stg, files, 2, false)
+	c.Assert(err, ErrorMatches, "2 of 5 backup files are missing or corrupt:\n"+
+		"3_write.sst has 9 bytes, but 14 bytes are recorded in backupmeta\n"+
+		"5_write.sst is missing")
+
+	// The changed file is found by sha256.
+	err = restore.VerifyBackupFiles(ctx, stg, files, 1, true)
+	c.Assert(err, ErrorMatches, "3 of 5 backup files are missing or corrupt:\n"+
+		"3_write.sst has 9 bytes.*\n"+
+		"5_write.sst is missing\n"+
+		"backup data checksum failed: 4_write.sst may be changed.*")
+}
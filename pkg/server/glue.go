@@ -0,0 +1,76 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package server
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pingcap/br/pkg/glue"
+)
+
+// taskGlue is an implementation of glue.Glue which records the progress of a
+// task instead of printing progress bars.
+type taskGlue struct {
+	glue.Glue
+	task *serverTask
+}
+
+// StartProgress implements glue.Glue.
+func (g taskGlue) StartProgress(ctx context.Context, cmdName string, total int64, redirectLog bool) glue.Progress {
+	return g.task.startProgress(cmdName, total)
+}
+
+// Record implements glue.Glue.
+func (g taskGlue) Record(name string, value uint64) {
+	g.task.record(name, value)
+	g.Glue.Record(name, value)
+}
+
+// taskProgress is a step of a task, e.g. "Full backup" or "Checksum".
+type taskProgress struct {
+	name       string
+	total      int64
+	current    int64
+	kvs        uint64
+	bytes      uint64
+	totalBytes uint64
+	closed     int32
+}
+
+// Inc implements glue.Progress.
+func (p *taskProgress) Inc() {
+	atomic.AddInt64(&p.current, 1)
+}
+
+// SetTotalBytes implements glue.WeightedProgress.
+func (p *taskProgress) SetTotalBytes(total uint64) {
+	atomic.StoreUint64(&p.totalBytes, total)
+}
+
+// AddWeight implements glue.WeightedProgress.
+func (p *taskProgress) AddWeight(kvs, bytes uint64) {
+	atomic.AddUint64(&p.kvs, kvs)
+	atomic.AddUint64(&p.bytes, bytes)
+}
+
+// Close implements glue.Progress.
+func (p *taskProgress) Close() {
+	atomic.StoreInt32(&p.closed, 1)
+}
+
+func (p *taskProgress) info() ProgressInfo {
+	info := ProgressInfo{
+		Name:       p.name,
+		Total:      p.total,
+		Current:    atomic.LoadInt64(&p.current),
+		TotalBytes: atomic.LoadUint64(&p.totalBytes),
+		Bytes:      atomic.LoadUint64(&p.bytes),
+		KVs:        atomic.LoadUint64(&p.kvs),
+		Finished:   atomic.LoadInt32(&p.closed) != 0,
+	}
+	if info.Finished || info.Current > info.Total {
+		info.Current = info.Total
+	}
+	return info
+}
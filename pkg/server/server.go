@@ -0,0 +1,419 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/task"
+)
+
+const (
+	backupCmdName  = "Full backup"
+	restoreCmdName = "Full restore"
+
+	tasksPath = "/tasks"
+
+	defaultMaxFinishedTasks = 100
+)
+
+// Server runs backup and restore tasks submitted through the HTTP API.
+//
+// The API is:
+//
+//	POST   /tasks/backup   submit a backup task, the body is a task.BackupConfig
+//	POST   /tasks/restore  submit a restore task, the body is a task.RestoreConfig
+//	GET    /tasks          list all tasks
+//	GET    /tasks/{id}     get the state and progress of a task
+//	DELETE /tasks/{id}     cancel a task
+//
+// Besides the fields of the configs, the bodies accept "filter" and
+// "case-sensitive" which are the same as the flags of the command line.
+// Tasks are run one by one in the order of submission, since the tasks share
+// the summary of the process, and the backups of a cluster share the GC safe
+// point of BR. Only the latest finished tasks are kept.
+type Server struct {
+	ctx  context.Context
+	glue glue.Glue
+
+	mu      sync.Mutex
+	nextID  uint64
+	tasks   []*serverTask
+	changed chan struct{}
+	wg      sync.WaitGroup
+
+	maxFinishedTasks int
+
+	runBackup  func(context.Context, glue.Glue, string, *task.BackupConfig) error
+	runRestore func(context.Context, glue.Glue, string, *task.RestoreConfig) error
+}
+
+// NewServer creates a server running tasks with the glue. All tasks are
+// canceled when the context is done.
+func NewServer(ctx context.Context, g glue.Glue) *Server {
+	return &Server{
+		ctx:        ctx,
+		glue:       g,
+		nextID:     1,
+		changed:    make(chan struct{}),
+		runBackup:  task.RunBackup,
+		runRestore: task.RunRestore,
+
+		maxFinishedTasks: defaultMaxFinishedTasks,
+	}
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(tasksPath, s.handleTasks)
+	mux.HandleFunc(tasksPath+"/", s.handleTask)
+	return mux
+}
+
+// Close cancels all tasks and waits for them to exit.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, t := range s.tasks {
+		t.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// filterRequest specifies the tables of a task like the --filter and
+// --case-sensitive flags.
+type filterRequest struct {
+	Filter        []string `json:"filter"`
+	CaseSensitive bool     `json:"case-sensitive"`
+}
+
+func (r *filterRequest) apply(cfg *task.Config) error {
+	if len(r.Filter) == 0 {
+		return nil
+	}
+	f, err := filter.Parse(r.Filter)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !r.CaseSensitive {
+		f = filter.CaseInsensitive(f)
+	}
+	cfg.TableFilter = f
+	return nil
+}
+
+type backupRequest struct {
+	task.BackupConfig
+	filterRequest
+}
+
+type restoreRequest struct {
+	task.RestoreConfig
+	filterRequest
+}
+
+// defaultBackupConfig returns the backup config with the default values of
+// the command line flags.
+func defaultBackupConfig() (task.BackupConfig, error) {
+	flags := pflag.NewFlagSet("backup", pflag.ContinueOnError)
+	task.DefineCommonFlags(flags)
+	task.DefineBackupFlags(flags)
+	var cfg task.BackupConfig
+	err := cfg.ParseFromFlags(flags)
+	cfg.LogProgress = true
+	return cfg, errors.Trace(err)
+}
+
+// defaultRestoreConfig returns the restore config with the default values of
+// the command line flags.
+func defaultRestoreConfig() (task.RestoreConfig, error) {
+	flags := pflag.NewFlagSet("restore", pflag.ContinueOnError)
+	task.DefineCommonFlags(flags)
+	task.DefineRestoreFlags(flags)
+	var cfg task.RestoreConfig
+	err := cfg.ParseFromFlags(flags)
+	cfg.LogProgress = true
+	return cfg, errors.Trace(err)
+}
+
+func decodeRequest(r *http.Request, req interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		return errors.Annotate(err, "invalid request body")
+	}
+	return nil
+}
+
+func validateConfig(cfg *task.Config) error {
+	if len(cfg.Storage) == 0 {
+		return errors.New("storage must not be empty")
+	}
+	if len(cfg.PD) == 0 {
+		return errors.New("must provide at least one PD server address")
+	}
+	return nil
+}
+
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.mu.Lock()
+	tasks := append([]*serverTask{}, s.tasks...)
+	s.mu.Unlock()
+	infos := make([]TaskInfo, 0, len(tasks))
+	for _, t := range tasks {
+		infos = append(infos, t.info())
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, tasksPath+"/")
+	switch {
+	case name == string(TaskKindBackup) && r.Method == http.MethodPost:
+		s.submitBackup(w, r)
+		return
+	case name == string(TaskKindRestore) && r.Method == http.MethodPost:
+		s.submitRestore(w, r)
+		return
+	}
+
+	id, err := strconv.ParseUint(name, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errors.Errorf("invalid task id %s", name))
+		return
+	}
+	t := s.getTask(id)
+	if t == nil {
+		writeError(w, http.StatusNotFound, errors.Errorf("task %d not found", id))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		t.cancel()
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	writeJSON(w, http.StatusOK, t.info())
+}
+
+func (s *Server) submitBackup(w http.ResponseWriter, r *http.Request) {
+	cfg, err := defaultBackupConfig()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	req := backupRequest{BackupConfig: cfg}
+	if err = decodeRequest(r, &req); err == nil {
+		err = req.apply(&req.Config)
+	}
+	if err == nil {
+		err = validateConfig(&req.Config)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg = req.BackupConfig
+	t := s.submit(TaskKindBackup, &cfg.Config, func(ctx context.Context, g glue.Glue) error {
+		summary.SetUnit(summary.BackupUnit)
+		return s.runBackup(ctx, g, backupCmdName, &cfg)
+	})
+	writeJSON(w, http.StatusOK, t.info())
+}
+
+func (s *Server) submitRestore(w http.ResponseWriter, r *http.Request) {
+	cfg, err := defaultRestoreConfig()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	req := restoreRequest{RestoreConfig: cfg}
+	if err = decodeRequest(r, &req); err == nil {
+		err = req.apply(&req.Config)
+	}
+	if err == nil {
+		err = validateConfig(&req.Config)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg = req.RestoreConfig
+	t := s.submit(TaskKindRestore, &cfg.Config, func(ctx context.Context, g glue.Glue) error {
+		summary.SetUnit(summary.RestoreUnit)
+		return s.runRestore(ctx, g, restoreCmdName, &cfg)
+	})
+	writeJSON(w, http.StatusOK, t.info())
+}
+
+func (s *Server) getTask(id uint64) *serverTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// submit adds a task and runs it in the background.
+func (s *Server) submit(kind TaskKind, cfg *task.Config, run taskRunner) *serverTask {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	t := &serverTask{
+		id:         s.nextID,
+		kind:       kind,
+		storage:    cfg.Storage,
+		run:        run,
+		ctx:        ctx,
+		cancel:     cancel,
+		state:      TaskStatePending,
+		createTime: time.Now(),
+		records:    make(map[string]uint64),
+	}
+	s.nextID++
+	s.tasks = append(s.tasks, t)
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	log.Info("task submitted", zap.Uint64("id", t.id), zap.String("kind", string(kind)))
+	go s.runTask(t)
+	return t
+}
+
+func (s *Server) runTask(t *serverTask) {
+	defer s.wg.Done()
+	defer t.cancel()
+
+	err := s.waitForTurn(t)
+	if err == nil {
+		log.Info("task started", zap.Uint64("id", t.id))
+		err = t.run(t.ctx, taskGlue{Glue: s.glue, task: t})
+	}
+
+	s.mu.Lock()
+	t.mu.Lock()
+	t.endTime = time.Now()
+	switch {
+	case err == nil:
+		t.state = TaskStateSucceeded
+	case t.ctx.Err() != nil:
+		t.state = TaskStateCanceled
+		t.err = err
+	default:
+		t.state = TaskStateFailed
+		t.err = err
+	}
+	t.mu.Unlock()
+	s.removeFinishedLocked()
+	s.notifyLocked()
+	s.mu.Unlock()
+	log.Info("task finished", zap.Uint64("id", t.id), zap.Error(err))
+}
+
+// waitForTurn waits until no earlier task is pending or running, and marks
+// the task running.
+func (s *Server) waitForTurn(t *serverTask) error {
+	for {
+		s.mu.Lock()
+		if s.canStartLocked(t) {
+			t.mu.Lock()
+			t.state = TaskStateRunning
+			t.startTime = time.Now()
+			t.mu.Unlock()
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		}
+	}
+}
+
+func (s *Server) canStartLocked(t *serverTask) bool {
+	for _, other := range s.tasks {
+		if other.id >= t.id {
+			break
+		}
+		other.mu.Lock()
+		finished := other.finished()
+		other.mu.Unlock()
+		if !finished {
+			return false
+		}
+	}
+	return true
+}
+
+// removeFinishedLocked removes the earliest finished tasks exceeding the
+// limit.
+func (s *Server) removeFinishedLocked() {
+	finished := 0
+	for _, t := range s.tasks {
+		t.mu.Lock()
+		if t.finished() {
+			finished++
+		}
+		t.mu.Unlock()
+	}
+	tasks := s.tasks[:0]
+	for _, t := range s.tasks {
+		t.mu.Lock()
+		remove := finished > s.maxFinishedTasks && t.finished()
+		t.mu.Unlock()
+		if remove {
+			finished--
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	for i := len(tasks); i < len(s.tasks); i++ {
+		s.tasks[i] = nil
+	}
+	s.tasks = tasks
+}
+
+// notifyLocked wakes up the tasks waiting for their turn.
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("failed to write response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/gluetikv"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/task"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testServerSuite{})
+
+type testServerSuite struct {
+	server  *Server
+	http    *httptest.Server
+	release chan struct{}
+	configs chan interface{}
+}
+
+func (s *testServerSuite) SetUpTest(c *C) {
+	s.server = NewServer(context.Background(), gluetikv.Glue{})
+	s.release = make(chan struct{})
+	s.configs = make(chan interface{}, 10)
+	wait := func(ctx context.Context, g glue.Glue) error {
+		p := g.StartProgress(ctx, "test", 2, true)
+		p.Inc()
+		select {
+		case <-s.release:
+			p.Close()
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.server.runBackup = func(ctx context.Context, g glue.Glue, _ string, cfg *task.BackupConfig) error {
+		s.configs <- cfg
+		return wait(ctx, g)
+	}
+	s.server.runRestore = func(ctx context.Context, g glue.Glue, _ string, cfg *task.RestoreConfig) error {
+		s.configs <- cfg
+		return wait(ctx, g)
+	}
+	s.http = httptest.NewServer(s.server.Handler())
+}
+
+func (s *testServerSuite) TearDownTest(c *C) {
+	s.server.Close()
+	s.http.Close()
+}
+
+func (s *testServerSuite) do(c *C, method, path string, body string) (int, []byte) {
+	req, err := http.NewRequest(method, s.http.URL+path, bytes.NewBufferString(body))
+	c.Assert(err, IsNil)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(resp.Body)
+	c.Assert(err, IsNil)
+	return resp.StatusCode, buf.Bytes()
+}
+
+func (s *testServerSuite) getTask(c *C, id uint64) TaskInfo {
+	status, body := s.do(c, http.MethodGet, fmt.Sprintf("/tasks/%d", id), "")
+	c.Assert(status, Equals, http.StatusOK)
+	var info TaskInfo
+	c.Assert(json.Unmarshal(body, &info), IsNil)
+	return info
+}
+
+func (s *testServerSuite) waitState(c *C, id uint64, state TaskState) TaskInfo {
+	for i := 0; i < 100; i++ {
+		info := s.getTask(c, id)
+		if info.State == state {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("task %d doesn't become %s", id, state)
+	return TaskInfo{}
+}
+
+func (s *testServerSuite) TestSubmitBackup(c *C) {
+	status, body := s.do(c, http.MethodPost, "/tasks/backup",
+		`{"storage": "s3://bucket/prefix?access-key=secret", "filter": ["db.*"], "backup-ts": 42}`)
+	c.Assert(status, Equals, http.StatusOK, Commentf("%s", body))
+	var info TaskInfo
+	c.Assert(json.Unmarshal(body, &info), IsNil)
+	c.Assert(info.ID, Equals, uint64(1))
+	c.Assert(info.Kind, Equals, TaskKindBackup)
+	c.Assert(info.Storage, Equals, "s3://bucket/prefix")
+
+	cfg := (<-s.configs).(*task.BackupConfig)
+	c.Assert(cfg.BackupTS, Equals, uint64(42))
+	// The unspecified fields have the default values of flags.
+	c.Assert(cfg.PD, DeepEquals, []string{"127.0.0.1:2379"})
+	c.Assert(cfg.Checksum, IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("DB", "t"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("db2", "t"), IsFalse)
+
+	info = s.waitState(c, 1, TaskStateRunning)
+	c.Assert(info.Progress, DeepEquals, []ProgressInfo{{Name: "test", Total: 2, Current: 1}})
+	close(s.release)
+	info = s.waitState(c, 1, TaskStateSucceeded)
+	c.Assert(info.Progress, DeepEquals, []ProgressInfo{{Name: "test", Total: 2, Current: 2, Finished: true}})
+
+	status, body = s.do(c, http.MethodGet, "/tasks", "")
+	c.Assert(status, Equals, http.StatusOK)
+	var infos []TaskInfo
+	c.Assert(json.Unmarshal(body, &infos), IsNil)
+	c.Assert(infos, HasLen, 1)
+}
+
+func (s *testServerSuite) TestInvalidRequest(c *C) {
+	status, body := s.do(c, http.MethodPost, "/tasks/backup", `{"storage": "local:///tmp", "unknown": 1}`)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(string(body), Matches, `.*unknown field.*\n`)
+	status, _ = s.do(c, http.MethodPost, "/tasks/restore", `{}`)
+	c.Assert(status, Equals, http.StatusBadRequest)
+	status, _ = s.do(c, http.MethodGet, "/tasks/10", "")
+	c.Assert(status, Equals, http.StatusNotFound)
+	status, _ = s.do(c, http.MethodPost, "/tasks", "")
+	c.Assert(status, Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *testServerSuite) submit(c *C, kind TaskKind, body string) {
+	status, resp := s.do(c, http.MethodPost, "/tasks/"+string(kind), body)
+	c.Assert(status, Equals, http.StatusOK, Commentf("%s", resp))
+}
+
+func (s *testServerSuite) TestQueueAndCancel(c *C) {
+	s.submit(c, TaskKindRestore, `{"storage": "local:///tmp/a", "pd": ["pd1:2379"]}`)
+	// The tasks are run one by one, even on different clusters.
+	s.submit(c, TaskKindBackup, `{"storage": "local:///tmp/b", "pd": ["pd1:2379"]}`)
+	s.submit(c, TaskKindBackup, `{"storage": "local:///tmp/c", "pd": ["pd2:2379"]}`)
+
+	s.waitState(c, 1, TaskStateRunning)
+	c.Assert(s.getTask(c, 2).State, Equals, TaskStatePending)
+	c.Assert(s.getTask(c, 3).State, Equals, TaskStatePending)
+
+	// Cancel a pending task.
+	status, _ := s.do(c, http.MethodDelete, "/tasks/2", "")
+	c.Assert(status, Equals, http.StatusOK)
+	info := s.waitState(c, 2, TaskStateCanceled)
+	c.Assert(info.StartTime, IsNil)
+	c.Assert(s.getTask(c, 3).State, Equals, TaskStatePending)
+
+	// Cancel a running task.
+	status, _ = s.do(c, http.MethodDelete, "/tasks/1", "")
+	c.Assert(status, Equals, http.StatusOK)
+	info = s.waitState(c, 1, TaskStateCanceled)
+	c.Assert(info.Error, Equals, context.Canceled.Error())
+	s.waitState(c, 3, TaskStateRunning)
+
+	close(s.release)
+	s.waitState(c, 3, TaskStateSucceeded)
+}
+
+func (s *testServerSuite) TestRemoveFinishedTasks(c *C) {
+	s.server.maxFinishedTasks = 2
+	close(s.release)
+	for i := 1; i <= 4; i++ {
+		s.submit(c, TaskKindBackup, fmt.Sprintf(`{"storage": "local:///tmp/%d"}`, i))
+		s.waitState(c, uint64(i), TaskStateSucceeded)
+	}
+
+	status, body := s.do(c, http.MethodGet, "/tasks", "")
+	c.Assert(status, Equals, http.StatusOK)
+	var infos []TaskInfo
+	c.Assert(json.Unmarshal(body, &infos), IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].ID, Equals, uint64(3))
+	c.Assert(infos[1].ID, Equals, uint64(4))
+	status, _ = s.do(c, http.MethodGet, "/tasks/1", "")
+	c.Assert(status, Equals, http.StatusNotFound)
+}
+
+func (s *testServerSuite) TestSummaryOfTasksBackToBack(c *C) {
+	reports := make(chan summary.Report, 2)
+	s.server.runBackup = func(ctx context.Context, g glue.Glue, cmdName string, cfg *task.BackupConfig) error {
+		defer summary.Summary(cmdName)
+		summary.SetBackupTS(42)
+		summary.SetClusterVersion("4.0.0")
+		summary.CollectSuccessUnit("range1", 1, time.Second)
+		summary.CollectSuccessUnit(summary.TotalKV, 1, uint64(10))
+		summary.SetSuccessStatus(true)
+		reports <- summary.GetReport(cmdName)
+		return nil
+	}
+	s.server.runRestore = func(ctx context.Context, g glue.Glue, cmdName string, cfg *task.RestoreConfig) error {
+		defer summary.Summary(cmdName)
+		// The restore fails before collecting anything.
+		reports <- summary.GetReport(cmdName)
+		return errors.New("injected")
+	}
+	s.submit(c, TaskKindBackup, `{"storage": "local:///tmp/a"}`)
+	s.waitState(c, 1, TaskStateSucceeded)
+	s.submit(c, TaskKindRestore, `{"storage": "local:///tmp/a"}`)
+	s.waitState(c, 2, TaskStateFailed)
+
+	backup := <-reports
+	c.Assert(backup.Success, IsTrue)
+	c.Assert(backup.SuccessUnits, Equals, 1)
+	restore := <-reports
+	c.Assert(restore.Unit, Equals, summary.RestoreUnit)
+	c.Assert(restore.Success, IsFalse)
+	c.Assert(restore.BackupTS, Equals, uint64(0))
+	c.Assert(restore.ClusterVersion, Equals, "")
+	c.Assert(restore.TotalUnits, Equals, 0)
+	c.Assert(restore.TotalKVs, Equals, uint64(0))
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package server
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pingcap/br/pkg/glue"
+)
+
+// TaskKind is the kind of a task.
+type TaskKind string
+
+// TaskState is the state of a task.
+type TaskState string
+
+// The kinds of tasks.
+const (
+	TaskKindBackup  TaskKind = "backup"
+	TaskKindRestore TaskKind = "restore"
+)
+
+// The states of tasks.
+const (
+	TaskStatePending   TaskState = "pending"
+	TaskStateRunning   TaskState = "running"
+	TaskStateSucceeded TaskState = "succeeded"
+	TaskStateFailed    TaskState = "failed"
+	TaskStateCanceled  TaskState = "canceled"
+)
+
+// TaskInfo is the information of a task returned by the HTTP API.
+type TaskInfo struct {
+	ID         uint64            `json:"id"`
+	Kind       TaskKind          `json:"kind"`
+	Storage    string            `json:"storage"`
+	State      TaskState         `json:"state"`
+	Error      string            `json:"error,omitempty"`
+	CreateTime time.Time         `json:"create-time"`
+	StartTime  *time.Time        `json:"start-time,omitempty"`
+	EndTime    *time.Time        `json:"end-time,omitempty"`
+	Progress   []ProgressInfo    `json:"progress"`
+	Records    map[string]uint64 `json:"records,omitempty"`
+}
+
+// ProgressInfo is the progress of a step of a task.
+type ProgressInfo struct {
+	Name       string `json:"name"`
+	Total      int64  `json:"total"`
+	Current    int64  `json:"current"`
+	TotalBytes uint64 `json:"total-bytes,omitempty"`
+	Bytes      uint64 `json:"bytes"`
+	KVs        uint64 `json:"kvs"`
+	Finished   bool   `json:"finished"`
+}
+
+type taskRunner func(ctx context.Context, g glue.Glue) error
+
+type serverTask struct {
+	id      uint64
+	kind    TaskKind
+	storage string
+	run     taskRunner
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu         sync.Mutex
+	state      TaskState
+	err        error
+	createTime time.Time
+	startTime  time.Time
+	endTime    time.Time
+	progress   []*taskProgress
+	records    map[string]uint64
+}
+
+func (t *serverTask) finished() bool {
+	return t.state != TaskStatePending && t.state != TaskStateRunning
+}
+
+func (t *serverTask) startProgress(name string, total int64) *taskProgress {
+	p := &taskProgress{name: name, total: total}
+	t.mu.Lock()
+	t.progress = append(t.progress, p)
+	t.mu.Unlock()
+	return p
+}
+
+func (t *serverTask) record(name string, value uint64) {
+	t.mu.Lock()
+	t.records[name] = value
+	t.mu.Unlock()
+}
+
+func (t *serverTask) info() TaskInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := TaskInfo{
+		ID:         t.id,
+		Kind:       t.kind,
+		Storage:    hideQuery(t.storage),
+		State:      t.state,
+		CreateTime: t.createTime,
+		Progress:   make([]ProgressInfo, 0, len(t.progress)),
+	}
+	if t.err != nil {
+		info.Error = t.err.Error()
+	}
+	if !t.startTime.IsZero() {
+		startTime := t.startTime
+		info.StartTime = &startTime
+	}
+	if !t.endTime.IsZero() {
+		endTime := t.endTime
+		info.EndTime = &endTime
+	}
+	for _, p := range t.progress {
+		info.Progress = append(info.Progress, p.info())
+	}
+	if len(t.records) != 0 {
+		info.Records = make(map[string]uint64, len(t.records))
+		for k, v := range t.records {
+			info.Records[k] = v
+		}
+	}
+	return info
+}
+
+// hideQuery removes the query of the storage URL, which may contain secrets.
+func hideQuery(storage string) string {
+	u, err := url.Parse(storage)
+	if err != nil {
+		return "<invalid URI>"
+	}
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package sst
+
+import "encoding/binary"
+
+// blockBuilder builds blocks in the same way as RocksDB: the keys share the
+// prefixes with the previous keys except at the restart points.
+type blockBuilder struct {
+	restartInterval int
+	// noValueLen is true for the index blocks whose values are delta encoded.
+	noValueLen bool
+	buf        []byte
+	restarts   []uint32
+	lastKey    []byte
+	counter    int
+}
+
+func (b *blockBuilder) add(key, value []byte) {
+	shared := 0
+	if b.isRestart() {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+	} else {
+		for shared < len(key) && shared < len(b.lastKey) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	}
+	b.counter++
+	b.buf = appendUvarint(b.buf, uint64(shared))
+	b.buf = appendUvarint(b.buf, uint64(len(key)-shared))
+	if !b.noValueLen {
+		b.buf = appendUvarint(b.buf, uint64(len(value)))
+	}
+	b.buf = append(b.buf, key[shared:]...)
+	b.buf = append(b.buf, value...)
+	b.lastKey = append(b.lastKey[:0], key...)
+}
+
+// isRestart returns whether the next key is at a restart point.
+func (b *blockBuilder) isRestart() bool {
+	return b.counter%b.restartInterval == 0
+}
+
+func (b *blockBuilder) finish() []byte {
+	if len(b.restarts) == 0 {
+		b.restarts = append(b.restarts, 0)
+	}
+	for _, restart := range b.restarts {
+		b.buf = appendUint32(b.buf, restart)
+	}
+	return appendUint32(b.buf, uint32(len(b.restarts)))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendBlockHandle(buf []byte, h blockHandle) []byte {
+	return appendUvarint(appendUvarint(buf, h.offset), h.size)
+}
@@ -0,0 +1,512 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package sst
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"github.com/pingcap/errors"
+)
+
+// The magic numbers of the block-based table format.
+const (
+	magicNumber       uint64 = 0x88e241b785f4cff7
+	legacyMagicNumber uint64 = 0xdb4775248b80fb57
+)
+
+const (
+	// footerLen is the length of the footer since format version 1:
+	// checksum type (1), metaindex and index handles (40), format version (4)
+	// and magic number (8).
+	footerLen = 53
+	// legacyFooterLen is the length of the footer of format version 0:
+	// metaindex and index handles (40) and magic number (8).
+	legacyFooterLen = 48
+	// blockHandlesLen is the length of the padded block handles in footers.
+	blockHandlesLen = 40
+	// blockTrailerLen is the length of the trailer of blocks: compression type
+	// (1) and checksum (4).
+	blockTrailerLen = 5
+	// maxFormatVersion is the latest format version supported.
+	maxFormatVersion = 5
+)
+
+// The checksum types of blocks.
+const (
+	checksumNone     = 0
+	checksumCRC32C   = 1
+	checksumXXHash64 = 3
+)
+
+// The compression types of blocks.
+const (
+	compressionNone     = 0x0
+	compressionSnappy   = 0x1
+	compressionZlib     = 0x2
+	compressionBZip2    = 0x3
+	compressionLZ4      = 0x4
+	compressionLZ4HC    = 0x5
+	compressionZSTD     = 0x7
+	compressionZSTDBeta = 0x40
+)
+
+// The types of index blocks.
+const (
+	indexTypeBinarySearch                = 0
+	indexTypeHashSearch                  = 1
+	indexTypeTwoLevel                    = 2
+	indexTypeBinarySearchFirstKey        = 3
+	dataBlockHashIndexFlag        uint32 = 1 << 31
+)
+
+// The names of the meta blocks and table properties.
+const (
+	propertiesBlockName       = "rocksdb.properties"
+	propNumEntries            = "rocksdb.num.entries"
+	propIndexType             = "rocksdb.block.based.table.index.type"
+	propIndexValueIsDeltaCode = "rocksdb.index.value.is.delta.encoded"
+)
+
+// valueTypeValue is the type of internal keys which are put.
+const valueTypeValue = 0x1
+
+// internalKeyTrailerLen is the length of the sequence number and the value
+// type appended to the user keys.
+const internalKeyTrailerLen = 8
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder
+	errZSTDDecoder  error
+)
+
+type blockHandle struct {
+	offset uint64
+	size   uint64
+}
+
+func decodeBlockHandle(data []byte) (blockHandle, int, error) {
+	offset, n := binary.Uvarint(data)
+	if n <= 0 {
+		return blockHandle{}, 0, errors.New("invalid block handle")
+	}
+	size, m := binary.Uvarint(data[n:])
+	if m <= 0 {
+		return blockHandle{}, 0, errors.New("invalid block handle")
+	}
+	return blockHandle{offset: offset, size: size}, n + m, nil
+}
+
+// Reader reads the key-value pairs in an SST file, which is a RocksDB table of
+// the block-based format, e.g. the files written by TiKV backup.
+type Reader struct {
+	data          []byte
+	formatVersion uint32
+	checksumType  byte
+	index         blockHandle
+	props         map[string][]byte
+}
+
+// NewReader parses the footer and the properties of the SST file.
+func NewReader(data []byte) (*Reader, error) {
+	if len(data) < legacyFooterLen {
+		return nil, errors.Errorf("file is too short to be an sst file: %d bytes", len(data))
+	}
+	r := &Reader{data: data}
+	var handles []byte
+	switch magic := binary.LittleEndian.Uint64(data[len(data)-8:]); magic {
+	case legacyMagicNumber:
+		footer := data[len(data)-legacyFooterLen:]
+		r.checksumType = checksumCRC32C
+		handles = footer[:blockHandlesLen]
+	case magicNumber:
+		if len(data) < footerLen {
+			return nil, errors.Errorf("file is too short to be an sst file: %d bytes", len(data))
+		}
+		footer := data[len(data)-footerLen:]
+		r.checksumType = footer[0]
+		handles = footer[1 : 1+blockHandlesLen]
+		r.formatVersion = binary.LittleEndian.Uint32(footer[1+blockHandlesLen:])
+		if r.formatVersion > maxFormatVersion {
+			return nil, errors.Errorf("unsupported format version %d", r.formatVersion)
+		}
+	default:
+		return nil, errors.Errorf("not a block-based table, magic number is %x", magic)
+	}
+	metaIndex, n, err := decodeBlockHandle(handles)
+	if err != nil {
+		return nil, errors.Annotate(err, "invalid metaindex handle")
+	}
+	if r.index, _, err = decodeBlockHandle(handles[n:]); err != nil {
+		return nil, errors.Annotate(err, "invalid index handle")
+	}
+
+	r.props = make(map[string][]byte)
+	metaIndexBlock, err := r.readBlock(metaIndex)
+	if err != nil {
+		return nil, errors.Annotate(err, "read metaindex block failed")
+	}
+	err = iterateBlock(metaIndexBlock, func(key, value []byte) error {
+		if string(key) != propertiesBlockName {
+			return nil
+		}
+		handle, _, err := decodeBlockHandle(value)
+		if err != nil {
+			return errors.Annotate(err, "invalid properties handle")
+		}
+		propsBlock, err := r.readBlock(handle)
+		if err != nil {
+			return errors.Annotate(err, "read properties block failed")
+		}
+		return iterateBlock(propsBlock, func(key, value []byte) error {
+			r.props[string(key)] = append([]byte(nil), value...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NumEntries returns the number of entries recorded in the properties.
+func (r *Reader) NumEntries() (uint64, bool) {
+	return r.uintProperty(propNumEntries)
+}
+
+func (r *Reader) uintProperty(name string) (uint64, bool) {
+	value, ok := r.props[name]
+	if !ok {
+		return 0, false
+	}
+	n, m := binary.Uvarint(value)
+	return n, m > 0
+}
+
+// Iterate calls fn with the user keys and values in the file in order. The
+// key and value are only valid until fn returns. Only the key-value pairs put
+// into the file are supported, an error is returned if there are other kinds
+// of entries, e.g. deletions.
+func (r *Reader) Iterate(fn func(key, value []byte) error) error {
+	indexType := uint32(indexTypeBinarySearch)
+	if value, ok := r.props[propIndexType]; ok && len(value) >= 4 {
+		indexType = binary.LittleEndian.Uint32(value)
+	}
+	deltaEncoded, _ := r.uintProperty(propIndexValueIsDeltaCode)
+	hasFirstKey := indexType == indexTypeBinarySearchFirstKey
+
+	var dataBlocks []blockHandle
+	switch indexType {
+	case indexTypeBinarySearch, indexTypeHashSearch, indexTypeBinarySearchFirstKey:
+		index, err := r.readBlock(r.index)
+		if err != nil {
+			return errors.Annotate(err, "read index block failed")
+		}
+		if dataBlocks, err = indexHandles(index, deltaEncoded != 0, hasFirstKey); err != nil {
+			return errors.Annotate(err, "invalid index block")
+		}
+	case indexTypeTwoLevel:
+		// The top level index points to the partitions of the index, which
+		// have the same format as the index of binary search.
+		topLevel, err := r.readBlock(r.index)
+		if err != nil {
+			return errors.Annotate(err, "read index block failed")
+		}
+		partitions, err := indexHandles(topLevel, deltaEncoded != 0, false)
+		if err != nil {
+			return errors.Annotate(err, "invalid index block")
+		}
+		for _, partition := range partitions {
+			index, err := r.readBlock(partition)
+			if err != nil {
+				return errors.Annotate(err, "read index partition failed")
+			}
+			handles, err := indexHandles(index, deltaEncoded != 0, hasFirstKey)
+			if err != nil {
+				return errors.Annotate(err, "invalid index partition")
+			}
+			dataBlocks = append(dataBlocks, handles...)
+		}
+	default:
+		return errors.Errorf("unsupported index type %d", indexType)
+	}
+
+	for _, handle := range dataBlocks {
+		block, err := r.readBlock(handle)
+		if err != nil {
+			return errors.Annotate(err, "read data block failed")
+		}
+		err = iterateBlock(block, func(key, value []byte) error {
+			if len(key) < internalKeyTrailerLen {
+				return errors.Errorf("invalid internal key %x", key)
+			}
+			userKey := key[:len(key)-internalKeyTrailerLen]
+			if tp := key[len(key)-internalKeyTrailerLen]; tp != valueTypeValue {
+				return errors.Errorf("unsupported value type %d of key %x", tp, userKey)
+			}
+			return fn(userKey, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBlock verifies the checksum of the block and decompresses it.
+func (r *Reader) readBlock(handle blockHandle) ([]byte, error) {
+	end := handle.offset + handle.size + blockTrailerLen
+	if end < handle.offset || end > uint64(len(r.data)) {
+		return nil, errors.Errorf("block [%d, %d) is out of the file", handle.offset, end)
+	}
+	block := r.data[handle.offset : handle.offset+handle.size]
+	trailer := r.data[handle.offset+handle.size : end]
+	if err := r.verifyBlockChecksum(r.data[handle.offset:end-4], binary.LittleEndian.Uint32(trailer[1:])); err != nil {
+		return nil, errors.Annotatef(err, "block at %d is corrupted", handle.offset)
+	}
+	return decompress(trailer[0], block, r.formatVersion)
+}
+
+// blockChecksum returns the masked crc32c of the block content followed by
+// the compression type.
+func blockChecksum(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32cTable)
+	// See util/crc32c.h of RocksDB.
+	return ((crc >> 15) | (crc << 17)) + 0xa282ead8
+}
+
+// verifyBlockChecksum verifies the checksum of the block content followed by
+// the compression type.
+func (r *Reader) verifyBlockChecksum(data []byte, expected uint32) error {
+	var actual uint32
+	switch r.checksumType {
+	case checksumNone:
+		return nil
+	case checksumCRC32C:
+		actual = blockChecksum(data)
+	case checksumXXHash64:
+		actual = uint32(xxhash.Sum64(data))
+	default:
+		return errors.Errorf("unsupported checksum type %d", r.checksumType)
+	}
+	if actual != expected {
+		return errors.Errorf("checksum mismatch, calculated %x, expected %x", actual, expected)
+	}
+	return nil
+}
+
+func decompress(compression byte, data []byte, formatVersion uint32) ([]byte, error) {
+	switch compression {
+	case compressionNone:
+		return data, nil
+	case compressionSnappy:
+		decoded, err := snappy.Decode(nil, data)
+		return decoded, errors.Trace(err)
+	}
+
+	// The decompressed size is prefixed since format version 2.
+	var size uint64
+	if formatVersion >= 2 {
+		var n int
+		if size, n = binary.Uvarint(data); n <= 0 || size > 1<<32 {
+			return nil, errors.New("invalid decompressed size")
+		}
+		data = data[n:]
+	} else if compression == compressionLZ4 || compression == compressionLZ4HC {
+		if len(data) < 8 {
+			return nil, errors.New("invalid decompressed size")
+		}
+		size = uint64(binary.LittleEndian.Uint32(data))
+		data = data[8:]
+	}
+
+	switch compression {
+	case compressionZlib:
+		// RocksDB writes raw deflate streams without zlib headers.
+		decoded, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+		return decoded, errors.Trace(err)
+	case compressionBZip2:
+		decoded, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(data)))
+		return decoded, errors.Trace(err)
+	case compressionLZ4, compressionLZ4HC:
+		decoded := make([]byte, size)
+		n, err := lz4.UncompressBlock(data, decoded)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if uint64(n) != size {
+			return nil, errors.Errorf("decompressed %d bytes, expected %d bytes", n, size)
+		}
+		return decoded, nil
+	case compressionZSTD, compressionZSTDBeta:
+		zstdDecoderOnce.Do(func() {
+			zstdDecoder, errZSTDDecoder = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		})
+		if errZSTDDecoder != nil {
+			return nil, errors.Trace(errZSTDDecoder)
+		}
+		decoded, err := zstdDecoder.DecodeAll(data, make([]byte, 0, size))
+		return decoded, errors.Trace(err)
+	default:
+		return nil, errors.Errorf("unsupported compression type %d", compression)
+	}
+}
+
+// blockEntries returns the entries and the offsets of the restart points of
+// the block.
+func blockEntries(block []byte) ([]byte, []uint32, error) {
+	if len(block) < 4 {
+		return nil, nil, errors.New("block is too short")
+	}
+	end := len(block) - 4
+	numRestarts := binary.LittleEndian.Uint32(block[end:])
+	if numRestarts&dataBlockHashIndexFlag != 0 {
+		// Skip the hash index of data blocks: the buckets followed by the
+		// number of buckets.
+		numRestarts &^= dataBlockHashIndexFlag
+		if end < 2 {
+			return nil, nil, errors.New("block is too short")
+		}
+		end -= 2 + int(binary.LittleEndian.Uint16(block[end-2:]))
+	}
+	restartsOffset := end - 4*int(numRestarts)
+	if restartsOffset < 0 {
+		return nil, nil, errors.New("invalid number of restart points")
+	}
+	restarts := make([]uint32, numRestarts)
+	for i := range restarts {
+		restarts[i] = binary.LittleEndian.Uint32(block[restartsOffset+4*i:])
+	}
+	return block[:restartsOffset], restarts, nil
+}
+
+// decodeEntryHeader decodes the shared and non-shared lengths of the key, and
+// the length of the value if hasValueLen is true.
+func decodeEntryHeader(entries []byte, hasValueLen bool) (shared, nonShared, valueLen uint64, n int, err error) {
+	var m int
+	if shared, m = binary.Uvarint(entries); m <= 0 {
+		return 0, 0, 0, 0, errors.New("invalid block entry")
+	}
+	n += m
+	if nonShared, m = binary.Uvarint(entries[n:]); m <= 0 {
+		return 0, 0, 0, 0, errors.New("invalid block entry")
+	}
+	n += m
+	if hasValueLen {
+		if valueLen, m = binary.Uvarint(entries[n:]); m <= 0 {
+			return 0, 0, 0, 0, errors.New("invalid block entry")
+		}
+		n += m
+	}
+	return shared, nonShared, valueLen, n, nil
+}
+
+// iterateBlock calls fn with the keys and values in the block.
+func iterateBlock(block []byte, fn func(key, value []byte) error) error {
+	entries, _, err := blockEntries(block)
+	if err != nil {
+		return err
+	}
+	var key []byte
+	for len(entries) > 0 {
+		shared, nonShared, valueLen, n, err := decodeEntryHeader(entries, true)
+		if err != nil {
+			return err
+		}
+		entries = entries[n:]
+		if shared > uint64(len(key)) || nonShared+valueLen > uint64(len(entries)) {
+			return errors.New("invalid block entry")
+		}
+		key = append(key[:shared], entries[:nonShared]...)
+		value := entries[nonShared : nonShared+valueLen]
+		entries = entries[nonShared+valueLen:]
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexHandles returns the block handles in the index block. If the values
+// are delta encoded, the entries have no value lengths, and only the handles
+// at the restart points are fully encoded, the others are encoded as the size
+// deltas to the previous handles.
+func indexHandles(block []byte, deltaEncoded, hasFirstKey bool) ([]blockHandle, error) {
+	if !deltaEncoded {
+		var handles []blockHandle
+		err := iterateBlock(block, func(_, value []byte) error {
+			handle, _, err := decodeBlockHandle(value)
+			if err != nil {
+				return err
+			}
+			handles = append(handles, handle)
+			return nil
+		})
+		return handles, err
+	}
+
+	entries, restarts, err := blockEntries(block)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		handles   []blockHandle
+		offset    = 0
+		nextStart = 0
+	)
+	for offset < len(entries) {
+		isRestart := nextStart < len(restarts) && uint32(offset) == restarts[nextStart]
+		if isRestart {
+			nextStart++
+		}
+		_, nonShared, _, n, err := decodeEntryHeader(entries[offset:], false)
+		if err != nil {
+			return nil, err
+		}
+		offset += n + int(nonShared)
+		if offset > len(entries) {
+			return nil, errors.New("invalid block entry")
+		}
+
+		var handle blockHandle
+		if isRestart || len(handles) == 0 {
+			if handle, n, err = decodeBlockHandle(entries[offset:]); err != nil {
+				return nil, err
+			}
+		} else {
+			delta, m := binary.Varint(entries[offset:])
+			if m <= 0 {
+				return nil, errors.New("invalid block handle delta")
+			}
+			n = m
+			prev := handles[len(handles)-1]
+			handle = blockHandle{
+				offset: prev.offset + prev.size + blockTrailerLen,
+				size:   uint64(int64(prev.size) + delta),
+			}
+		}
+		offset += n
+		if hasFirstKey {
+			// Skip the first key of the block.
+			keyLen, m := binary.Uvarint(entries[offset:])
+			if m <= 0 {
+				return nil, errors.New("invalid first key")
+			}
+			offset += m + int(keyLen)
+		}
+		handles = append(handles, handle)
+	}
+	return handles, nil
+}
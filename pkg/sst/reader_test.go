@@ -0,0 +1,352 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package sst
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	. "github.com/pingcap/check"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+
+	"github.com/pingcap/br/pkg/sst/ssttest"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testReaderSuite{})
+
+type testReaderSuite struct{}
+
+type kv struct {
+	key, value []byte
+}
+
+func mockKVs(n int) []kv {
+	kvs := make([]kv, 0, n)
+	for i := 0; i < n; i++ {
+		kvs = append(kvs, kv{
+			key:   []byte(fmt.Sprintf("key-%08d", i)),
+			value: bytes.Repeat([]byte{byte(i)}, i%50),
+		})
+	}
+	return kvs
+}
+
+// sstBuilder builds SST files in the block-based format of RocksDB.
+type sstBuilder struct {
+	c             *C
+	formatVersion uint32
+	legacyFooter  bool
+	checksumType  byte
+	compression   byte
+	blockSize     int
+	// indexRestartInterval is used for the delta encoded index.
+	indexRestartInterval int
+	deltaEncodedIndex    bool
+	valueType            byte
+
+	buf bytes.Buffer
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func (b *sstBuilder) compress(data []byte) []byte {
+	var compressed []byte
+	switch b.compression {
+	case compressionNone:
+		return data
+	case compressionSnappy:
+		return snappy.Encode(nil, data)
+	case compressionZlib:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		b.c.Assert(err, IsNil)
+		_, err = w.Write(data)
+		b.c.Assert(err, IsNil)
+		b.c.Assert(w.Close(), IsNil)
+		compressed = buf.Bytes()
+	case compressionLZ4:
+		compressed = make([]byte, lz4.CompressBlockBound(len(data)))
+		n, err := lz4.CompressBlock(data, compressed, make([]int, 1<<16))
+		b.c.Assert(err, IsNil)
+		b.c.Assert(n, Greater, 0)
+		compressed = compressed[:n]
+	case compressionZSTD:
+		encoder, err := zstd.NewWriter(nil)
+		b.c.Assert(err, IsNil)
+		compressed = encoder.EncodeAll(data, nil)
+		b.c.Assert(encoder.Close(), IsNil)
+	}
+	var header []byte
+	switch {
+	case b.formatVersion >= 2:
+		header = appendUvarint(nil, uint64(len(data)))
+	case b.compression == compressionLZ4:
+		header = appendUint32(appendUint32(nil, uint32(len(data))), 0)
+	}
+	return append(header, compressed...)
+}
+
+func (b *sstBuilder) writeBlock(data []byte, compression byte) blockHandle {
+	handle := blockHandle{offset: uint64(b.buf.Len()), size: uint64(len(data))}
+	b.buf.Write(data)
+	b.buf.WriteByte(compression)
+	content := b.buf.Bytes()[handle.offset:]
+	var checksum uint32
+	switch b.checksumType {
+	case checksumCRC32C:
+		checksum = blockChecksum(content)
+	case checksumXXHash64:
+		checksum = uint32(xxhash.Sum64(content))
+	}
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], checksum)
+	b.buf.Write(tmp[:])
+	return handle
+}
+
+func (b *sstBuilder) build(kvs []kv) []byte {
+	if b.blockSize == 0 {
+		b.blockSize = 256
+	}
+	if b.indexRestartInterval == 0 {
+		b.indexRestartInterval = 1
+	}
+	if b.valueType == 0 {
+		b.valueType = valueTypeValue
+	}
+	index := &blockBuilder{restartInterval: b.indexRestartInterval, noValueLen: b.deltaEncodedIndex}
+	var lastHandle blockHandle
+	addIndex := func(key []byte, handle blockHandle) {
+		var value []byte
+		if b.deltaEncodedIndex && !index.isRestart() {
+			value = appendVarint(nil, int64(handle.size)-int64(lastHandle.size))
+		} else {
+			value = appendBlockHandle(nil, handle)
+		}
+		index.add(key, value)
+		lastHandle = handle
+	}
+
+	data := &blockBuilder{restartInterval: 4}
+	for i, pair := range kvs {
+		key := append(append([]byte(nil), pair.key...), b.valueType, 0, 0, 0, 0, 0, 0, 0)
+		data.add(key, pair.value)
+		if len(data.buf) >= b.blockSize || i == len(kvs)-1 {
+			handle := b.writeBlock(b.compress(data.finish()), b.compression)
+			addIndex(key, handle)
+			data = &blockBuilder{restartInterval: 4}
+		}
+	}
+
+	props := &blockBuilder{restartInterval: 1}
+	props.add([]byte(propIndexType), appendUint32(nil, indexTypeBinarySearch))
+	if b.deltaEncodedIndex {
+		props.add([]byte(propIndexValueIsDeltaCode), appendUvarint(nil, 1))
+	}
+	props.add([]byte(propNumEntries), appendUvarint(nil, uint64(len(kvs))))
+	propsHandle := b.writeBlock(props.finish(), compressionNone)
+	metaIndex := &blockBuilder{restartInterval: 1}
+	metaIndex.add([]byte(propertiesBlockName), appendBlockHandle(nil, propsHandle))
+	metaIndexHandle := b.writeBlock(metaIndex.finish(), compressionNone)
+	indexHandle := b.writeBlock(index.finish(), compressionNone)
+
+	handles := appendBlockHandle(appendBlockHandle(nil, metaIndexHandle), indexHandle)
+	handles = append(handles, make([]byte, blockHandlesLen-len(handles))...)
+	var magic [8]byte
+	if b.legacyFooter {
+		b.buf.Write(handles)
+		binary.LittleEndian.PutUint64(magic[:], legacyMagicNumber)
+	} else {
+		b.buf.WriteByte(b.checksumType)
+		b.buf.Write(handles)
+		b.buf.Write(appendUint32(nil, b.formatVersion))
+		binary.LittleEndian.PutUint64(magic[:], magicNumber)
+	}
+	b.buf.Write(magic[:])
+	return b.buf.Bytes()
+}
+
+func readAll(c *C, data []byte) []kv {
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+	var kvs []kv
+	err = r.Iterate(func(key, value []byte) error {
+		kvs = append(kvs, kv{key: append([]byte(nil), key...), value: append([]byte(nil), value...)})
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return kvs
+}
+
+func assertKVs(c *C, actual, expected []kv) {
+	c.Assert(actual, HasLen, len(expected))
+	for i := range expected {
+		c.Assert(actual[i].key, BytesEquals, expected[i].key)
+		c.Assert(actual[i].value, BytesEquals, expected[i].value)
+	}
+}
+
+func (s *testReaderSuite) TestCompressions(c *C) {
+	kvs := mockKVs(100)
+	for _, compression := range []byte{
+		compressionNone, compressionSnappy, compressionZlib, compressionLZ4, compressionZSTD,
+	} {
+		for _, formatVersion := range []uint32{1, 2} {
+			if compression == compressionZSTD && formatVersion < 2 {
+				continue
+			}
+			comment := Commentf("compression %d, format version %d", compression, formatVersion)
+			b := &sstBuilder{c: c, formatVersion: formatVersion, checksumType: checksumCRC32C, compression: compression}
+			data := b.build(kvs)
+			r, err := NewReader(data)
+			c.Assert(err, IsNil, comment)
+			n, ok := r.NumEntries()
+			c.Assert(ok, IsTrue, comment)
+			c.Assert(n, Equals, uint64(len(kvs)), comment)
+			assertKVs(c, readAll(c, data), kvs)
+		}
+	}
+}
+
+func (s *testReaderSuite) TestFormats(c *C) {
+	kvs := mockKVs(100)
+	cases := []*sstBuilder{
+		{legacyFooter: true, checksumType: checksumCRC32C, compression: compressionSnappy},
+		{formatVersion: 2, checksumType: checksumXXHash64, compression: compressionLZ4},
+		{formatVersion: 2, checksumType: checksumNone},
+		{formatVersion: 4, checksumType: checksumCRC32C, deltaEncodedIndex: true, indexRestartInterval: 3},
+		{formatVersion: 4, checksumType: checksumCRC32C, deltaEncodedIndex: true, blockSize: 1 << 20},
+	}
+	for _, b := range cases {
+		b.c = c
+		assertKVs(c, readAll(c, b.build(kvs)), kvs)
+	}
+	b := &sstBuilder{c: c, formatVersion: 2, checksumType: checksumCRC32C}
+	assertKVs(c, readAll(c, b.build(nil)), nil)
+}
+
+func (s *testReaderSuite) TestCorruption(c *C) {
+	kvs := mockKVs(100)
+	b := &sstBuilder{c: c, formatVersion: 2, checksumType: checksumCRC32C, compression: compressionLZ4}
+	data := b.build(kvs)
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[10] ^= 0xff
+	r, err := NewReader(corrupted)
+	c.Assert(err, IsNil)
+	err = r.Iterate(func(key, value []byte) error { return nil })
+	c.Assert(err, ErrorMatches, "read data block failed: block at 0 is corrupted: checksum mismatch.*")
+
+	corrupted = append([]byte(nil), data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, err = NewReader(corrupted)
+	c.Assert(err, ErrorMatches, "not a block-based table.*")
+
+	_, err = NewReader(data[:10])
+	c.Assert(err, ErrorMatches, "file is too short.*")
+
+	// The deletions are not supported.
+	b = &sstBuilder{c: c, formatVersion: 2, checksumType: checksumCRC32C, valueType: 0x7}
+	r, err = NewReader(b.build(kvs))
+	c.Assert(err, IsNil)
+	err = r.Iterate(func(key, value []byte) error { return nil })
+	c.Assert(err, ErrorMatches, "unsupported value type 7 of key 6b65792d3030303030303030")
+
+	// The error returned by fn stops the iteration.
+	r, err = NewReader(data)
+	c.Assert(err, IsNil)
+	count := 0
+	err = r.Iterate(func(key, value []byte) error {
+		count++
+		return fmt.Errorf("stop")
+	})
+	c.Assert(err, ErrorMatches, "stop")
+	c.Assert(count, Equals, 1)
+}
+
+func (s *testReaderSuite) TestWriter(c *C) {
+	kvs := mockKVs(1000)
+	w := ssttest.NewWriter()
+	for _, pair := range kvs {
+		c.Assert(w.Add(pair.key, pair.value), IsNil)
+	}
+	c.Assert(w.Add(kvs[0].key, nil), ErrorMatches, "keys must be added in ascending order.*")
+	data := w.Finish()
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+	n, ok := r.NumEntries()
+	c.Assert(ok, IsTrue)
+	c.Assert(n, Equals, uint64(len(kvs)))
+	assertKVs(c, readAll(c, data), kvs)
+
+	assertKVs(c, readAll(c, ssttest.NewWriter().Finish()), nil)
+}
+
+// readFixture reads the SST file in testdata, see testdata/README.md.
+func readFixture(c *C, name string) (*Reader, []kv) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	c.Assert(err, IsNil)
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+	return r, readAll(c, data)
+}
+
+func (s *testReaderSuite) TestTiKVFixtures(c *C) {
+	const startTS, commitTS = 417000000000000000, 417000000000000001
+	rowKey := func(handle int64) []byte {
+		return tablecodec.EncodeRowKeyWithHandle(42, tidbkv.IntHandle(handle))
+	}
+
+	r, defaultKVs := readFixture(c, "default.sst")
+	n, ok := r.NumEntries()
+	c.Assert(ok, IsTrue)
+	c.Assert(n, Equals, uint64(20))
+	c.Assert(defaultKVs, HasLen, 20)
+	for i, pair := range defaultKVs {
+		handle := int64(i+1) * 10
+		key, ts, err := utils.DecodeMVCCKey(pair.key)
+		c.Assert(err, IsNil)
+		c.Assert(key, BytesEquals, rowKey(handle))
+		c.Assert(ts, Equals, uint64(startTS))
+		c.Assert(pair.value, BytesEquals, bytes.Repeat([]byte{byte(handle)}, 300))
+	}
+
+	r, writeKVs := readFixture(c, "write.sst")
+	n, ok = r.NumEntries()
+	c.Assert(ok, IsTrue)
+	c.Assert(n, Equals, uint64(200))
+	c.Assert(writeKVs, HasLen, 200)
+	for i, pair := range writeKVs {
+		handle := int64(i + 1)
+		key, ts, err := utils.DecodeMVCCKey(pair.key)
+		c.Assert(err, IsNil)
+		c.Assert(key, BytesEquals, rowKey(handle))
+		c.Assert(ts, Equals, uint64(commitTS))
+		write, err := utils.ParseWrite(pair.value)
+		c.Assert(err, IsNil)
+		c.Assert(write.Type, Equals, utils.WriteTypePut)
+		c.Assert(write.StartTS, Equals, uint64(startTS))
+		c.Assert(write.HasShortValue, Equals, handle%10 != 0)
+		if write.HasShortValue {
+			c.Assert(write.ShortValue, BytesEquals, bytes.Repeat([]byte{byte(handle)}, int(handle%50)))
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+// Package ssttest writes SST files for tests, it is not used by BR itself.
+// The files are only as complete as the tests need, e.g. they have no filter
+// block and are not compressed, so the tests reading the files written by
+// TiKV use the files in pkg/sst/testdata instead.
+package ssttest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/pingcap/errors"
+)
+
+const (
+	writerBlockSize       = 4096
+	writerRestartInterval = 16
+	writerFormatVersion   = 2
+
+	magicNumber           uint64 = 0x88e241b785f4cff7
+	blockHandlesLen              = 40
+	checksumCRC32C               = 1
+	compressionNone              = 0x0
+	indexTypeBinarySearch        = 0
+	valueTypeValue               = 0x1
+
+	propertiesBlockName = "rocksdb.properties"
+	propNumEntries      = "rocksdb.num.entries"
+	propIndexType       = "rocksdb.block.based.table.index.type"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type blockHandle struct {
+	offset uint64
+	size   uint64
+}
+
+// Writer writes key-value pairs into an SST file of the block-based format,
+// which can be read by sst.Reader and RocksDB. The blocks are not compressed.
+type Writer struct {
+	buf     bytes.Buffer
+	data    *blockBuilder
+	index   *blockBuilder
+	lastKey []byte
+	entries uint64
+}
+
+// NewWriter creates a Writer.
+func NewWriter() *Writer {
+	return &Writer{
+		data:  &blockBuilder{restartInterval: writerRestartInterval},
+		index: &blockBuilder{restartInterval: 1},
+	}
+}
+
+// Add adds a key-value pair, the keys must be added in ascending order.
+func (w *Writer) Add(key, value []byte) error {
+	if w.entries > 0 && bytes.Compare(key, w.lastKey) <= 0 {
+		return errors.Errorf("keys must be added in ascending order, %x is added after %x", key, w.lastKey)
+	}
+	// The sequence numbers are 0, the same as the files ingested.
+	internalKey := append(append([]byte(nil), key...), valueTypeValue, 0, 0, 0, 0, 0, 0, 0)
+	w.data.add(internalKey, value)
+	w.lastKey = append(w.lastKey[:0], key...)
+	w.entries++
+	if len(w.data.buf) >= writerBlockSize {
+		w.flushDataBlock()
+	}
+	return nil
+}
+
+func (w *Writer) flushDataBlock() {
+	if w.data.counter == 0 {
+		return
+	}
+	lastKey := w.data.lastKey
+	handle := w.writeBlock(w.data.finish())
+	w.index.add(lastKey, appendBlockHandle(nil, handle))
+	w.data = &blockBuilder{restartInterval: writerRestartInterval}
+}
+
+func (w *Writer) writeBlock(block []byte) blockHandle {
+	handle := blockHandle{offset: uint64(w.buf.Len()), size: uint64(len(block))}
+	w.buf.Write(block)
+	w.buf.WriteByte(compressionNone)
+	w.buf.Write(appendUint32(nil, blockChecksum(w.buf.Bytes()[handle.offset:])))
+	return handle
+}
+
+// Finish writes the index and the footer, and returns the content of the file.
+func (w *Writer) Finish() []byte {
+	w.flushDataBlock()
+	props := &blockBuilder{restartInterval: 1}
+	props.add([]byte(propIndexType), appendUint32(nil, indexTypeBinarySearch))
+	props.add([]byte(propNumEntries), appendUvarint(nil, w.entries))
+	propsHandle := w.writeBlock(props.finish())
+	metaIndex := &blockBuilder{restartInterval: 1}
+	metaIndex.add([]byte(propertiesBlockName), appendBlockHandle(nil, propsHandle))
+	metaIndexHandle := w.writeBlock(metaIndex.finish())
+	indexHandle := w.writeBlock(w.index.finish())
+
+	w.buf.WriteByte(checksumCRC32C)
+	handles := appendBlockHandle(appendBlockHandle(nil, metaIndexHandle), indexHandle)
+	w.buf.Write(handles)
+	w.buf.Write(make([]byte, blockHandlesLen-len(handles)))
+	w.buf.Write(appendUint32(nil, writerFormatVersion))
+	var magic [8]byte
+	binary.LittleEndian.PutUint64(magic[:], magicNumber)
+	w.buf.Write(magic[:])
+	return w.buf.Bytes()
+}
+
+// blockChecksum returns the masked crc32c of the block content followed by
+// the compression type.
+func blockChecksum(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32cTable)
+	// See util/crc32c.h of RocksDB.
+	return ((crc >> 15) | (crc << 17)) + 0xa282ead8
+}
+
+// blockBuilder builds blocks in the same way as RocksDB: the keys share the
+// prefixes with the previous keys except at the restart points.
+type blockBuilder struct {
+	restartInterval int
+	// noValueLen is true for the index blocks whose values are delta encoded.
+	noValueLen bool
+	buf        []byte
+	restarts   []uint32
+	lastKey    []byte
+	counter    int
+}
+
+func (b *blockBuilder) add(key, value []byte) {
+	shared := 0
+	if b.isRestart() {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+	} else {
+		for shared < len(key) && shared < len(b.lastKey) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	}
+	b.counter++
+	b.buf = appendUvarint(b.buf, uint64(shared))
+	b.buf = appendUvarint(b.buf, uint64(len(key)-shared))
+	if !b.noValueLen {
+		b.buf = appendUvarint(b.buf, uint64(len(value)))
+	}
+	b.buf = append(b.buf, key[shared:]...)
+	b.buf = append(b.buf, value...)
+	b.lastKey = append(b.lastKey[:0], key...)
+}
+
+// isRestart returns whether the next key is at a restart point.
+func (b *blockBuilder) isRestart() bool {
+	return b.counter%b.restartInterval == 0
+}
+
+func (b *blockBuilder) finish() []byte {
+	if len(b.restarts) == 0 {
+		b.restarts = append(b.restarts, 0)
+	}
+	for _, restart := range b.restarts {
+		b.buf = appendUint32(b.buf, restart)
+	}
+	return appendUint32(b.buf, uint32(len(b.restarts)))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendBlockHandle(buf []byte, h blockHandle) []byte {
+	return appendUvarint(appendUvarint(buf, h.offset), h.size)
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+//go:build ignore
+// +build ignore
+
+// gen_fixtures writes the SST files of the default and write CFs in the layout
+// of the files written by TiKV backup, see README.md. Run it in this directory
+// by `go run gen_fixtures.go`.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+)
+
+const (
+	tableID     = 42
+	rows        = 200
+	startTS     = 417000000000000000
+	commitTS    = startTS + 1
+	blockSize   = 4096
+	restartIntv = 16
+
+	compressionLZ4  = 0x4
+	compressionZSTD = 0x7
+	formatVersion   = 2
+	magicNumber     = 0x88e241b785f4cff7
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type blockHandle struct{ offset, size uint64 }
+
+func putUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func putUint32(buf []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func putHandle(buf []byte, h blockHandle) []byte {
+	return putUvarint(putUvarint(buf, h.offset), h.size)
+}
+
+type block struct {
+	restartIntv int
+	buf         []byte
+	restarts    []uint32
+	lastKey     []byte
+	n           int
+}
+
+func (b *block) add(key, value []byte) {
+	shared := 0
+	if b.n%b.restartIntv == 0 {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+	} else {
+		for shared < len(key) && shared < len(b.lastKey) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	}
+	b.n++
+	b.buf = putUvarint(b.buf, uint64(shared))
+	b.buf = putUvarint(b.buf, uint64(len(key)-shared))
+	b.buf = putUvarint(b.buf, uint64(len(value)))
+	b.buf = append(b.buf, key[shared:]...)
+	b.buf = append(b.buf, value...)
+	b.lastKey = append(b.lastKey[:0], key...)
+}
+
+func (b *block) finish() []byte {
+	if len(b.restarts) == 0 {
+		b.restarts = append(b.restarts, 0)
+	}
+	for _, r := range b.restarts {
+		b.buf = putUint32(b.buf, r)
+	}
+	return putUint32(b.buf, uint32(len(b.restarts)))
+}
+
+type table struct {
+	cf          string
+	compression byte
+	buf         bytes.Buffer
+}
+
+func (t *table) compress(data []byte) []byte {
+	out := putUvarint(nil, uint64(len(data)))
+	switch t.compression {
+	case compressionLZ4:
+		dst := make([]byte, lz4.CompressBlockBound(len(data)))
+		n, err := lz4.CompressBlock(data, dst, make([]int, 1<<16))
+		if err != nil {
+			panic(err)
+		}
+		return append(out, dst[:n]...)
+	case compressionZSTD:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			panic(err)
+		}
+		return enc.EncodeAll(data, out)
+	}
+	panic("unknown compression")
+}
+
+func (t *table) writeBlock(data []byte, compression byte) blockHandle {
+	if compression != 0 {
+		data = t.compress(data)
+	}
+	h := blockHandle{offset: uint64(t.buf.Len()), size: uint64(len(data))}
+	t.buf.Write(data)
+	t.buf.WriteByte(compression)
+	crc := crc32.Checksum(t.buf.Bytes()[h.offset:], crc32cTable)
+	t.buf.Write(putUint32(nil, ((crc>>15)|(crc<<17))+0xa282ead8))
+	return h
+}
+
+func (t *table) write(keys, values [][]byte) []byte {
+	index := &block{restartIntv: 1}
+	data := &block{restartIntv: restartIntv}
+	var rawKeySize, rawValueSize, dataSize, dataBlocks uint64
+	flush := func() {
+		if data.n == 0 {
+			return
+		}
+		lastKey := append([]byte(nil), data.lastKey...)
+		h := t.writeBlock(data.finish(), t.compression)
+		index.add(lastKey, putHandle(nil, h))
+		dataSize += h.size + 5
+		dataBlocks++
+		data = &block{restartIntv: restartIntv}
+	}
+	for i, key := range keys {
+		// The keys are ingested with sequence number 0 and type value.
+		internalKey := append(append([]byte(nil), key...), 1, 0, 0, 0, 0, 0, 0, 0)
+		data.add(internalKey, values[i])
+		rawKeySize += uint64(len(internalKey))
+		rawValueSize += uint64(len(values[i]))
+		if len(data.buf) >= blockSize {
+			flush()
+		}
+	}
+	flush()
+
+	indexBlock := index.finish()
+	props := map[string][]byte{
+		"rocksdb.block.based.table.index.type":          putUint32(nil, 0),
+		"rocksdb.block.based.table.prefix.filtering":    []byte("0"),
+		"rocksdb.block.based.table.whole.key.filtering": []byte("1"),
+		"rocksdb.column.family.id":                      putUvarint(nil, 0x7fffffff),
+		"rocksdb.column.family.name":                    []byte(t.cf),
+		"rocksdb.comparator":                            []byte("leveldb.BytewiseComparator"),
+		"rocksdb.compression":                           []byte(map[byte]string{compressionLZ4: "LZ4", compressionZSTD: "ZSTD"}[t.compression]),
+		"rocksdb.data.size":                             putUvarint(nil, dataSize),
+		"rocksdb.deleted.keys":                          putUvarint(nil, 0),
+		"rocksdb.external_sst_file.global_seqno":        make([]byte, 8),
+		"rocksdb.external_sst_file.version":             putUint32(nil, 2),
+		"rocksdb.filter.size":                           putUvarint(nil, 0),
+		"rocksdb.fixed.key.length":                      putUvarint(nil, 0),
+		"rocksdb.format.version":                        putUvarint(nil, formatVersion),
+		"rocksdb.index.key.is.user.key":                 putUvarint(nil, 0),
+		"rocksdb.index.size":                            putUvarint(nil, uint64(len(indexBlock))),
+		"rocksdb.index.value.is.delta.encoded":          putUvarint(nil, 0),
+		"rocksdb.merge.operands":                        putUvarint(nil, 0),
+		"rocksdb.num.data.blocks":                       putUvarint(nil, dataBlocks),
+		"rocksdb.num.entries":                           putUvarint(nil, uint64(len(keys))),
+		"rocksdb.raw.key.size":                          putUvarint(nil, rawKeySize),
+		"rocksdb.raw.value.size":                        putUvarint(nil, rawValueSize),
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	propsBlock := &block{restartIntv: 1}
+	for _, name := range names {
+		propsBlock.add([]byte(name), props[name])
+	}
+	propsHandle := t.writeBlock(propsBlock.finish(), 0)
+	metaIndex := &block{restartIntv: 1}
+	metaIndex.add([]byte("rocksdb.properties"), putHandle(nil, propsHandle))
+	metaIndexHandle := t.writeBlock(metaIndex.finish(), 0)
+	indexHandle := t.writeBlock(indexBlock, 0)
+
+	t.buf.WriteByte(1) // crc32c
+	handles := putHandle(putHandle(nil, metaIndexHandle), indexHandle)
+	t.buf.Write(handles)
+	t.buf.Write(make([]byte, 40-len(handles)))
+	t.buf.Write(putUint32(nil, formatVersion))
+	var magic [8]byte
+	binary.LittleEndian.PutUint64(magic[:], magicNumber)
+	t.buf.Write(magic[:])
+	return t.buf.Bytes()
+}
+
+func main() {
+	var defaultKeys, defaultValues, writeKeys, writeValues [][]byte
+	for handle := int64(1); handle <= rows; handle++ {
+		key := codec.EncodeBytes([]byte("z"), tablecodec.EncodeRowKeyWithHandle(tableID, kv.IntHandle(handle)))
+		value := bytes.Repeat([]byte{byte(handle)}, int(handle%50))
+		write := append([]byte{'P'}, putUvarint(nil, startTS)...)
+		if handle%10 == 0 {
+			// The long values are put into the default CF.
+			value = bytes.Repeat([]byte{byte(handle)}, 300)
+			defaultKeys = append(defaultKeys, codec.EncodeUintDesc(append([]byte(nil), key...), startTS))
+			defaultValues = append(defaultValues, value)
+		} else {
+			write = append(append(write, 'v', byte(len(value))), value...)
+		}
+		writeKeys = append(writeKeys, codec.EncodeUintDesc(append([]byte(nil), key...), commitTS))
+		writeValues = append(writeValues, write)
+	}
+	t := &table{cf: "default", compression: compressionLZ4}
+	if err := ioutil.WriteFile("default.sst", t.write(defaultKeys, defaultValues), 0644); err != nil {
+		panic(err)
+	}
+	t = &table{cf: "write", compression: compressionZSTD}
+	if err := ioutil.WriteFile("write.sst", t.write(writeKeys, writeValues), 0644); err != nil {
+		panic(err)
+	}
+}
@@ -3,16 +3,20 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/backup"
 	"github.com/spf13/pflag"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -83,14 +87,26 @@ type gcsStorage struct {
 	bucket *storage.BucketHandle
 }
 
+// objectName returns the name of the object of the file. The prefix is
+// regarded as a directory, no matter whether it ends with "/".
+func (s *gcsStorage) objectName(name string) string {
+	return path.Join(s.gcs.Prefix, name)
+}
+
 // Write file to storage.
 func (s *gcsStorage) Write(ctx context.Context, name string, data []byte) error {
-	object := s.gcs.Prefix + name
+	return s.WriteFrom(ctx, name, bytes.NewReader(data))
+}
+
+// WriteFrom writes file to storage with the data read from r.
+func (s *gcsStorage) WriteFrom(ctx context.Context, name string, r io.ReadSeeker) error {
+	object := s.objectName(name)
 	wc := s.bucket.Object(object).NewWriter(ctx)
 	wc.StorageClass = s.gcs.StorageClass
 	wc.PredefinedACL = s.gcs.PredefinedAcl
-	_, err := wc.Write(data)
+	_, err := io.Copy(wc, r)
 	if err != nil {
+		wc.Close()
 		return err
 	}
 	return wc.Close()
@@ -98,7 +114,7 @@ func (s *gcsStorage) Write(ctx context.Context, name string, data []byte) error
 
 // Read storage file.
 func (s *gcsStorage) Read(ctx context.Context, name string) ([]byte, error) {
-	object := s.gcs.Prefix + name
+	object := s.objectName(name)
 	rc, err := s.bucket.Object(object).NewReader(ctx)
 	if err != nil {
 		return nil, err
@@ -110,9 +126,14 @@ func (s *gcsStorage) Read(ctx context.Context, name string) ([]byte, error) {
 	return b, err
 }
 
+// Open opens the storage file for reading.
+func (s *gcsStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.bucket.Object(s.objectName(name)).NewReader(ctx)
+}
+
 // FileExists return true if file exists.
 func (s *gcsStorage) FileExists(ctx context.Context, name string) (bool, error) {
-	object := s.gcs.Prefix + name
+	object := s.objectName(name)
 	_, err := s.bucket.Object(object).Attrs(ctx)
 	if err != nil {
 		if err == storage.ErrObjectNotExist {
@@ -123,6 +144,48 @@ func (s *gcsStorage) FileExists(ctx context.Context, name string) (bool, error)
 	return true, nil
 }
 
+// FileSize returns the size of the file.
+func (s *gcsStorage) FileSize(ctx context.Context, name string) (int64, error) {
+	object := s.objectName(name)
+	attrs, err := s.bucket.Object(object).Attrs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+// DeleteFile deletes the file in storage.
+func (s *gcsStorage) DeleteFile(ctx context.Context, name string) error {
+	object := s.objectName(name)
+	return s.bucket.Object(object).Delete(ctx)
+}
+
+// WalkDir calls fn with the objects under the prefix. The prefix is listed as
+// a directory, so the objects of a sibling prefix like "backup-old/" are not
+// listed for the prefix "backup".
+func (s *gcsStorage) WalkDir(ctx context.Context, fn func(name string, size int64) error) error {
+	prefix := s.gcs.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	it := s.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(attrs.Name, prefix) {
+			continue
+		}
+		if err = fn(strings.TrimPrefix(attrs.Name, prefix), attrs.Size); err != nil {
+			return err
+		}
+	}
+}
+
 func newGCSStorage(ctx context.Context, gcs *backup.GCS, sendCredential bool) (*gcsStorage, error) {
 	return newGCSStorageWithHTTPClient(ctx, gcs, nil, sendCredential)
 }
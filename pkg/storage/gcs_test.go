@@ -6,6 +6,7 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/fsouza/fake-gcs-server/fakestorage"
 	. "github.com/pingcap/check"
@@ -47,6 +48,15 @@ func (r *testStorageSuite) TestGCS(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(d, DeepEquals, []byte("data"))
 
+	c.Assert(stg.WriteFrom(ctx, "stream", strings.NewReader("stream data")), IsNil)
+	stream, err := stg.Open(ctx, "stream")
+	c.Assert(err, IsNil)
+	d, err = ioutil.ReadAll(stream)
+	stream.Close()
+	c.Assert(err, IsNil)
+	c.Assert(d, DeepEquals, []byte("stream data"))
+	c.Assert(stg.DeleteFile(ctx, "stream"), IsNil)
+
 	exist, err := stg.FileExists(ctx, "key")
 	c.Assert(err, IsNil)
 	c.Assert(exist, IsTrue)
@@ -54,6 +64,67 @@ func (r *testStorageSuite) TestGCS(c *C) {
 	exist, err = stg.FileExists(ctx, "key_not_exist")
 	c.Assert(err, IsNil)
 	c.Assert(exist, IsFalse)
+
+	size, err := stg.FileSize(ctx, "key")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len("data")))
+	_, err = stg.FileSize(ctx, "key_not_exist")
+	c.Assert(err, NotNil)
+
+	c.Assert(stg.Write(ctx, "dir/key2", []byte("data2")), IsNil)
+	sizes := make(map[string]int64)
+	err = stg.WalkDir(ctx, func(name string, size int64) error {
+		sizes[name] = size
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(sizes, DeepEquals, map[string]int64{"key": 4, "dir/key2": 5})
+
+	err = stg.DeleteFile(ctx, "key")
+	c.Assert(err, IsNil)
+	exist, err = stg.FileExists(ctx, "key")
+	c.Assert(err, IsNil)
+	c.Assert(exist, IsFalse)
+}
+
+func (r *testStorageSuite) TestGCSPrefixWithoutSlash(c *C) {
+	ctx := context.Background()
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{NoListener: true})
+	c.Assert(err, IsNil)
+	bucketName := "testbucket"
+	server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: bucketName})
+	bucket := server.Client().Bucket(bucketName)
+	for _, name := range []string{"backup-old/1.sst", "backupmeta"} {
+		wc := bucket.Object(name).NewWriter(ctx)
+		_, err = wc.Write([]byte("other"))
+		c.Assert(err, IsNil)
+		c.Assert(wc.Close(), IsNil)
+	}
+
+	gcs := &backup.GCS{Bucket: bucketName, Prefix: "backup", CredentialsBlob: "Fake Credentials"}
+	stg, err := newGCSStorageWithHTTPClient(ctx, gcs, server.HTTPClient(), false)
+	c.Assert(err, IsNil)
+	c.Assert(stg.Write(ctx, "1.sst", []byte("data")), IsNil)
+	_, err = bucket.Object("backup/1.sst").Attrs(ctx)
+	c.Assert(err, IsNil)
+
+	// The objects of the sibling prefix are not listed.
+	var names []string
+	err = stg.WalkDir(ctx, func(name string, size int64) error {
+		names = append(names, name)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"1.sst"})
+
+	// Deleting the listed files never deletes the objects of other prefixes.
+	for _, name := range names {
+		c.Assert(stg.DeleteFile(ctx, name), IsNil)
+	}
+	for _, name := range []string{"backup-old/1.sst", "backupmeta"} {
+		_, err = bucket.Object(name).Attrs(ctx)
+		c.Assert(err, IsNil)
+	}
 }
 
 func (r *testStorageSuite) TestNewGCSStorage(c *C) {
@@ -4,9 +4,11 @@ package storage
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 // localStorage represents local file system storage.
@@ -16,21 +18,79 @@ type localStorage struct {
 
 func (l *localStorage) Write(ctx context.Context, name string, data []byte) error {
 	filepath := path.Join(l.base, name)
+	// The name may have directories like the object keys of S3 and GCS.
+	if err := os.MkdirAll(path.Dir(filepath), 0755); err != nil {
+		return err
+	}
 	return ioutil.WriteFile(filepath, data, 0644) // nolint:gosec
 	// the backupmeta file _is_ intended to be world-readable.
 }
 
+// WriteFrom implement ExternalStorage.WriteFrom.
+func (l *localStorage) WriteFrom(ctx context.Context, name string, r io.ReadSeeker) error {
+	filepath := path.Join(l.base, name)
+	if err := os.MkdirAll(path.Dir(filepath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func (l *localStorage) Read(ctx context.Context, name string) ([]byte, error) {
 	filepath := path.Join(l.base, name)
 	return ioutil.ReadFile(filepath)
 }
 
+// Open implement ExternalStorage.Open.
+func (l *localStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(path.Join(l.base, name))
+}
+
 // FileExists implement ExternalStorage.FileExists.
 func (l *localStorage) FileExists(ctx context.Context, name string) (bool, error) {
 	filepath := path.Join(l.base, name)
 	return pathExists(filepath)
 }
 
+// FileSize implement ExternalStorage.FileSize.
+func (l *localStorage) FileSize(ctx context.Context, name string) (int64, error) {
+	stat, err := os.Stat(path.Join(l.base, name))
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// DeleteFile implement ExternalStorage.DeleteFile.
+func (l *localStorage) DeleteFile(ctx context.Context, name string) error {
+	filepath := path.Join(l.base, name)
+	return os.Remove(filepath)
+}
+
+// WalkDir implement ExternalStorage.WalkDir.
+func (l *localStorage) WalkDir(ctx context.Context, fn func(name string, size int64) error) error {
+	return filepath.Walk(l.base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(l.base, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(name), info.Size())
+	})
+}
+
 func pathExists(_path string) (bool, error) {
 	_, err := os.Stat(_path)
 	if err != nil {
@@ -0,0 +1,47 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+func (r *testStorageSuite) TestLocalWalkDir(c *C) {
+	ctx := context.Background()
+	base := c.MkDir()
+	stg, err := newLocalStorage(base)
+	c.Assert(err, IsNil)
+	c.Assert(stg.Write(ctx, "backupmeta", []byte("meta")), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(base, "dir", "empty"), 0755), IsNil)
+	c.Assert(stg.Write(ctx, "dir/1.sst", []byte("sst")), IsNil)
+
+	sizes := make(map[string]int64)
+	err = stg.WalkDir(ctx, func(name string, size int64) error {
+		sizes[name] = size
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(sizes, DeepEquals, map[string]int64{"backupmeta": 4, "dir/1.sst": 3})
+}
+
+func (r *testStorageSuite) TestLocalStream(c *C) {
+	ctx := context.Background()
+	stg, err := newLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	c.Assert(stg.WriteFrom(ctx, "dir/1.sst", strings.NewReader("sst")), IsNil)
+
+	rc, err := stg.Open(ctx, "dir/1.sst")
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(rc.Close(), IsNil)
+	c.Assert(string(data), Equals, "sst")
+	_, err = stg.Open(ctx, "not_exist")
+	c.Assert(os.IsNotExist(err), IsTrue)
+}
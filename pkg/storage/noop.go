@@ -2,7 +2,12 @@
 
 package storage
 
-import "context"
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+)
 
 type noopStorage struct{}
 
@@ -11,16 +16,41 @@ func (*noopStorage) Write(ctx context.Context, name string, data []byte) error {
 	return nil
 }
 
+// WriteFrom writes file to storage.
+func (*noopStorage) WriteFrom(ctx context.Context, name string, r io.ReadSeeker) error {
+	return nil
+}
+
 // Read storage file.
 func (*noopStorage) Read(ctx context.Context, name string) ([]byte, error) {
 	return []byte{}, nil
 }
 
+// Open opens an empty file.
+func (*noopStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(nil)), nil
+}
+
 // FileExists return true if file exists.
 func (*noopStorage) FileExists(ctx context.Context, name string) (bool, error) {
 	return false, nil
 }
 
+// FileSize returns the size of the file.
+func (*noopStorage) FileSize(ctx context.Context, name string) (int64, error) {
+	return 0, nil
+}
+
+// DeleteFile deletes the file in storage.
+func (*noopStorage) DeleteFile(ctx context.Context, name string) error {
+	return nil
+}
+
+// WalkDir walks no file.
+func (*noopStorage) WalkDir(ctx context.Context, fn func(name string, size int64) error) error {
+	return nil
+}
+
 func newNoopStorage() *noopStorage {
 	return &noopStorage{}
 }
@@ -5,8 +5,10 @@ package storage
 import (
 	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 	"net/url"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -38,8 +40,10 @@ type s3Handlers interface {
 	HeadObjectWithContext(context.Context, *s3.HeadObjectInput, ...request.Option) (*s3.HeadObjectOutput, error)
 	GetObjectWithContext(context.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error)
 	PutObjectWithContext(context.Context, *s3.PutObjectInput, ...request.Option) (*s3.PutObjectOutput, error)
+	DeleteObjectWithContext(context.Context, *s3.DeleteObjectInput, ...request.Option) (*s3.DeleteObjectOutput, error)
 	HeadBucketWithContext(context.Context, *s3.HeadBucketInput, ...request.Option) (*s3.HeadBucketOutput, error)
 	WaitUntilObjectExistsWithContext(context.Context, *s3.HeadObjectInput, ...request.WaiterOption) error
+	ListObjectsWithContext(context.Context, *s3.ListObjectsInput, ...request.Option) (*s3.ListObjectsOutput, error)
 }
 
 // S3Storage info for s3 storage.
@@ -222,8 +226,14 @@ var checkS3Bucket = func(svc *s3.S3, bucket string) error {
 
 // Write write to s3 storage.
 func (rs *S3Storage) Write(ctx context.Context, file string, data []byte) error {
+	return rs.WriteFrom(ctx, file, bytes.NewReader(data))
+}
+
+// WriteFrom writes to s3 storage with the data read from r, r is seeked to
+// compute the signature and the length of the data.
+func (rs *S3Storage) WriteFrom(ctx context.Context, file string, r io.ReadSeeker) error {
 	input := &s3.PutObjectInput{
-		Body:   aws.ReadSeekCloser(bytes.NewReader(data)),
+		Body:   aws.ReadSeekCloser(r),
 		Bucket: aws.String(rs.options.Bucket),
 		Key:    aws.String(rs.options.Prefix + file),
 	}
@@ -254,6 +264,20 @@ func (rs *S3Storage) Write(ctx context.Context, file string, data []byte) error
 
 // Read read file from s3.
 func (rs *S3Storage) Read(ctx context.Context, file string) ([]byte, error) {
+	body, err := rs.Open(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Open opens the file on s3 for reading.
+func (rs *S3Storage) Open(ctx context.Context, file string) (io.ReadCloser, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(rs.options.Bucket),
 		Key:    aws.String(rs.options.Prefix + file),
@@ -263,12 +287,7 @@ func (rs *S3Storage) Read(ctx context.Context, file string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer result.Body.Close()
-	data, err := ioutil.ReadAll(result.Body)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return result.Body, nil
 }
 
 // FileExists check if file exists on s3 storage.
@@ -292,3 +311,63 @@ func (rs *S3Storage) FileExists(ctx context.Context, file string) (bool, error)
 
 	return true, err
 }
+
+// FileSize returns the size of the file on s3 storage.
+func (rs *S3Storage) FileSize(ctx context.Context, file string) (int64, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(rs.options.Bucket),
+		Key:    aws.String(rs.options.Prefix + file),
+	}
+
+	result, err := rs.svc.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	return aws.Int64Value(result.ContentLength), nil
+}
+
+// DeleteFile delete the file in s3 storage.
+func (rs *S3Storage) DeleteFile(ctx context.Context, file string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(rs.options.Bucket),
+		Key:    aws.String(rs.options.Prefix + file),
+	}
+
+	_, err := rs.svc.DeleteObjectWithContext(ctx, input)
+	return err
+}
+
+// WalkDir calls fn with the objects under the prefix of s3 storage.
+func (rs *S3Storage) WalkDir(ctx context.Context, fn func(name string, size int64) error) error {
+	// The prefix ends with "/" since it is set by newS3Storage, so the objects
+	// of a sibling prefix like "backup-old/" are not listed.
+	prefix := rs.options.Prefix
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(rs.options.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		result, err := rs.svc.ListObjectsWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, object := range result.Contents {
+			key := aws.StringValue(object.Key)
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if err = fn(strings.TrimPrefix(key, prefix), aws.Int64Value(object.Size)); err != nil {
+				return err
+			}
+		}
+		if !aws.BoolValue(result.IsTruncated) || len(result.Contents) == 0 {
+			return nil
+		}
+		// The next marker is only returned if a delimiter is specified.
+		marker := result.NextMarker
+		if marker == nil {
+			marker = result.Contents[len(result.Contents)-1].Key
+		}
+		input.Marker = marker
+	}
+}
@@ -393,6 +393,13 @@ func (r *testStorageSuite) TestS3Handlers(c *C) {
 		if err != nil {
 			c.Assert(err, Equals, test.mh.err)
 		}
+		size, err := ms3.FileSize(ctx, "file")
+		c.Assert(err, Equals, test.mh.err)
+		if err == nil {
+			c.Assert(size, Equals, int64(len("HappyFace.jpg")))
+		}
+		err = ms3.DeleteFile(ctx, "file")
+		c.Assert(err, Equals, test.mh.err)
 	}
 	tests := []testcase{
 		{
@@ -448,17 +455,47 @@ func (r *testStorageSuite) TestS3Handlers(c *C) {
 	}
 }
 
+func (r *testStorageSuite) TestS3WalkDir(c *C) {
+	ctx := aws.BackgroundContext()
+	ms3 := S3Storage{
+		svc: &mockS3Handler{objects: []string{
+			"other/backupmeta", "prefix-old/1.sst", "prefix/1.sst", "prefix/2.sst", "prefix/backupmeta",
+			"prefix/dir/3.sst",
+		}},
+		options: &backup.S3{Bucket: "bucket", Prefix: "prefix/"},
+	}
+	var names []string
+	err := ms3.WalkDir(ctx, func(name string, size int64) error {
+		c.Assert(size, Equals, int64(len("prefix/"+name)))
+		names = append(names, name)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"1.sst", "2.sst", "backupmeta", "dir/3.sst"})
+
+	ms3.svc = &mockS3Handler{err: errors.New("list error")}
+	err = ms3.WalkDir(ctx, func(name string, size int64) error { return nil })
+	c.Assert(err, ErrorMatches, "list error")
+}
+
 func (r *testStorageSuite) TestS3Others(c *C) {
 	defineS3Flags(&pflag.FlagSet{})
 }
 
 type mockS3Handler struct {
 	err error
+	// objects are listed in pages of 2 objects.
+	objects []string
 }
 
 func (c *mockS3Handler) HeadObjectWithContext(ctx context.Context,
 	input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
-	return nil, c.err
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len("HappyFace.jpg"))),
+	}, nil
 }
 func (c *mockS3Handler) GetObjectWithContext(ctx context.Context,
 	input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
@@ -473,6 +510,10 @@ func (c *mockS3Handler) PutObjectWithContext(ctx context.Context,
 	input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
 	return nil, c.err
 }
+func (c *mockS3Handler) DeleteObjectWithContext(ctx context.Context,
+	input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	return nil, c.err
+}
 func (c *mockS3Handler) HeadBucketWithContext(ctx context.Context,
 	input *s3.HeadBucketInput, opts ...request.Option) (*s3.HeadBucketOutput, error) {
 	return nil, c.err
@@ -481,3 +522,21 @@ func (c *mockS3Handler) WaitUntilObjectExistsWithContext(ctx context.Context,
 	input *s3.HeadObjectInput, opts ...request.WaiterOption) error {
 	return c.err
 }
+func (c *mockS3Handler) ListObjectsWithContext(ctx context.Context,
+	input *s3.ListObjectsInput, opts ...request.Option) (*s3.ListObjectsOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	output := &s3.ListObjectsOutput{IsTruncated: aws.Bool(false)}
+	for _, key := range c.objects {
+		if !strings.HasPrefix(key, aws.StringValue(input.Prefix)) || key <= aws.StringValue(input.Marker) {
+			continue
+		}
+		if len(output.Contents) == 2 {
+			output.IsTruncated = aws.Bool(true)
+			break
+		}
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), Size: aws.Int64(int64(len(key)))})
+	}
+	return output, nil
+}
@@ -4,6 +4,7 @@ package storage
 
 import (
 	"context"
+	"io"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/backup"
@@ -13,10 +14,22 @@ import (
 type ExternalStorage interface {
 	// Write file to storage
 	Write(ctx context.Context, name string, data []byte) error
+	// WriteFrom writes the file with the data read from r, without holding
+	// the whole file in memory.
+	WriteFrom(ctx context.Context, name string, r io.ReadSeeker) error
 	// Read storage file
 	Read(ctx context.Context, name string) ([]byte, error)
+	// Open opens the file for reading, the caller should close it.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
 	// FileExists return true if file exists
 	FileExists(ctx context.Context, name string) (bool, error)
+	// FileSize returns the size of the file in bytes
+	FileSize(ctx context.Context, name string) (int64, error)
+	// DeleteFile delete the file in storage
+	DeleteFile(ctx context.Context, name string) error
+	// WalkDir calls fn with the name and size of every file in storage, the
+	// names are relative to the root of storage.
+	WalkDir(ctx context.Context, fn func(name string, size int64) error) error
 }
 
 // Create creates ExternalStorage.
@@ -4,6 +4,7 @@ package summary
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -37,6 +38,14 @@ type LogCollector interface {
 
 	SetSuccessStatus(success bool)
 
+	SetBackupTS(ts uint64)
+
+	SetClusterVersion(version string)
+
+	CollectTable(table TableReport)
+
+	Report(name string) Report
+
 	Summary(name string)
 }
 
@@ -74,21 +83,36 @@ type logCollector struct {
 	durations        map[string]time.Duration
 	ints             map[string]int
 	successStatus    bool
+	backupTS         uint64
+	clusterVersion   string
+	tables           []TableReport
+	startTime        time.Time
 
 	log logFunc
 }
 
 func newLogCollector(log logFunc) LogCollector {
-	return &logCollector{
-		successUnitCount: 0,
-		failureUnitCount: 0,
-		successCosts:     make(map[string]time.Duration),
-		successData:      make(map[string]uint64),
-		failureReasons:   make(map[string]error),
-		durations:        make(map[string]time.Duration),
-		ints:             make(map[string]int),
-		log:              log,
-	}
+	tc := &logCollector{log: log}
+	tc.reset()
+	return tc
+}
+
+// reset clears everything collected for the last task, so that nothing of it
+// is reported by the next task in the same process, e.g. the server.
+// The unit is kept since it is set before the task runs.
+func (tc *logCollector) reset() {
+	tc.successUnitCount = 0
+	tc.failureUnitCount = 0
+	tc.successCosts = make(map[string]time.Duration)
+	tc.successData = make(map[string]uint64)
+	tc.failureReasons = make(map[string]error)
+	tc.durations = make(map[string]time.Duration)
+	tc.ints = make(map[string]int)
+	tc.successStatus = false
+	tc.backupTS = 0
+	tc.clusterVersion = ""
+	tc.tables = nil
+	tc.startTime = time.Now()
 }
 
 func (tc *logCollector) SetUnit(unit string) {
@@ -137,13 +161,66 @@ func (tc *logCollector) SetSuccessStatus(success bool) {
 	tc.successStatus = success
 }
 
+func (tc *logCollector) SetBackupTS(ts uint64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.backupTS = ts
+}
+
+func (tc *logCollector) SetClusterVersion(version string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.clusterVersion = version
+}
+
+func (tc *logCollector) CollectTable(table TableReport) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.tables = append(tc.tables, table)
+}
+
+func (tc *logCollector) Report(name string) Report {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	report := Report{
+		Name:            name,
+		Unit:            tc.unit,
+		Success:         len(tc.failureReasons) == 0 && tc.successStatus,
+		BackupTS:        tc.backupTS,
+		ClusterVersion:  tc.clusterVersion,
+		StartTime:       tc.startTime,
+		EndTime:         time.Now(),
+		TotalUnits:      tc.failureUnitCount + tc.successUnitCount,
+		SuccessUnits:    tc.successUnitCount,
+		TotalKVs:        tc.successData[TotalKV],
+		TotalBytes:      tc.successData[TotalBytes],
+		DurationSeconds: make(map[string]float64, len(tc.durations)),
+		Counters:        make(map[string]int, len(tc.ints)),
+		Tables:          append([]TableReport{}, tc.tables...),
+	}
+	for _, cost := range tc.successCosts {
+		report.TotalTakeSeconds += cost.Seconds()
+	}
+	for key, val := range tc.durations {
+		report.DurationSeconds[key] = val.Seconds()
+	}
+	for key, val := range tc.ints {
+		report.Counters[key] = val
+	}
+	for unitName, reason := range tc.failureReasons {
+		report.FailedUnits = append(report.FailedUnits, FailedUnit{Name: unitName, Error: reason.Error()})
+	}
+	sort.Slice(report.FailedUnits, func(i, j int) bool {
+		return report.FailedUnits[i].Name < report.FailedUnits[j].Name
+	})
+	return report
+}
+
 func (tc *logCollector) Summary(name string) {
 	tc.mu.Lock()
 	defer func() {
-		tc.durations = make(map[string]time.Duration)
-		tc.ints = make(map[string]int)
-		tc.successCosts = make(map[string]time.Duration)
-		tc.failureReasons = make(map[string]error)
+		tc.reset()
 		tc.mu.Unlock()
 	}()
 
@@ -3,6 +3,7 @@
 package summary
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -47,3 +48,70 @@ func (suit *testCollectorSuite) TestSumDurationInt(c *C) {
 	assertContains(zap.Duration("b", 2*time.Second))
 	assertContains(zap.Int("c", 4))
 }
+
+func (suit *testCollectorSuite) TestReport(c *C) {
+	col := newLogCollector(func(string, ...zap.Field) {})
+	col.SetUnit(BackupUnit)
+	col.SetBackupTS(42)
+	col.SetClusterVersion("4.0.0")
+	col.CollectSuccessUnit("range1", 1, time.Second)
+	col.CollectSuccessUnit(TotalKV, 1, uint64(10))
+	col.CollectSuccessUnit(TotalBytes, 1, uint64(100))
+	col.CollectFailureUnit("range2", errors.New("injected"))
+	col.CollectDuration("backup checksum", 2*time.Second)
+	col.CollectInt("backup total regions", 3)
+	col.CollectTable(TableReport{DB: "test", Table: "t", Crc64Xor: 1, TotalKVs: 10, TotalBytes: 100})
+	col.SetSuccessStatus(true)
+
+	report := col.Report("Full backup")
+	c.Assert(report.Name, Equals, "Full backup")
+	c.Assert(report.Unit, Equals, BackupUnit)
+	c.Assert(report.Success, IsFalse)
+	c.Assert(report.BackupTS, Equals, uint64(42))
+	c.Assert(report.ClusterVersion, Equals, "4.0.0")
+	c.Assert(report.TotalUnits, Equals, 2)
+	c.Assert(report.SuccessUnits, Equals, 1)
+	c.Assert(report.FailedUnits, DeepEquals, []FailedUnit{{Name: "range2", Error: "injected"}})
+	c.Assert(report.TotalTakeSeconds, Equals, float64(1))
+	c.Assert(report.TotalKVs, Equals, uint64(10))
+	c.Assert(report.TotalBytes, Equals, uint64(100))
+	c.Assert(report.DurationSeconds, DeepEquals, map[string]float64{"backup checksum": 2})
+	c.Assert(report.Counters, DeepEquals, map[string]int{"backup total regions": 3})
+	c.Assert(report.Tables, HasLen, 1)
+
+	// The summary resets everything collected for the task.
+	col.Summary("Full backup")
+	c.Assert(col.Report("Full backup").Tables, HasLen, 0)
+}
+
+func (suit *testCollectorSuite) TestTasksBackToBack(c *C) {
+	col := newLogCollector(func(string, ...zap.Field) {})
+	col.SetUnit(BackupUnit)
+	col.SetBackupTS(42)
+	col.SetClusterVersion("4.0.0")
+	col.CollectSuccessUnit("range1", 1, time.Second)
+	col.CollectSuccessUnit(TotalKV, 1, uint64(10))
+	col.CollectSuccessUnit(TotalBytes, 1, uint64(100))
+	col.CollectFailureUnit("range2", errors.New("injected"))
+	col.CollectDuration("backup checksum", 2*time.Second)
+	col.CollectInt("backup total regions", 3)
+	col.CollectTable(TableReport{DB: "test", Table: "t"})
+	col.SetSuccessStatus(true)
+	col.Summary("Full backup")
+
+	// The next task fails before collecting anything.
+	report := col.Report("Full backup")
+	c.Assert(report.Unit, Equals, BackupUnit)
+	c.Assert(report.Success, IsFalse)
+	c.Assert(report.BackupTS, Equals, uint64(0))
+	c.Assert(report.ClusterVersion, Equals, "")
+	c.Assert(report.TotalUnits, Equals, 0)
+	c.Assert(report.SuccessUnits, Equals, 0)
+	c.Assert(report.FailedUnits, HasLen, 0)
+	c.Assert(report.TotalTakeSeconds, Equals, float64(0))
+	c.Assert(report.TotalKVs, Equals, uint64(0))
+	c.Assert(report.TotalBytes, Equals, uint64(0))
+	c.Assert(report.DurationSeconds, HasLen, 0)
+	c.Assert(report.Counters, HasLen, 0)
+	c.Assert(report.Tables, HasLen, 0)
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package summary
+
+import (
+	"time"
+)
+
+// Report is the machine-readable report of a task, it contains the same
+// information as the summary log, as well as the details of tables.
+type Report struct {
+	Name           string `json:"name"`
+	Unit           string `json:"unit"`
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+	BackupTS       uint64 `json:"backup-ts,omitempty"`
+	BRVersion      string `json:"br-version"`
+	BRGitHash      string `json:"br-git-hash"`
+	ClusterVersion string `json:"cluster-version,omitempty"`
+
+	StartTime        time.Time `json:"start-time"`
+	EndTime          time.Time `json:"end-time"`
+	TotalTakeSeconds float64   `json:"total-take-seconds"`
+
+	TotalUnits   int          `json:"total-units"`
+	SuccessUnits int          `json:"success-units"`
+	FailedUnits  []FailedUnit `json:"failed-units,omitempty"`
+
+	TotalKVs   uint64 `json:"total-kvs"`
+	TotalBytes uint64 `json:"total-bytes"`
+
+	// DurationSeconds are the time costs of phases, in seconds.
+	DurationSeconds map[string]float64 `json:"duration-seconds"`
+	Counters        map[string]int     `json:"counters"`
+	Tables          []TableReport      `json:"tables,omitempty"`
+}
+
+// FailedUnit is a unit failed in a task.
+type FailedUnit struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// TableReport is the size and checksum of a table in a task.
+type TableReport struct {
+	DB         string `json:"db"`
+	Table      string `json:"table"`
+	Crc64Xor   uint64 `json:"crc64xor"`
+	TotalKVs   uint64 `json:"total-kvs"`
+	TotalBytes uint64 `json:"total-bytes"`
+}
@@ -34,6 +34,26 @@ func SetSuccessStatus(success bool) {
 	collector.SetSuccessStatus(success)
 }
 
+// SetBackupTS sets the backup TS of the task.
+func SetBackupTS(ts uint64) {
+	collector.SetBackupTS(ts)
+}
+
+// SetClusterVersion sets the version of the cluster the task runs against.
+func SetClusterVersion(version string) {
+	collector.SetClusterVersion(version)
+}
+
+// CollectTable collects the size and checksum of a table.
+func CollectTable(table TableReport) {
+	collector.CollectTable(table)
+}
+
+// GetReport returns the structured report of the task.
+func GetReport(name string) Report {
+	return collector.Report(name)
+}
+
 // Summary outputs summary log.
 func Summary(name string) {
 	collector.Summary(name)
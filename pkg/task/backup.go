@@ -59,6 +59,10 @@ func DefineBackupFlags(flags *pflag.FlagSet) {
 	flags.String(flagBackupTS, "", "the backup ts support TSO or datetime,"+
 		" e.g. '400036290571534337', '2018-05-11 01:42:23'")
 	flags.Int64(flagGCTTL, backup.DefaultBRGCSafePointTTL, "the TTL (in seconds) that PD holds for BR's GC safepoint")
+	setConfigKey(flags, flagBackupTimeago, "time-ago")
+	setConfigKey(flags, flagBackupTS, "backup-ts")
+	setConfigKey(flags, flagLastBackupTS, "last-backup-ts")
+	setConfigKey(flags, flagGCTTL, "gc-ttl")
 }
 
 // ParseFromFlags parses the backup-related flags from the flag set.
@@ -99,8 +103,11 @@ func (cfg *BackupConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 }
 
 // RunBackup starts a backup task inside the current goroutine.
-func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig) error {
+func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig) (err error) {
 	defer summary.Summary(cmdName)
+	defer func() {
+		writeReport(c, &cfg.Config, cmdName, utils.BackupReportFile, err)
+	}()
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
@@ -113,6 +120,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		return err
 	}
 	defer mgr.Close()
+	collectClusterVersion(ctx, mgr.GetPDClient())
 
 	client, err := backup.NewBackupClient(ctx, mgr)
 	if err != nil {
@@ -128,6 +136,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		return err
 	}
 	g.Record("BackupTS", backupTS)
+	summary.SetBackupTS(backupTS)
 
 	ranges, backupSchemas, err := backup.BuildBackupRangeAndSchema(
 		mgr.GetDomain(), mgr.GetTiKV(), cfg.TableFilter, backupTS)
@@ -150,7 +159,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 			log.Error("Check gc safepoint for last backup ts failed", zap.Error(err))
 			return err
 		}
-		ddlJobs, err = backup.GetBackupDDLJobs(mgr.GetDomain(), cfg.LastBackupTS, backupTS)
+		ddlJobs, err = backup.GetBackupDDLJobs(mgr.GetTiKV(), cfg.LastBackupTS, backupTS)
 		if err != nil {
 			return err
 		}
@@ -193,6 +202,10 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		backupSchemasConcurrency := utils.MinInt(backup.DefaultSchemaConcurrency, backupSchemas.Len())
 		updateCh = g.StartProgress(
 			ctx, "Checksum", int64(backupSchemas.Len()), !cfg.LogProgress)
+		if cfg.LastBackupTS == 0 {
+			// The checksum of a full backup scans the same kv pairs as backed up.
+			glue.SetTotalBytes(updateCh, client.KVSize())
+		}
 		backupSchemas.Start(
 			ctx, mgr.GetTiKV(), backupTS, uint(backupSchemasConcurrency), updateCh)
 		err = client.CompleteMeta(backupSchemas)
@@ -219,6 +232,11 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	if err != nil {
 		return err
 	}
+	if cfg.reportEnabled() {
+		if err = client.CollectTableSummary(); err != nil {
+			return err
+		}
+	}
 
 	g.Record("Size", client.ArchiveSize())
 
@@ -0,0 +1,354 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// backupMetaFiles are the files written along with the backup files, they are
+// deleted when the backup is pruned.
+var backupMetaFiles = []string{
+	utils.MetaFile,
+	utils.MetaJSONFile,
+	utils.SavedMetaFile,
+	utils.RawChecksumFile,
+	utils.BackupReportFile,
+	utils.RestoreReportFile,
+}
+
+// isBackupMetaFile returns whether the file is written along with the backup
+// files.
+func isBackupMetaFile(name string) bool {
+	for _, metaFile := range backupMetaFiles {
+		if name == metaFile {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogEntry is a backup found in the storage.
+type CatalogEntry struct {
+	// Path is the directory of the backupmeta, "." is the root of the storage.
+	Path         string    `json:"path"`
+	Mode         string    `json:"mode"`
+	StartVersion uint64    `json:"start-version"`
+	EndVersion   uint64    `json:"end-version"`
+	EndTime      time.Time `json:"end-time"`
+	Files        int       `json:"files"`
+	Size         uint64    `json:"size"`
+	// Parent is the path of the backup that the incremental backup is based
+	// on, i.e. whose end version is the start version of the incremental one.
+	// It is empty if the parent is not in the storage.
+	Parent string `json:"parent,omitempty"`
+
+	// files are the backup files and meta files in the storage.
+	files []string
+}
+
+// BackupCatalog is the backups in a storage, sorted by the end version.
+type BackupCatalog []*CatalogEntry
+
+// ListBackups finds the backups in the storage by their backupmeta, which can
+// be at any depth of the storage.
+func ListBackups(ctx context.Context, s storage.ExternalStorage) (BackupCatalog, error) {
+	var (
+		backupDirs []string
+		dirFiles   = make(map[string][]string)
+	)
+	err := s.WalkDir(ctx, func(name string, size int64) error {
+		dir := path.Dir(name)
+		dirFiles[dir] = append(dirFiles[dir], name)
+		if path.Base(name) == utils.MetaFile {
+			backupDirs = append(backupDirs, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotate(err, "list the storage failed")
+	}
+
+	catalog := make(BackupCatalog, 0, len(backupDirs))
+	for _, dir := range backupDirs {
+		metaName := path.Join(dir, utils.MetaFile)
+		data, err := s.Read(ctx, metaName)
+		if err != nil {
+			return nil, errors.Annotatef(err, "load %s failed", metaName)
+		}
+		meta := &backup.BackupMeta{}
+		if err = proto.Unmarshal(data, meta); err != nil {
+			return nil, errors.Annotatef(err, "parse %s failed", metaName)
+		}
+		entry := &CatalogEntry{
+			Path:         dir,
+			Mode:         backupMode(meta),
+			StartVersion: meta.StartVersion,
+			EndVersion:   meta.EndVersion,
+			EndTime:      oracle.GetTimeFromTS(meta.EndVersion).UTC(),
+			Files:        len(meta.Files),
+			Size:         utils.ArchiveSize(meta),
+		}
+		if entry.Mode == BackupModeRaw {
+			// The versions are ignored by raw kv backups.
+			entry.StartVersion, entry.EndVersion, entry.EndTime = 0, 0, time.Time{}
+		}
+		for _, file := range meta.Files {
+			entry.files = append(entry.files, path.Join(dir, file.Name))
+		}
+		for _, name := range dirFiles[dir] {
+			if isBackupMetaFile(path.Base(name)) {
+				entry.files = append(entry.files, name)
+			}
+		}
+		catalog = append(catalog, entry)
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].EndVersion != catalog[j].EndVersion {
+			return catalog[i].EndVersion < catalog[j].EndVersion
+		}
+		return catalog[i].Path < catalog[j].Path
+	})
+	for _, entry := range catalog {
+		if parents := catalog.parents(entry); len(parents) > 0 {
+			entry.Parent = parents[0].Path
+		}
+	}
+	return catalog, nil
+}
+
+// parents returns the backups that the incremental backup may be based on.
+// There can be more than one, e.g. the copies of a backup.
+func (catalog BackupCatalog) parents(entry *CatalogEntry) []*CatalogEntry {
+	if entry.Mode != BackupModeIncremental {
+		return nil
+	}
+	var parents []*CatalogEntry
+	for _, other := range catalog {
+		if other != entry && other.Mode != BackupModeRaw && other.EndVersion == entry.StartVersion {
+			parents = append(parents, other)
+		}
+	}
+	return parents
+}
+
+// PrintJSON writes the backups as JSON.
+func (catalog BackupCatalog) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Trace(err)
+}
+
+// PrintTable writes the backups as a human readable table.
+func (catalog BackupCatalog) PrintTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tMODE\tTS RANGE\tEND TIME\tFILES\tSIZE\tPARENT")
+	for _, entry := range catalog {
+		tsRange, endTime := "-", "-"
+		if entry.Mode != BackupModeRaw {
+			tsRange = fmt.Sprintf("(%d, %d]", entry.StartVersion, entry.EndVersion)
+			endTime = entry.EndTime.Format(time.RFC3339)
+		}
+		parent := "-"
+		switch {
+		case entry.Parent != "":
+			parent = entry.Parent
+		case entry.Mode == BackupModeIncremental:
+			parent = "(missing)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", entry.Path, entry.Mode, tsRange, endTime,
+			entry.Files, utils.FormatBytes(entry.Size), parent)
+	}
+	return errors.Trace(tw.Flush())
+}
+
+// RetentionPolicy decides which backups are retained when pruning. A backup
+// is retained if it is matched by any rule.
+type RetentionPolicy struct {
+	// KeepLast is the number of the latest backups to retain.
+	KeepLast int
+	// KeepWithin is the age of the backups to retain.
+	KeepWithin time.Duration
+}
+
+// ParseRetentionDuration parses the duration of the retention policy, which
+// can be in days like "7d", or in the format of time.ParseDuration.
+func ParseRetentionDuration(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.Errorf("invalid duration %s", s)
+	}
+	return d, nil
+}
+
+// PruneAction is whether a backup is deleted by pruning, and why.
+type PruneAction struct {
+	Backup *CatalogEntry `json:"backup"`
+	Delete bool          `json:"delete"`
+	Reason string        `json:"reason"`
+}
+
+// PrunePlan is the actions of the backups in a catalog.
+type PrunePlan []PruneAction
+
+// PlanPrune decides the backups to delete by the policy. The raw kv backups
+// are never deleted since they have no version to tell the age. The backups
+// that a retained incremental backup is based on are retained too, since they
+// are needed to restore it.
+func PlanPrune(catalog BackupCatalog, policy RetentionPolicy, now time.Time) (PrunePlan, error) {
+	if policy.KeepLast <= 0 && policy.KeepWithin <= 0 {
+		return nil, errors.New("the retention policy should keep some backups")
+	}
+	reasons := make(map[*CatalogEntry]string, len(catalog))
+	var versioned []*CatalogEntry
+	for _, entry := range catalog {
+		if entry.Mode == BackupModeRaw {
+			reasons[entry] = "raw kv backups are not pruned"
+			continue
+		}
+		versioned = append(versioned, entry)
+	}
+	// The catalog is sorted by the end version, so the latest are at the end.
+	for i := len(versioned) - 1; i >= 0 && i >= len(versioned)-policy.KeepLast; i-- {
+		reasons[versioned[i]] = fmt.Sprintf("one of the last %d backups", policy.KeepLast)
+	}
+	if policy.KeepWithin > 0 {
+		for _, entry := range versioned {
+			if _, ok := reasons[entry]; !ok && now.Sub(entry.EndTime) <= policy.KeepWithin {
+				reasons[entry] = fmt.Sprintf("within %s", policy.KeepWithin)
+			}
+		}
+	}
+
+	// Retain the ancestors of the retained incremental backups, from the
+	// latest so that the whole chain is retained.
+	for i := len(versioned) - 1; i >= 0; i-- {
+		entry := versioned[i]
+		if _, ok := reasons[entry]; !ok {
+			continue
+		}
+		for _, parent := range catalog.parents(entry) {
+			if _, ok := reasons[parent]; !ok {
+				reasons[parent] = fmt.Sprintf("needed by %s", entry.Path)
+			}
+		}
+	}
+
+	plan := make(PrunePlan, 0, len(catalog))
+	for _, entry := range catalog {
+		action := PruneAction{Backup: entry, Reason: reasons[entry]}
+		if action.Reason == "" {
+			action.Delete, action.Reason = true, "expired"
+		}
+		plan = append(plan, action)
+	}
+	return plan, nil
+}
+
+// Deleted returns the backups to delete.
+func (plan PrunePlan) Deleted() []*CatalogEntry {
+	var deleted []*CatalogEntry
+	for _, action := range plan {
+		if action.Delete {
+			deleted = append(deleted, action.Backup)
+		}
+	}
+	return deleted
+}
+
+// ExecutePrune deletes the backups in the plan. The backupmeta is deleted at
+// first, so a backup partially deleted is no longer regarded as a backup, and
+// the remaining files can be found by gc-check.
+func ExecutePrune(ctx context.Context, s storage.ExternalStorage, plan PrunePlan) error {
+	for _, entry := range plan.Deleted() {
+		metaName := path.Join(entry.Path, utils.MetaFile)
+		if err := s.DeleteFile(ctx, metaName); err != nil {
+			return errors.Annotatef(err, "delete %s failed", metaName)
+		}
+		for _, name := range entry.files {
+			if name == metaName {
+				continue
+			}
+			exists, err := s.FileExists(ctx, name)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if !exists {
+				continue
+			}
+			if err = s.DeleteFile(ctx, name); err != nil {
+				return errors.Annotatef(err, "delete %s failed", name)
+			}
+		}
+		log.Info("prune backup",
+			zap.String("path", entry.Path),
+			zap.String("mode", entry.Mode),
+			zap.Uint64("end-version", entry.EndVersion),
+			zap.Int("files", entry.Files),
+			zap.Uint64("size", entry.Size))
+	}
+	return nil
+}
+
+// PrintJSON writes the plan as JSON.
+func (plan PrunePlan) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Trace(err)
+}
+
+// PrintTable writes the plan as a human readable table.
+func (plan PrunePlan) PrintTable(w io.Writer) error {
+	var size uint64
+	deleted := plan.Deleted()
+	for _, entry := range deleted {
+		size += entry.Size
+	}
+	fmt.Fprintf(w, "Backups: %d, to delete: %d (%s)\n\n", len(plan), len(deleted), utils.FormatBytes(size))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tPATH\tMODE\tEND TIME\tSIZE\tREASON")
+	for _, action := range plan {
+		op, endTime := "keep", "-"
+		if action.Delete {
+			op = "delete"
+		}
+		if action.Backup.Mode != BackupModeRaw {
+			endTime = action.Backup.EndTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", op, action.Backup.Path, action.Backup.Mode,
+			endTime, utils.FormatBytes(action.Backup.Size), action.Reason)
+	}
+	return errors.Trace(tw.Flush())
+}
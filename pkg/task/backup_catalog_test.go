@@ -0,0 +1,198 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testBackupCatalogSuite{})
+
+type testBackupCatalogSuite struct{}
+
+var catalogNow = time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC)
+
+func daysAgo(days int) uint64 {
+	t := catalogNow.Add(-time.Duration(days) * 24 * time.Hour)
+	return oracle.ComposeTS(t.UnixNano()/int64(time.Millisecond), 0)
+}
+
+func writeCatalogBackup(c *C, ctx context.Context, s storage.ExternalStorage, dir string, meta *backup.BackupMeta) {
+	meta.Files = []*backup.File{{Name: "1.sst", Size_: 4}}
+	c.Assert(s.Write(ctx, path.Join(dir, "1.sst"), []byte("data")), IsNil)
+	data, err := proto.Marshal(meta)
+	c.Assert(err, IsNil)
+	c.Assert(s.Write(ctx, path.Join(dir, utils.MetaFile), data), IsNil)
+	c.Assert(s.Write(ctx, path.Join(dir, utils.BackupReportFile), []byte("{}")), IsNil)
+}
+
+func mockCatalog(c *C, ctx context.Context, s storage.ExternalStorage) {
+	writeCatalogBackup(c, ctx, s, "", &backup.BackupMeta{EndVersion: daysAgo(10)})
+	writeCatalogBackup(c, ctx, s, "inc1", &backup.BackupMeta{StartVersion: daysAgo(10), EndVersion: daysAgo(9)})
+	writeCatalogBackup(c, ctx, s, "inc-orphan", &backup.BackupMeta{StartVersion: daysAgo(20), EndVersion: daysAgo(8)})
+	writeCatalogBackup(c, ctx, s, "2/full", &backup.BackupMeta{EndVersion: daysAgo(3)})
+	writeCatalogBackup(c, ctx, s, "2/inc1", &backup.BackupMeta{StartVersion: daysAgo(3), EndVersion: daysAgo(2)})
+	writeCatalogBackup(c, ctx, s, "2/inc2", &backup.BackupMeta{StartVersion: daysAgo(2), EndVersion: daysAgo(1)})
+	writeCatalogBackup(c, ctx, s, "raw", &backup.BackupMeta{IsRawKv: true})
+}
+
+func planActions(plan PrunePlan) map[string]string {
+	actions := make(map[string]string, len(plan))
+	for _, action := range plan {
+		if action.Delete {
+			actions[action.Backup.Path] = "delete"
+		} else {
+			actions[action.Backup.Path] = action.Reason
+		}
+	}
+	return actions
+}
+
+func (s *testBackupCatalogSuite) TestListBackups(c *C) {
+	ctx := context.Background()
+	_, stg := createLocalStorage(c, ctx)
+	mockCatalog(c, ctx, stg)
+
+	catalog, err := ListBackups(ctx, stg)
+	c.Assert(err, IsNil)
+	var buf bytes.Buffer
+	c.Assert(catalog.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Equals, "PATH        MODE         TS RANGE                                  END TIME              FILES  SIZE  PARENT\n"+
+		"raw         raw          -                                         -                     1      17 B  -\n"+
+		".           full         (0, 417493470412800000]                   2020-06-20T00:00:00Z  1      25 B  -\n"+
+		"inc1        incremental  (417493470412800000, 417516119654400000]  2020-06-21T00:00:00Z  1      35 B  .\n"+
+		"inc-orphan  incremental  (417266977996800000, 417538768896000000]  2020-06-22T00:00:00Z  1      35 B  (missing)\n"+
+		"2/full      full         (0, 417652015104000000]                   2020-06-27T00:00:00Z  1      25 B  -\n"+
+		"2/inc1      incremental  (417652015104000000, 417674664345600000]  2020-06-28T00:00:00Z  1      35 B  2/full\n"+
+		"2/inc2      incremental  (417674664345600000, 417697313587200000]  2020-06-29T00:00:00Z  1      35 B  2/inc1\n")
+}
+
+func (s *testBackupCatalogSuite) TestPruneBackups(c *C) {
+	ctx := context.Background()
+	_, stg := createLocalStorage(c, ctx)
+	mockCatalog(c, ctx, stg)
+	catalog, err := ListBackups(ctx, stg)
+	c.Assert(err, IsNil)
+
+	_, err = PlanPrune(catalog, RetentionPolicy{}, catalogNow)
+	c.Assert(err, ErrorMatches, "the retention policy should keep some backups")
+
+	// The full backup of the incremental backup within the duration is kept.
+	within, err := ParseRetentionDuration("9d")
+	c.Assert(err, IsNil)
+	plan, err := PlanPrune(catalog, RetentionPolicy{KeepWithin: within}, catalogNow)
+	c.Assert(err, IsNil)
+	c.Assert(planActions(plan), DeepEquals, map[string]string{
+		"raw":        "raw kv backups are not pruned",
+		".":          "needed by inc1",
+		"inc1":       "within 216h0m0s",
+		"inc-orphan": "within 216h0m0s",
+		"2/full":     "within 216h0m0s",
+		"2/inc1":     "within 216h0m0s",
+		"2/inc2":     "within 216h0m0s",
+	})
+
+	// The whole chain of the last incremental backup is kept.
+	plan, err = PlanPrune(catalog, RetentionPolicy{KeepLast: 1}, catalogNow)
+	c.Assert(err, IsNil)
+	c.Assert(planActions(plan), DeepEquals, map[string]string{
+		"raw":        "raw kv backups are not pruned",
+		".":          "delete",
+		"inc1":       "delete",
+		"inc-orphan": "delete",
+		"2/full":     "needed by 2/inc1",
+		"2/inc1":     "needed by 2/inc2",
+		"2/inc2":     "one of the last 1 backups",
+	})
+	c.Assert(ExecutePrune(ctx, stg, plan), IsNil)
+
+	catalog, err = ListBackups(ctx, stg)
+	c.Assert(err, IsNil)
+	var paths []string
+	for _, entry := range catalog {
+		paths = append(paths, entry.Path)
+	}
+	c.Assert(paths, DeepEquals, []string{"raw", "2/full", "2/inc1", "2/inc2"})
+	report, err := CheckBackupGC(ctx, stg)
+	c.Assert(err, IsNil)
+	c.Assert(report.Clean(), IsTrue)
+	for _, name := range []string{"1.sst", utils.BackupReportFile, "inc1/1.sst"} {
+		exists, err := stg.FileExists(ctx, name)
+		c.Assert(err, IsNil)
+		c.Assert(exists, IsFalse)
+	}
+}
+
+func (s *testBackupCatalogSuite) TestPruneBackupsWithSiblingPrefix(c *C) {
+	ctx := context.Background()
+	root := c.MkDir()
+	createStorage := func(dir string) storage.ExternalStorage {
+		backend, err := storage.ParseBackend("local://"+path.Join(root, dir), nil)
+		c.Assert(err, IsNil)
+		stg, err := storage.Create(ctx, backend, false)
+		c.Assert(err, IsNil)
+		return stg
+	}
+	stg, sibling := createStorage("backup"), createStorage("backup-old")
+	writeCatalogBackup(c, ctx, stg, "", &backup.BackupMeta{EndVersion: daysAgo(10)})
+	writeCatalogBackup(c, ctx, stg, "new", &backup.BackupMeta{EndVersion: daysAgo(1)})
+	writeCatalogBackup(c, ctx, sibling, "", &backup.BackupMeta{EndVersion: daysAgo(20)})
+	// The files written by restore are deleted along with the backup.
+	for _, name := range []string{utils.RestoreReportFile, "README"} {
+		c.Assert(stg.Write(ctx, name, []byte("{}")), IsNil)
+	}
+
+	catalog, err := ListBackups(ctx, stg)
+	c.Assert(err, IsNil)
+	c.Assert(catalog, HasLen, 2)
+	plan, err := PlanPrune(catalog, RetentionPolicy{KeepLast: 1}, catalogNow)
+	c.Assert(err, IsNil)
+	c.Assert(planActions(plan), DeepEquals, map[string]string{
+		".":   "delete",
+		"new": "one of the last 1 backups",
+	})
+	c.Assert(ExecutePrune(ctx, stg, plan), IsNil)
+
+	for _, name := range []string{"1.sst", utils.MetaFile, utils.RestoreReportFile} {
+		exists, err := stg.FileExists(ctx, name)
+		c.Assert(err, IsNil)
+		c.Assert(exists, IsFalse, Commentf("%s is not deleted", name))
+	}
+	// The file that is not written by BR is kept.
+	exists, err := stg.FileExists(ctx, "README")
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsTrue)
+	// The backup in the sibling prefix is untouched.
+	catalog, err = ListBackups(ctx, sibling)
+	c.Assert(err, IsNil)
+	c.Assert(catalog, HasLen, 1)
+	for _, name := range []string{"1.sst", utils.MetaFile, utils.BackupReportFile} {
+		exists, err := sibling.FileExists(ctx, name)
+		c.Assert(err, IsNil)
+		c.Assert(exists, IsTrue)
+	}
+}
+
+func (s *testBackupCatalogSuite) TestParseRetentionDuration(c *C) {
+	d, err := ParseRetentionDuration("7d")
+	c.Assert(err, IsNil)
+	c.Assert(d, Equals, 7*24*time.Hour)
+	d, err = ParseRetentionDuration("12h")
+	c.Assert(err, IsNil)
+	c.Assert(d, Equals, 12*time.Hour)
+	for _, invalid := range []string{"d", "-1d", "1.5d", "-1h", "week"} {
+		_, err = ParseRetentionDuration(invalid)
+		c.Assert(err, ErrorMatches, "invalid duration "+invalid)
+	}
+}
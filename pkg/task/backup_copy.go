@@ -0,0 +1,382 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagCopyFrom    = "from"
+	flagCopyTo      = "to"
+	flagCopyFileDir = "file-dir"
+
+	defaultCopyConcurrency = 8
+)
+
+// CopyConfig is the configuration specific for copying backups.
+type CopyConfig struct {
+	Config
+
+	From string `json:"from" toml:"from"`
+	To   string `json:"to" toml:"to"`
+	// FileDir is the directory of the backup files in the destination,
+	// relative to the backupmeta. The file names in the backupmeta are
+	// rewritten if it is set.
+	FileDir string `json:"file-dir" toml:"file-dir"`
+}
+
+// DefineCopyFlags defines the flags of copying backups.
+func DefineCopyFlags(flags *pflag.FlagSet) {
+	flags.String(flagCopyFrom, "", `The url of the backup to copy, eg, "local:///nfs/backup"`)
+	flags.String(flagCopyTo, "", `The url to copy the backup to, eg, "s3://bucket/path/prefix"`)
+	flags.String(flagCopyFileDir, "", "The directory of the backup files in the destination, "+
+		"the file names in the backupmeta are rewritten if it is set")
+	RedactURLFlag(flags, flagCopyFrom)
+	RedactURLFlag(flags, flagCopyTo)
+}
+
+// ParseFromFlags parses the copy-related flags from the flag set.
+func (cfg *CopyConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	cfg.From, err = flags.GetString(flagCopyFrom)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.To, err = flags.GetString(flagCopyTo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.From == "" || cfg.To == "" {
+		return errors.Errorf("both --%s and --%s should be specified", flagCopyFrom, flagCopyTo)
+	}
+	cfg.FileDir, err = flags.GetString(flagCopyFileDir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.Config.ParseFromFlags(flags); err != nil {
+		return err
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultCopyConcurrency
+	}
+	return nil
+}
+
+// CopyResult is the summary of copying a backup.
+type CopyResult struct {
+	Files int
+	// Copied is the number of files copied, the others are skipped because
+	// they are already in the destination, e.g. copied before being resumed.
+	Copied      int
+	CopiedBytes uint64
+}
+
+// RunBackupCopy copies the backup files and the backupmeta to the destination
+// storage. The sha256 of every file is verified when it is read from the
+// source, and verified again by reading it back from the destination. The
+// backupmeta is written at last, so the copy can be resumed by running again
+// if it is interrupted, and the files already copied are skipped.
+func RunBackupCopy(c context.Context, cfg *CopyConfig) (*CopyResult, error) {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	fromCfg, toCfg := cfg.Config, cfg.Config
+	fromCfg.Storage, toCfg.Storage = cfg.From, cfg.To
+	_, src, err := GetStorage(ctx, &fromCfg)
+	if err != nil {
+		return nil, errors.Annotate(err, "create the source storage failed")
+	}
+	_, dst, err := GetStorage(ctx, &toCfg)
+	if err != nil {
+		return nil, errors.Annotate(err, "create the destination storage failed")
+	}
+	metaData, err := src.Read(ctx, utils.MetaFile)
+	if err != nil {
+		return nil, errors.Annotate(err, "load backupmeta failed")
+	}
+	meta := &backup.BackupMeta{}
+	if err = proto.Unmarshal(metaData, meta); err != nil {
+		return nil, errors.Annotate(err, "parse backupmeta failed")
+	}
+	newMeta, err := rewriteFileNames(meta, cfg.FileDir)
+	if err != nil {
+		return nil, err
+	}
+	newMetaData, err := proto.Marshal(newMeta)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = checkCopyDestination(ctx, dst, newMetaData); err != nil {
+		return nil, err
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), int(cfg.RateLimit))
+	}
+	result := &CopyResult{Files: len(meta.Files)}
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	pool := utils.NewWorkerPool(uint(cfg.Concurrency), "copy backup")
+	wg := new(sync.WaitGroup)
+	for i, file := range meta.Files {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		srcFile, dstFile := file, newMeta.Files[i]
+		pool.Apply(func() {
+			defer wg.Done()
+			copied, err := copyBackupFile(ctx, src, dst, srcFile, dstFile, limiter)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			if copied {
+				result.Copied++
+				result.CopiedBytes += srcFile.Size_
+			}
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// The checksums of raw kv ranges are used by restore, so they are copied
+	// as well.
+	exists, err := src.FileExists(ctx, utils.RawChecksumFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if exists {
+		data, err := src.Read(ctx, utils.RawChecksumFile)
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot read %s", utils.RawChecksumFile)
+		}
+		if err = dst.Write(ctx, utils.RawChecksumFile, data); err != nil {
+			return nil, errors.Annotatef(err, "cannot write %s", utils.RawChecksumFile)
+		}
+	}
+	if err = dst.Write(ctx, utils.MetaFile, newMetaData); err != nil {
+		return nil, errors.Annotate(err, "write backupmeta failed")
+	}
+	log.Info("copy backup",
+		zap.Int("files", result.Files),
+		zap.Int("copied", result.Copied),
+		zap.Uint64("copied-bytes", result.CopiedBytes))
+	return result, nil
+}
+
+// rewriteFileNames returns the backupmeta with the files moved into the
+// directory. The backupmeta is returned as is if the directory is empty.
+func rewriteFileNames(meta *backup.BackupMeta, dir string) (*backup.BackupMeta, error) {
+	if dir == "" {
+		return meta, nil
+	}
+	newMeta := proto.Clone(meta).(*backup.BackupMeta)
+	names := make(map[string]string, len(newMeta.Files))
+	for _, file := range newMeta.Files {
+		name := path.Join(dir, path.Base(file.Name))
+		if other, ok := names[name]; ok {
+			return nil, errors.Errorf("both %s and %s are moved to %s", other, file.Name, name)
+		}
+		names[name] = file.Name
+		file.Name = name
+	}
+	return newMeta, nil
+}
+
+// checkCopyDestination checks that the destination has no other backup. The
+// destination that has the same backupmeta is the backup copied before.
+func checkCopyDestination(ctx context.Context, dst storage.ExternalStorage, metaData []byte) error {
+	exists, err := dst.FileExists(ctx, utils.MetaFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !exists {
+		return nil
+	}
+	data, err := dst.Read(ctx, utils.MetaFile)
+	if err != nil {
+		return errors.Annotate(err, "load the backupmeta of the destination failed")
+	}
+	if !bytes.Equal(data, metaData) {
+		return errors.New("the destination has another backup")
+	}
+	return nil
+}
+
+// copyBackupFile copies the file from the source to the destination. The file
+// is streamed through a temporary local file, and its sha256 is computed while
+// reading from the source, so the whole file is never held in memory. The file
+// is not copied if it is already in the destination with the size and the
+// sha256 recorded in the backupmeta. It returns whether the file is copied.
+func copyBackupFile(
+	ctx context.Context,
+	src, dst storage.ExternalStorage,
+	srcFile, dstFile *backup.File,
+	limiter *rate.Limiter,
+) (bool, error) {
+	start := time.Now()
+	exists, err := dst.FileExists(ctx, dstFile.Name)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if exists && (dstFile.Size_ != 0 || len(dstFile.Sha256) != 0) {
+		size, sum, err := hashFile(ctx, dst, dstFile.Name, limiter)
+		if err == nil && checkCopiedFile(uint64(size), sum, dstFile) == nil {
+			log.Debug("skip the file already copied", zap.String("name", dstFile.Name))
+			return false, nil
+		}
+	}
+
+	tmp, err := ioutil.TempFile("", "br-copy-")
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	rc, err := src.Open(ctx, srcFile.Name)
+	if err != nil {
+		return false, errors.Annotatef(err, "cannot read %s", srcFile.Name)
+	}
+	reader := &copyReader{ctx: ctx, r: rc, limiter: limiter, hash: sha256.New()}
+	size, err := io.Copy(tmp, reader)
+	rc.Close()
+	if err != nil {
+		return false, errors.Annotatef(err, "cannot read %s", srcFile.Name)
+	}
+	sum := reader.hash.Sum(nil)
+	if err = checkCopiedFile(uint64(size), sum, srcFile); err != nil {
+		return false, errors.Annotate(err, "the source is corrupted")
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return false, errors.Trace(err)
+	}
+	if err = dst.WriteFrom(ctx, dstFile.Name, tmp); err != nil {
+		return false, errors.Annotatef(err, "cannot write %s", dstFile.Name)
+	}
+	// The sha256 of the source is compared, which is the one recorded in the
+	// backupmeta if any.
+	dstSize, dstSum, err := hashFile(ctx, dst, dstFile.Name, limiter)
+	if err != nil {
+		return false, errors.Annotatef(err, "cannot read %s back", dstFile.Name)
+	}
+	if dstSize != size || !bytes.Equal(dstSum, sum) {
+		return false, errors.Errorf("the copy is corrupted: %s has %d bytes with sha256 %s, "+
+			"but %d bytes with sha256 %s are written",
+			dstFile.Name, dstSize, hex.EncodeToString(dstSum), size, hex.EncodeToString(sum))
+	}
+	log.Info("copy backup file",
+		zap.String("from", srcFile.Name),
+		zap.String("to", dstFile.Name),
+		zap.Int64("size", size),
+		zap.Duration("take", time.Since(start)))
+	return true, nil
+}
+
+// hashFile reads the file and returns its size and sha256.
+func hashFile(
+	ctx context.Context, s storage.ExternalStorage, name string, limiter *rate.Limiter,
+) (int64, []byte, error) {
+	rc, err := s.Open(ctx, name)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	defer rc.Close()
+	reader := &copyReader{ctx: ctx, r: rc, limiter: limiter, hash: sha256.New()}
+	size, err := io.Copy(ioutil.Discard, reader)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	return size, reader.hash.Sum(nil), nil
+}
+
+// copyReader computes the sha256 of the data read, and waits for the rate
+// limiter before every read.
+type copyReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+	hash    hash.Hash
+}
+
+func (r *copyReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	if waitErr := waitRateLimit(r.ctx, r.limiter, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+// checkCopiedFile checks the size and the sha256 of the file, they are not
+// checked if they are not recorded by the old versions of TiKV.
+func checkCopiedFile(size uint64, sum []byte, file *backup.File) error {
+	if file.Size_ != 0 && size != file.Size_ {
+		return errors.Errorf("%s has %d bytes, but %d bytes are recorded in backupmeta",
+			file.Name, size, file.Size_)
+	}
+	if len(file.Sha256) == 0 {
+		return nil
+	}
+	if !bytes.Equal(sum, file.Sha256) {
+		return errors.Errorf("%s has sha256 %s, but %s is recorded in backupmeta",
+			file.Name, hex.EncodeToString(sum), hex.EncodeToString(file.Sha256))
+	}
+	return nil
+}
+
+// waitRateLimit waits until n bytes are allowed by the limiter. The bytes are
+// split into bursts, which are at most the bytes allowed in a second.
+func waitRateLimit(ctx context.Context, limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+	for n > 0 {
+		burst := n
+		if burst > limiter.Burst() {
+			burst = limiter.Burst()
+		}
+		if err := limiter.WaitN(ctx, burst); err != nil {
+			return errors.Trace(err)
+		}
+		n -= burst
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testBackupCopySuite{})
+
+type testBackupCopySuite struct{}
+
+func mockCopiedBackup(c *C, ctx context.Context, s storage.ExternalStorage, dir string) *backup.BackupMeta {
+	meta := &backup.BackupMeta{ClusterId: 1, EndVersion: 100}
+	for _, name := range []string{"1_write.sst", "1_default.sst", "2_write.sst"} {
+		data := []byte("data of " + name)
+		sum := sha256.Sum256(data)
+		c.Assert(s.Write(ctx, dir+name, data), IsNil)
+		meta.Files = append(meta.Files, &backup.File{
+			Name:   dir + name,
+			Sha256: sum[:],
+			Size_:  uint64(len(data)),
+		})
+	}
+	data, err := proto.Marshal(meta)
+	c.Assert(err, IsNil)
+	c.Assert(s.Write(ctx, utils.MetaFile, data), IsNil)
+	return meta
+}
+
+func createLocalStorage(c *C, ctx context.Context) (string, storage.ExternalStorage) {
+	url := "local://" + c.MkDir()
+	backend, err := storage.ParseBackend(url, nil)
+	c.Assert(err, IsNil)
+	s, err := storage.Create(ctx, backend, false)
+	c.Assert(err, IsNil)
+	return url, s
+}
+
+func readCopiedMeta(c *C, ctx context.Context, s storage.ExternalStorage) *backup.BackupMeta {
+	data, err := s.Read(ctx, utils.MetaFile)
+	c.Assert(err, IsNil)
+	meta := &backup.BackupMeta{}
+	c.Assert(proto.Unmarshal(data, meta), IsNil)
+	return meta
+}
+
+func (s *testBackupCopySuite) TestBackupCopy(c *C) {
+	ctx := context.Background()
+	fromURL, from := createLocalStorage(c, ctx)
+	toURL, to := createLocalStorage(c, ctx)
+	meta := mockCopiedBackup(c, ctx, from, "")
+	c.Assert(from.Write(ctx, utils.RawChecksumFile, []byte("checksum")), IsNil)
+
+	cfg := &CopyConfig{From: fromURL, To: toURL, Config: Config{Concurrency: 2, RateLimit: 1 << 20}}
+	result, err := RunBackupCopy(ctx, cfg)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, &CopyResult{Files: 3, Copied: 3, CopiedBytes: 59})
+	c.Assert(proto.Equal(readCopiedMeta(c, ctx, to), meta), IsTrue)
+	data, err := to.Read(ctx, utils.RawChecksumFile)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "checksum")
+
+	// The copy is resumed, only the corrupted files are copied again, even if
+	// the size is right.
+	c.Assert(to.Write(ctx, "1_default.sst", []byte("corrupted")), IsNil)
+	c.Assert(to.Write(ctx, "2_write.sst", []byte("data of 2_write.ss!")), IsNil)
+	result, err = RunBackupCopy(ctx, cfg)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, &CopyResult{Files: 3, Copied: 2, CopiedBytes: 40})
+	for _, name := range []string{"1_default.sst", "2_write.sst"} {
+		data, err = to.Read(ctx, name)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, "data of "+name)
+	}
+
+	// The source is corrupted.
+	toURL2, to2 := createLocalStorage(c, ctx)
+	c.Assert(from.Write(ctx, "2_write.sst", []byte("data of 2_write.ss!")), IsNil)
+	_, err = RunBackupCopy(ctx, &CopyConfig{From: fromURL, To: toURL2, Config: Config{Concurrency: 1}})
+	c.Assert(err, ErrorMatches, "the source is corrupted: 2_write.sst has sha256 .*, but .* is recorded in backupmeta")
+	exists, err := to2.FileExists(ctx, "2_write.sst")
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsFalse)
+
+	// The destination has another backup.
+	fromURL3, from3 := createLocalStorage(c, ctx)
+	mockCopiedBackup(c, ctx, from3, "other_")
+	_, err = RunBackupCopy(ctx, &CopyConfig{From: fromURL3, To: toURL, Config: Config{Concurrency: 1}})
+	c.Assert(err, ErrorMatches, "the destination has another backup")
+}
+
+// corruptedStorage corrupts the last byte of the files written.
+type corruptedStorage struct {
+	storage.ExternalStorage
+}
+
+func (s corruptedStorage) WriteFrom(ctx context.Context, name string, r io.ReadSeeker) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data[len(data)-1]++
+	return s.Write(ctx, name, data)
+}
+
+func (s *testBackupCopySuite) TestBackupCopyCorruptedDestination(c *C) {
+	ctx := context.Background()
+	_, from := createLocalStorage(c, ctx)
+	_, to := createLocalStorage(c, ctx)
+	meta := mockCopiedBackup(c, ctx, from, "")
+
+	file := meta.Files[0]
+	_, err := copyBackupFile(ctx, from, corruptedStorage{to}, file, file, nil)
+	c.Assert(err, ErrorMatches, "the copy is corrupted: 1_write.sst has 19 bytes with sha256 .*, "+
+		"but 19 bytes with sha256 .* are written")
+	copied, err := copyBackupFile(ctx, from, to, file, file, nil)
+	c.Assert(err, IsNil)
+	c.Assert(copied, IsTrue)
+	copied, err = copyBackupFile(ctx, from, to, file, file, nil)
+	c.Assert(err, IsNil)
+	c.Assert(copied, IsFalse)
+}
+
+func (s *testBackupCopySuite) TestBackupCopyFileDir(c *C) {
+	ctx := context.Background()
+	fromURL, from := createLocalStorage(c, ctx)
+	toURL, to := createLocalStorage(c, ctx)
+	mockCopiedBackup(c, ctx, from, "")
+
+	cfg := &CopyConfig{From: fromURL, To: toURL, FileDir: "files", Config: Config{Concurrency: 2}}
+	result, err := RunBackupCopy(ctx, cfg)
+	c.Assert(err, IsNil)
+	c.Assert(result.Copied, Equals, 3)
+	meta := readCopiedMeta(c, ctx, to)
+	var names []string
+	for _, file := range meta.Files {
+		names = append(names, file.Name)
+		exists, err := to.FileExists(ctx, file.Name)
+		c.Assert(err, IsNil)
+		c.Assert(exists, IsTrue)
+	}
+	c.Assert(names, DeepEquals, []string{"files/1_write.sst", "files/1_default.sst", "files/2_write.sst"})
+
+	// The files in different directories have the same name.
+	meta.Files[1].Name = "other/1_write.sst"
+	_, err = rewriteFileNames(meta, "files")
+	c.Assert(err, ErrorMatches, "both files/1_write.sst and other/1_write.sst are moved to files/1_write.sst")
+}
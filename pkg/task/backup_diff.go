@@ -0,0 +1,287 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// The relations between the TS ranges of two backups.
+const (
+	TSRangeSame       = "same"
+	TSRangeContiguous = "contiguous"
+	TSRangeGap        = "gap"
+	TSRangeOverlap    = "overlap"
+)
+
+// BackupDiff is the difference between a backup and the other one.
+type BackupDiff struct {
+	// TSRange is the relation between the TS ranges of the backups, ordered by
+	// their end versions.
+	TSRange string `json:"ts-range"`
+	// TSRangeDetail describes the relation, e.g. the gap between the backups.
+	TSRangeDetail string `json:"ts-range-detail"`
+
+	// Added are the tables only in the other backup.
+	Added []string `json:"added"`
+	// Removed are the tables only in the backup.
+	Removed []string `json:"removed"`
+	// Changed are the tables in both backups but with different schemas or
+	// checksums.
+	Changed []TableDiff `json:"changed"`
+
+	// AddedDDLJobs are the DDL jobs only in the other backup.
+	AddedDDLJobs []string `json:"added-ddl-jobs"`
+	// RemovedDDLJobs are the DDL jobs only in the backup.
+	RemovedDDLJobs []string `json:"removed-ddl-jobs"`
+}
+
+// TableDiff is the difference of a table in two backups. The deltas are the
+// values in the other backup minus the ones in the backup.
+type TableDiff struct {
+	Name            string `json:"name"`
+	SchemaChanged   bool   `json:"schema-changed"`
+	ChecksumChanged bool   `json:"checksum-changed"`
+	Crc64Xor        uint64 `json:"crc64xor"`
+	OtherCrc64Xor   uint64 `json:"other-crc64xor"`
+	TotalKVsDelta   int64  `json:"total-kvs-delta"`
+	TotalBytesDelta int64  `json:"total-bytes-delta"`
+	OtherTotalKVs   uint64 `json:"other-total-kvs"`
+	OtherTotalBytes uint64 `json:"other-total-bytes"`
+}
+
+// Equal returns whether the backups have the same tables and DDL jobs.
+func (d *BackupDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		len(d.AddedDDLJobs) == 0 && len(d.RemovedDDLJobs) == 0
+}
+
+// DiffBackups compares the backup with the other one. Only the tables matched
+// by the filter are compared.
+func DiffBackups(meta, other *backup.BackupMeta, tableFilter filter.Filter) (*BackupDiff, error) {
+	if meta.IsRawKv || other.IsRawKv {
+		return nil, errors.New("cannot diff raw kv backups")
+	}
+	diff := &BackupDiff{}
+	diff.TSRange, diff.TSRangeDetail = compareTSRanges(meta, other)
+
+	tables, err := loadFilteredTables(meta, tableFilter)
+	if err != nil {
+		return nil, err
+	}
+	otherTables, err := loadFilteredTables(other, tableFilter)
+	if err != nil {
+		return nil, err
+	}
+	for name, table := range tables {
+		otherTable, ok := otherTables[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, name)
+			continue
+		}
+		tableDiff, err := diffTable(name, table, otherTable)
+		if err != nil {
+			return nil, err
+		}
+		if tableDiff.SchemaChanged || tableDiff.ChecksumChanged {
+			diff.Changed = append(diff.Changed, tableDiff)
+		}
+	}
+	for name := range otherTables {
+		if _, ok := tables[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Name < diff.Changed[j].Name
+	})
+
+	jobs, err := loadDDLJobs(meta)
+	if err != nil {
+		return nil, err
+	}
+	otherJobs, err := loadDDLJobs(other)
+	if err != nil {
+		return nil, err
+	}
+	diff.RemovedDDLJobs = diffDDLJobs(jobs, otherJobs)
+	diff.AddedDDLJobs = diffDDLJobs(otherJobs, jobs)
+	return diff, nil
+}
+
+// tsRange returns the TS range (start, end] of the backup. A full backup
+// contains all the versions before its end version, no matter whether its
+// start version is 0 or the same as its end version.
+func tsRange(meta *backup.BackupMeta) (uint64, uint64) {
+	if backupMode(meta) == BackupModeFull {
+		return 0, meta.EndVersion
+	}
+	return meta.StartVersion, meta.EndVersion
+}
+
+func compareTSRanges(meta, other *backup.BackupMeta) (string, string) {
+	start, end := tsRange(meta)
+	otherStart, otherEnd := tsRange(other)
+	if start == otherStart && end == otherEnd {
+		return TSRangeSame, fmt.Sprintf("both are (%d, %d]", start, end)
+	}
+	// Let the former backup be the one ends earlier.
+	formerStart, formerEnd, latterStart, latterEnd := start, end, otherStart, otherEnd
+	if otherEnd < end {
+		formerStart, formerEnd, latterStart, latterEnd = otherStart, otherEnd, start, end
+	}
+	switch {
+	case latterStart == formerEnd:
+		return TSRangeContiguous, fmt.Sprintf("(%d, %d] is followed by (%d, %d]",
+			formerStart, formerEnd, latterStart, latterEnd)
+	case latterStart > formerEnd:
+		return TSRangeGap, fmt.Sprintf("(%d, %d] is missing between (%d, %d] and (%d, %d]",
+			formerEnd, latterStart, formerStart, formerEnd, latterStart, latterEnd)
+	default:
+		return TSRangeOverlap, fmt.Sprintf("(%d, %d] is in both (%d, %d] and (%d, %d]",
+			latterStart, formerEnd, formerStart, formerEnd, latterStart, latterEnd)
+	}
+}
+
+func loadFilteredTables(meta *backup.BackupMeta, tableFilter filter.Filter) (map[string]*utils.Table, error) {
+	dbs, err := utils.LoadBackupTables(meta)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]*utils.Table)
+	for _, db := range dbs {
+		for _, table := range db.Tables {
+			if tableFilter.MatchTable(db.Info.Name.O, table.Info.Name.O) {
+				name := utils.EncloseName(db.Info.Name.O) + "." + utils.EncloseName(table.Info.Name.O)
+				tables[name] = table
+			}
+		}
+	}
+	return tables, nil
+}
+
+func diffTable(name string, table, other *utils.Table) (TableDiff, error) {
+	schema, err := normalizedSchema(table.Info)
+	if err != nil {
+		return TableDiff{}, err
+	}
+	otherSchema, err := normalizedSchema(other.Info)
+	if err != nil {
+		return TableDiff{}, err
+	}
+	return TableDiff{
+		Name:          name,
+		SchemaChanged: !bytes.Equal(schema, otherSchema),
+		ChecksumChanged: table.Crc64Xor != other.Crc64Xor ||
+			table.TotalKvs != other.TotalKvs || table.TotalBytes != other.TotalBytes,
+		Crc64Xor:        table.Crc64Xor,
+		OtherCrc64Xor:   other.Crc64Xor,
+		TotalKVsDelta:   int64(other.TotalKvs) - int64(table.TotalKvs),
+		TotalBytesDelta: int64(other.TotalBytes) - int64(table.TotalBytes),
+		OtherTotalKVs:   other.TotalKvs,
+		OtherTotalBytes: other.TotalBytes,
+	}, nil
+}
+
+// normalizedSchema encodes the table info without the fields which change
+// without DDL, e.g. the auto increment ID.
+func normalizedSchema(info *model.TableInfo) ([]byte, error) {
+	normalized := info.Clone()
+	normalized.ID = 0
+	normalized.AutoIncID = 0
+	normalized.AutoRandID = 0
+	normalized.UpdateTS = 0
+	normalized.TiFlashReplica = nil
+	data, err := json.Marshal(normalized)
+	return data, errors.Trace(err)
+}
+
+func loadDDLJobs(meta *backup.BackupMeta) ([]*model.Job, error) {
+	if len(meta.Ddls) == 0 {
+		return nil, nil
+	}
+	var jobs []*model.Job
+	if err := json.Unmarshal(meta.Ddls, &jobs); err != nil {
+		return nil, errors.Annotate(err, "parse ddl jobs failed")
+	}
+	return jobs, nil
+}
+
+// diffDDLJobs returns the descriptions of the jobs not in the other jobs.
+func diffDDLJobs(jobs, other []*model.Job) []string {
+	ids := make(map[int64]struct{}, len(other))
+	for _, job := range other {
+		ids[job.ID] = struct{}{}
+	}
+	var diff []string
+	for _, job := range jobs {
+		if _, ok := ids[job.ID]; !ok {
+			diff = append(diff, fmt.Sprintf("%d %s: %s", job.ID, job.Type, job.Query))
+		}
+	}
+	return diff
+}
+
+// PrintJSON writes the difference as JSON.
+func (d *BackupDiff) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Trace(err)
+}
+
+// PrintTable writes the difference as human readable text.
+func (d *BackupDiff) PrintTable(w io.Writer) error {
+	fmt.Fprintf(w, "TS Range: %s, %s\n", d.TSRange, d.TSRangeDetail)
+	if d.Equal() {
+		fmt.Fprintln(w, "The backups have the same tables and DDL jobs.")
+		return nil
+	}
+	for _, name := range d.Removed {
+		fmt.Fprintf(w, "- %s\n", name)
+	}
+	for _, name := range d.Added {
+		fmt.Fprintf(w, "+ %s\n", name)
+	}
+	for _, job := range d.RemovedDDLJobs {
+		fmt.Fprintf(w, "- DDL job %s\n", job)
+	}
+	for _, job := range d.AddedDDLJobs {
+		fmt.Fprintf(w, "+ DDL job %s\n", job)
+	}
+	if len(d.Changed) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tSCHEMA\tCRC64XOR\tKVS\tBYTES")
+	for _, t := range d.Changed {
+		schema := "same"
+		if t.SchemaChanged {
+			schema = "changed"
+		}
+		crc := "same"
+		if t.Crc64Xor != t.OtherCrc64Xor {
+			crc = fmt.Sprintf("%d -> %d", t.Crc64Xor, t.OtherCrc64Xor)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d (%+d)\t%d (%+d)\n", t.Name, schema, crc,
+			t.OtherTotalKVs, t.TotalKVsDelta, t.OtherTotalBytes, t.TotalBytesDelta)
+	}
+	return errors.Trace(tw.Flush())
+}
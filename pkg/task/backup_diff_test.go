@@ -0,0 +1,131 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+)
+
+var _ = Suite(&testBackupDiffSuite{})
+
+type testBackupDiffSuite struct{}
+
+func (s *testBackupDiffSuite) TestDiffBackupsEqual(c *C) {
+	f, err := filter.Parse([]string{"*.*"})
+	c.Assert(err, IsNil)
+	meta, other := mockBackupMeta(c), mockBackupMeta(c)
+	// The auto increment ID changes without DDL.
+	var table model.TableInfo
+	c.Assert(json.Unmarshal(other.Schemas[0].Table, &table), IsNil)
+	table.AutoIncID = 100
+	other.Schemas[0].Table, err = json.Marshal(&table)
+	c.Assert(err, IsNil)
+
+	diff, err := DiffBackups(meta, other, f)
+	c.Assert(err, IsNil)
+	c.Assert(diff.Equal(), IsTrue)
+	c.Assert(diff.TSRange, Equals, TSRangeSame)
+
+	var buf bytes.Buffer
+	c.Assert(diff.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Equals, "TS Range: same, both are (0, 417000000000000000]\n"+
+		"The backups have the same tables and DDL jobs.\n")
+}
+
+func (s *testBackupDiffSuite) TestDiffBackups(c *C) {
+	f, err := filter.Parse([]string{"*.*"})
+	c.Assert(err, IsNil)
+	meta, other := mockBackupMeta(c), mockBackupMeta(c)
+	// t2 has more data and a new column.
+	var table model.TableInfo
+	c.Assert(json.Unmarshal(other.Schemas[0].Table, &table), IsNil)
+	table.Columns = append(table.Columns, &model.ColumnInfo{Name: model.NewCIStr("c")})
+	other.Schemas[0].Table, err = json.Marshal(&table)
+	c.Assert(err, IsNil)
+	other.Schemas[0].Crc64Xor = 42
+	other.Schemas[0].TotalKvs += 10
+	other.Schemas[0].TotalBytes -= 100
+	// other.s is removed and test.t3 is added.
+	other.Schemas[3].Table, err = json.Marshal(&model.TableInfo{ID: 5, Name: model.NewCIStr("t3")})
+	c.Assert(err, IsNil)
+	other.Schemas[3].Db = other.Schemas[0].Db
+	meta.Ddls = []byte(`[{"id":1,"type":3,"query":"create table t2 (a int)"},{"id":2}]`)
+	other.Ddls = []byte(`[{"id":2},{"id":3,"type":5,"query":"alter table t2 add column c int"}]`)
+
+	diff, err := DiffBackups(meta, other, f)
+	c.Assert(err, IsNil)
+	c.Assert(diff.Equal(), IsFalse)
+	c.Assert(diff.Added, DeepEquals, []string{"`test`.`t3`"})
+	c.Assert(diff.Removed, DeepEquals, []string{"`other`.`s`"})
+	c.Assert(diff.Changed, DeepEquals, []TableDiff{{
+		Name:            "`test`.`t2`",
+		SchemaChanged:   true,
+		ChecksumChanged: true,
+		OtherCrc64Xor:   42,
+		TotalKVsDelta:   10,
+		TotalBytesDelta: -100,
+		OtherTotalKVs:   110,
+		OtherTotalBytes: 900,
+	}})
+	c.Assert(diff.RemovedDDLJobs, DeepEquals, []string{"1 create table: create table t2 (a int)"})
+	c.Assert(diff.AddedDDLJobs, DeepEquals, []string{"3 add column: alter table t2 add column c int"})
+
+	var buf bytes.Buffer
+	c.Assert(diff.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Matches, "(?s).*"+
+		"- `other`.`s`\n"+
+		"\\+ `test`.`t3`\n"+
+		"- DDL job 1 create table: create table t2 \\(a int\\)\n"+
+		"\\+ DDL job 3 add column: alter table t2 add column c int\n"+
+		"\n"+
+		"TABLE        SCHEMA   CRC64XOR  KVS        BYTES\n"+
+		"`test`.`t2`  changed  0 -> 42   110 \\(\\+10\\)  900 \\(-100\\)\n")
+
+	buf.Reset()
+	c.Assert(diff.PrintJSON(&buf), IsNil)
+	var decoded BackupDiff
+	c.Assert(json.Unmarshal(buf.Bytes(), &decoded), IsNil)
+	c.Assert(&decoded, DeepEquals, diff)
+
+	// Only the filtered tables are compared.
+	f, err = filter.Parse([]string{"test.t1"})
+	c.Assert(err, IsNil)
+	meta.Ddls, other.Ddls = nil, nil
+	diff, err = DiffBackups(meta, other, f)
+	c.Assert(err, IsNil)
+	c.Assert(diff.Equal(), IsTrue)
+
+	_, err = DiffBackups(meta, &backup.BackupMeta{IsRawKv: true}, f)
+	c.Assert(err, ErrorMatches, "cannot diff raw kv backups")
+}
+
+func (s *testBackupDiffSuite) TestCompareTSRanges(c *C) {
+	cases := []struct {
+		start, end, otherStart, otherEnd uint64
+		relation                         string
+		detail                           string
+	}{
+		{0, 10, 10, 10, TSRangeSame, "both are (0, 10]"},
+		{10, 10, 0, 10, TSRangeSame, "both are (0, 10]"},
+		{0, 10, 0, 10, TSRangeSame, "both are (0, 10]"},
+		{0, 10, 5, 20, TSRangeOverlap, "(5, 10] is in both (0, 10] and (5, 20]"},
+		{0, 10, 10, 20, TSRangeContiguous, "(0, 10] is followed by (10, 20]"},
+		{10, 20, 0, 10, TSRangeContiguous, "(0, 10] is followed by (10, 20]"},
+		{0, 10, 15, 20, TSRangeGap, "(10, 15] is missing between (0, 10] and (15, 20]"},
+		{5, 10, 0, 20, TSRangeOverlap, "(0, 10] is in both (5, 10] and (0, 20]"},
+		{0, 10, 0, 20, TSRangeOverlap, "(0, 10] is in both (0, 10] and (0, 20]"},
+	}
+	for _, ca := range cases {
+		relation, detail := compareTSRanges(
+			&backup.BackupMeta{StartVersion: ca.start, EndVersion: ca.end},
+			&backup.BackupMeta{StartVersion: ca.otherStart, EndVersion: ca.otherEnd})
+		c.Assert(relation, Equals, ca.relation, Commentf("%+v", ca))
+		c.Assert(detail, Equals, ca.detail, Commentf("%+v", ca))
+	}
+}
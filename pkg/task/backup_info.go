@@ -0,0 +1,213 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// The modes of backups.
+const (
+	BackupModeRaw         = "raw"
+	BackupModeFull        = "full"
+	BackupModeIncremental = "incremental"
+)
+
+// The types of tables.
+const (
+	TableTypeTable    = "table"
+	TableTypeView     = "view"
+	TableTypeSequence = "sequence"
+)
+
+// BackupInfo is the summary of the content of a backup.
+type BackupInfo struct {
+	Mode           string    `json:"mode"`
+	ClusterID      uint64    `json:"cluster-id"`
+	ClusterVersion string    `json:"cluster-version"`
+	StartVersion   uint64    `json:"start-version"`
+	EndVersion     uint64    `json:"end-version"`
+	EndTime        time.Time `json:"end-time"`
+	Files          int       `json:"files"`
+	Size           uint64    `json:"size"`
+	DDLJobs        int       `json:"ddl-jobs,omitempty"`
+
+	Databases []DatabaseInfo `json:"databases,omitempty"`
+	RawRanges []RawRangeInfo `json:"raw-ranges,omitempty"`
+}
+
+// DatabaseInfo is the summary of a database in a backup.
+type DatabaseInfo struct {
+	Name   string      `json:"name"`
+	Tables []TableInfo `json:"tables"`
+}
+
+// TableInfo is the summary of a table, view or sequence in a backup.
+type TableInfo struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	TotalKVs        uint64 `json:"total-kvs"`
+	TotalBytes      uint64 `json:"total-bytes"`
+	Size            uint64 `json:"size"`
+	Files           int    `json:"files"`
+	TiFlashReplicas int    `json:"tiflash-replicas"`
+}
+
+// RawRangeInfo is a range of a raw kv backup, the keys are in hex.
+type RawRangeInfo struct {
+	StartKey string `json:"start-key"`
+	EndKey   string `json:"end-key"`
+	CF       string `json:"cf"`
+}
+
+// NewBackupInfo summarizes the backup. Only the tables matched by the filter
+// are included.
+func NewBackupInfo(meta *backup.BackupMeta, tableFilter filter.Filter) (*BackupInfo, error) {
+	info := &BackupInfo{
+		ClusterID:      meta.ClusterId,
+		ClusterVersion: meta.ClusterVersion,
+		StartVersion:   meta.StartVersion,
+		EndVersion:     meta.EndVersion,
+		EndTime:        oracle.GetTimeFromTS(meta.EndVersion).UTC(),
+		Files:          len(meta.Files),
+		Size:           utils.ArchiveSize(meta),
+	}
+	info.Mode = backupMode(meta)
+	switch info.Mode {
+	case BackupModeRaw:
+		// The versions are ignored by raw kv backups.
+		info.StartVersion, info.EndVersion, info.EndTime = 0, 0, time.Time{}
+		for _, r := range meta.RawRanges {
+			info.RawRanges = append(info.RawRanges, RawRangeInfo{
+				StartKey: hex.EncodeToString(r.StartKey),
+				EndKey:   hex.EncodeToString(r.EndKey),
+				CF:       r.Cf,
+			})
+		}
+		return info, nil
+	case BackupModeIncremental:
+		if len(meta.Ddls) > 0 {
+			var jobs []*model.Job
+			if err := json.Unmarshal(meta.Ddls, &jobs); err != nil {
+				return nil, errors.Annotate(err, "parse ddl jobs failed")
+			}
+			info.DDLJobs = len(jobs)
+		}
+	}
+
+	dbs, err := utils.LoadBackupTables(meta)
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		dbInfo := DatabaseInfo{Name: db.Info.Name.O, Tables: make([]TableInfo, 0, len(db.Tables))}
+		for _, table := range db.Tables {
+			if !tableFilter.MatchTable(db.Info.Name.O, table.Info.Name.O) {
+				continue
+			}
+			tableInfo := TableInfo{
+				Name:            table.Info.Name.O,
+				Type:            TableTypeTable,
+				TotalKVs:        table.TotalKvs,
+				TotalBytes:      table.TotalBytes,
+				Files:           len(table.Files),
+				TiFlashReplicas: table.TiFlashReplicas,
+			}
+			switch {
+			case table.Info.IsView():
+				tableInfo.Type = TableTypeView
+			case table.Info.IsSequence():
+				tableInfo.Type = TableTypeSequence
+			}
+			for _, file := range table.Files {
+				tableInfo.Size += file.Size_
+			}
+			dbInfo.Tables = append(dbInfo.Tables, tableInfo)
+		}
+		if len(dbInfo.Tables) == 0 {
+			continue
+		}
+		sort.Slice(dbInfo.Tables, func(i, j int) bool {
+			return dbInfo.Tables[i].Name < dbInfo.Tables[j].Name
+		})
+		info.Databases = append(info.Databases, dbInfo)
+	}
+	sort.Slice(info.Databases, func(i, j int) bool {
+		return info.Databases[i].Name < info.Databases[j].Name
+	})
+	return info, nil
+}
+
+// backupMode returns whether the backup is a raw kv, full or incremental one.
+func backupMode(meta *backup.BackupMeta) string {
+	switch {
+	case meta.IsRawKv:
+		return BackupModeRaw
+	case meta.StartVersion != 0 && meta.StartVersion != meta.EndVersion:
+		return BackupModeIncremental
+	default:
+		return BackupModeFull
+	}
+}
+
+// PrintJSON writes the summary as JSON.
+func (info *BackupInfo) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Trace(err)
+}
+
+// PrintTable writes the summary as human readable tables.
+func (info *BackupInfo) PrintTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Mode:\t%s\n", info.Mode)
+	fmt.Fprintf(tw, "Cluster ID:\t%d\n", info.ClusterID)
+	fmt.Fprintf(tw, "Cluster Version:\t%s\n", info.ClusterVersion)
+	if info.Mode != BackupModeRaw {
+		fmt.Fprintf(tw, "TS Range:\t(%d, %d]\n", info.StartVersion, info.EndVersion)
+		fmt.Fprintf(tw, "End Time:\t%s\n", info.EndTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "Files:\t%d\n", info.Files)
+	fmt.Fprintf(tw, "Size:\t%s\n", utils.FormatBytes(info.Size))
+	if info.Mode == BackupModeIncremental {
+		fmt.Fprintf(tw, "DDL Jobs:\t%d\n", info.DDLJobs)
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if info.Mode == BackupModeRaw {
+		fmt.Fprintln(tw, "START KEY\tEND KEY\tCF")
+		for _, r := range info.RawRanges {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.StartKey, r.EndKey, r.CF)
+		}
+		return errors.Trace(tw.Flush())
+	}
+	fmt.Fprintln(tw, "DATABASE\tTABLE\tTYPE\tKVS\tBYTES\tSIZE\tFILES\tTIFLASH REPLICAS")
+	for _, db := range info.Databases {
+		for _, t := range db.Tables {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%d\t%d\n", db.Name, t.Name, t.Type,
+				t.TotalKVs, t.TotalBytes, utils.FormatBytes(t.Size), t.Files, t.TiFlashReplicas)
+		}
+	}
+	return errors.Trace(tw.Flush())
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/tablecodec"
+)
+
+var _ = Suite(&testBackupInfoSuite{})
+
+type testBackupInfoSuite struct{}
+
+func mockBackupMeta(c *C) *backup.BackupMeta {
+	meta := &backup.BackupMeta{ClusterId: 1, ClusterVersion: `"4.0.0"`, EndVersion: 417000000000000000}
+	addTable := func(db string, table *model.TableInfo, replicas uint32, files ...*backup.File) {
+		dbData, err := json.Marshal(&model.DBInfo{Name: model.NewCIStr(db)})
+		c.Assert(err, IsNil)
+		tableData, err := json.Marshal(table)
+		c.Assert(err, IsNil)
+		var kvs, bytes uint64
+		for _, f := range files {
+			kvs += f.TotalKvs
+			bytes += f.TotalBytes
+		}
+		meta.Schemas = append(meta.Schemas, &backup.Schema{
+			Db: dbData, Table: tableData, TotalKvs: kvs, TotalBytes: bytes, TiflashReplicas: replicas,
+		})
+		meta.Files = append(meta.Files, files...)
+	}
+	file := func(tableID int64, kvs uint64) *backup.File {
+		return &backup.File{
+			Name:       "file.sst",
+			StartKey:   tablecodec.GenTableRecordPrefix(tableID),
+			EndKey:     tablecodec.GenTableRecordPrefix(tableID + 1),
+			TotalKvs:   kvs,
+			TotalBytes: kvs * 10,
+			Size_:      kvs * 2,
+		}
+	}
+	addTable("test", &model.TableInfo{ID: 2, Name: model.NewCIStr("t2")}, 0, file(2, 100))
+	addTable("test", &model.TableInfo{ID: 1, Name: model.NewCIStr("t1")}, 1, file(1, 1000), file(1, 24))
+	addTable("test", &model.TableInfo{ID: 3, Name: model.NewCIStr("v"), View: &model.ViewInfo{}}, 0)
+	addTable("other", &model.TableInfo{ID: 4, Name: model.NewCIStr("s"), Sequence: &model.SequenceInfo{}}, 0)
+	return meta
+}
+
+func (s *testBackupInfoSuite) TestNewBackupInfo(c *C) {
+	meta := mockBackupMeta(c)
+	f, err := filter.Parse([]string{"*.*"})
+	c.Assert(err, IsNil)
+	info, err := NewBackupInfo(meta, f)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode, Equals, BackupModeFull)
+	c.Assert(info.Files, Equals, 3)
+	c.Assert(info.Databases, DeepEquals, []DatabaseInfo{
+		{Name: "other", Tables: []TableInfo{{Name: "s", Type: TableTypeSequence}}},
+		{Name: "test", Tables: []TableInfo{
+			{Name: "t1", Type: TableTypeTable, TotalKVs: 1024, TotalBytes: 10240, Size: 2048, Files: 2, TiFlashReplicas: 1},
+			{Name: "t2", Type: TableTypeTable, TotalKVs: 100, TotalBytes: 1000, Size: 200, Files: 1},
+			{Name: "v", Type: TableTypeView},
+		}},
+	})
+
+	var buf bytes.Buffer
+	c.Assert(info.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Matches, `(?s)Mode:             full
+Cluster ID:       1
+.*
+DATABASE  TABLE  TYPE      KVS   BYTES  SIZE      FILES  TIFLASH REPLICAS
+other     s      sequence  0     0      0 B       0      0
+test      t1     table     1024  10240  2.00 KiB  2      1
+test      t2     table     100   1000   200 B     1      0
+test      v      view      0     0      0 B       0      0
+`)
+
+	f, err = filter.Parse([]string{"test.t*"})
+	c.Assert(err, IsNil)
+	meta.StartVersion = meta.EndVersion - 1
+	meta.Ddls = []byte(`[{"id":1},{"id":2}]`)
+	info, err = NewBackupInfo(meta, f)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode, Equals, BackupModeIncremental)
+	c.Assert(info.DDLJobs, Equals, 2)
+	c.Assert(info.Databases, HasLen, 1)
+	c.Assert(info.Databases[0].Tables, HasLen, 2)
+
+	buf.Reset()
+	c.Assert(info.PrintJSON(&buf), IsNil)
+	var decoded BackupInfo
+	c.Assert(json.Unmarshal(buf.Bytes(), &decoded), IsNil)
+	c.Assert(decoded.Databases, DeepEquals, info.Databases)
+}
+
+func (s *testBackupInfoSuite) TestNewBackupInfoRaw(c *C) {
+	meta := &backup.BackupMeta{
+		IsRawKv:   true,
+		RawRanges: []*backup.RawRange{{StartKey: []byte("a"), EndKey: []byte("z"), Cf: "default"}},
+	}
+	info, err := NewBackupInfo(meta, nil)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode, Equals, BackupModeRaw)
+	c.Assert(info.RawRanges, DeepEquals, []RawRangeInfo{{StartKey: "61", EndKey: "7a", CF: "default"}})
+
+	var buf bytes.Buffer
+	c.Assert(info.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Matches, `(?s).*START KEY  END KEY  CF
+61         7a       default
+`)
+}
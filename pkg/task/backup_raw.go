@@ -5,16 +5,24 @@ package task
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
 	kvproto "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/backup"
+	"github.com/pingcap/br/pkg/checksum"
 	"github.com/pingcap/br/pkg/conn"
 	"github.com/pingcap/br/pkg/glue"
-	"github.com/pingcap/br/pkg/rtree"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/utils"
@@ -25,23 +33,47 @@ const (
 	flagTiKVColumnFamily = "cf"
 	flagStartKey         = "start"
 	flagEndKey           = "end"
+	flagRawRange         = "range"
+	flagRawRangeFile     = "range-file"
 )
 
+// RawKeyRange is a range of raw kv keys, the start key is inclusive and the
+// end key is exclusive.
+type RawKeyRange struct {
+	StartKey []byte `json:"start-key" toml:"start-key"`
+	EndKey   []byte `json:"end-key" toml:"end-key"`
+}
+
 // RawKvConfig is the common config for rawkv backup and restore.
 type RawKvConfig struct {
 	Config
 
+	// Ranges are sorted and not overlapped.
+	Ranges []RawKeyRange `json:"ranges" toml:"ranges"`
+	CFs    []string      `json:"cfs" toml:"cfs"`
+
+	// Deprecated: use Ranges instead. They are folded into Ranges when the
+	// task starts if Ranges is empty.
 	StartKey []byte `json:"start-key" toml:"start-key"`
-	EndKey   []byte `json:"end-key" toml:"end-key"`
-	CF       string `json:"cf" toml:"cf"`
+	// Deprecated: use Ranges instead.
+	EndKey []byte `json:"end-key" toml:"end-key"`
+	// Deprecated: use CFs instead. It's folded into CFs when the task starts
+	// if CFs is empty.
+	CF string `json:"cf" toml:"cf"`
 }
 
 // DefineRawBackupFlags defines common flags for the backup command.
 func DefineRawBackupFlags(command *cobra.Command) {
 	command.Flags().StringP(flagKeyFormat, "", "hex", "start/end key format, support raw|escaped|hex")
-	command.Flags().StringP(flagTiKVColumnFamily, "", "default", "backup specify cf, correspond to tikv cf")
+	command.Flags().StringSliceP(flagTiKVColumnFamily, "", []string{"default"},
+		"backup specify cf, correspond to tikv cf, can be repeated to backup several cfs")
+	setConfigKey(command.Flags(), flagTiKVColumnFamily, "cfs")
 	command.Flags().StringP(flagStartKey, "", "", "backup raw kv start key, key is inclusive")
 	command.Flags().StringP(flagEndKey, "", "", "backup raw kv end key, key is exclusive")
+	command.Flags().StringArray(flagRawRange, nil,
+		"backup raw kv range in the form of start,end, can be repeated to backup several ranges")
+	command.Flags().String(flagRawRangeFile, "",
+		"the file contains raw kv ranges to backup, one range in the form of start,end per line")
 }
 
 // ParseFromFlags parses the backup-related flags from the flag set.
@@ -54,40 +86,162 @@ func (cfg *RawKvConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return err
 	}
-	cfg.StartKey, err = utils.ParseKey(format, start)
+	end, err := flags.GetString(flagEndKey)
 	if err != nil {
 		return err
 	}
-	end, err := flags.GetString(flagEndKey)
+	rangeArgs, err := flags.GetStringArray(flagRawRange)
 	if err != nil {
 		return err
 	}
-	cfg.EndKey, err = utils.ParseKey(format, end)
+	rangeFile, err := flags.GetString(flagRawRangeFile)
 	if err != nil {
 		return err
 	}
+	if len(rangeFile) != 0 {
+		lines, err := readRawRangeFile(rangeFile)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		rangeArgs = append(rangeArgs, lines...)
+	}
 
-	if bytes.Compare(cfg.StartKey, cfg.EndKey) >= 0 {
-		return errors.New("endKey must be greater than startKey")
+	cfg.Ranges = cfg.Ranges[:0]
+	if len(rangeArgs) != 0 {
+		if len(start) != 0 || len(end) != 0 {
+			return errors.Errorf("--%s and --%s cannot be used together with --%s or --%s",
+				flagStartKey, flagEndKey, flagRawRange, flagRawRangeFile)
+		}
+		for _, arg := range rangeArgs {
+			r, err := parseRawKeyRange(format, arg)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			cfg.Ranges = append(cfg.Ranges, r)
+		}
+	} else {
+		var r RawKeyRange
+		r.StartKey, err = utils.ParseKey(format, start)
+		if err != nil {
+			return err
+		}
+		r.EndKey, err = utils.ParseKey(format, end)
+		if err != nil {
+			return err
+		}
+		cfg.Ranges = append(cfg.Ranges, r)
+	}
+	if err = sortRawKeyRanges(cfg.Ranges); err != nil {
+		return errors.Trace(err)
 	}
 
-	cfg.CF, err = flags.GetString(flagTiKVColumnFamily)
+	cfg.CFs, err = flags.GetStringSlice(flagTiKVColumnFamily)
 	if err != nil {
 		return err
 	}
+	if len(cfg.CFs) == 0 {
+		return errors.Errorf("--%s must not be empty", flagTiKVColumnFamily)
+	}
 	if err = cfg.Config.ParseFromFlags(flags); err != nil {
 		return errors.Trace(err)
 	}
 	return nil
 }
 
+// readRawRangeFile reads the raw kv ranges from the file, empty lines and
+// lines starting with '#' are ignored.
+func readRawRangeFile(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to read raw range file %s", path)
+	}
+	ranges := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ranges = append(ranges, line)
+	}
+	return ranges, nil
+}
+
+// parseRawKeyRange parses a raw kv range in the form of "start,end".
+func parseRawKeyRange(format, rawRange string) (RawKeyRange, error) {
+	parts := strings.SplitN(rawRange, ",", 2)
+	if len(parts) != 2 {
+		return RawKeyRange{}, errors.Errorf("invalid raw range %q, it should be in the form of start,end", rawRange)
+	}
+	startKey, err := utils.ParseKey(format, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return RawKeyRange{}, errors.Trace(err)
+	}
+	endKey, err := utils.ParseKey(format, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return RawKeyRange{}, errors.Trace(err)
+	}
+	return RawKeyRange{StartKey: startKey, EndKey: endKey}, nil
+}
+
+// sortRawKeyRanges sorts the ranges by the start key, and checks that every
+// range is valid and no ranges overlap.
+func sortRawKeyRanges(ranges []RawKeyRange) error {
+	for _, r := range ranges {
+		if bytes.Compare(r.StartKey, r.EndKey) >= 0 {
+			return errors.New("endKey must be greater than startKey")
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].StartKey, ranges[j].StartKey) < 0
+	})
+	for i := 1; i < len(ranges); i++ {
+		if bytes.Compare(ranges[i].StartKey, ranges[i-1].EndKey) < 0 {
+			return errors.Errorf("raw ranges overlapped: [%s, %s), [%s, %s)",
+				hex.EncodeToString(ranges[i-1].StartKey), hex.EncodeToString(ranges[i-1].EndKey),
+				hex.EncodeToString(ranges[i].StartKey), hex.EncodeToString(ranges[i].EndKey))
+		}
+	}
+	return nil
+}
+
+// foldDeprecatedFields moves the deprecated StartKey, EndKey and CF into
+// Ranges and CFs, for the callers which set the config directly instead of
+// parsing it from the flags.
+func (cfg *RawKvConfig) foldDeprecatedFields() error {
+	if len(cfg.StartKey) != 0 || len(cfg.EndKey) != 0 {
+		if len(cfg.Ranges) != 0 {
+			return errors.New("StartKey and EndKey cannot be used together with Ranges")
+		}
+		cfg.Ranges = []RawKeyRange{{StartKey: cfg.StartKey, EndKey: cfg.EndKey}}
+		cfg.StartKey, cfg.EndKey = nil, nil
+	}
+	if len(cfg.CF) != 0 {
+		if len(cfg.CFs) != 0 {
+			return errors.New("CF cannot be used together with CFs")
+		}
+		cfg.CFs = []string{cfg.CF}
+		cfg.CF = ""
+	}
+	if len(cfg.CFs) == 0 {
+		// The empty CF was the default CF.
+		cfg.CFs = []string{"default"}
+	}
+	return errors.Trace(sortRawKeyRanges(cfg.Ranges))
+}
+
 // RunBackupRaw starts a backup task inside the current goroutine.
-func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConfig) error {
+func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConfig) (err error) {
 	defer summary.Summary(cmdName)
+	defer func() {
+		writeReport(c, &cfg.Config, cmdName, utils.BackupReportFile, err)
+	}()
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
-	u, err := storage.ParseBackend(cfg.Storage, &cfg.BackendOptions)
+	if err = cfg.foldDeprecatedFields(); err != nil {
+		return err
+	}
+	u, s, err := GetStorage(ctx, &cfg.Config)
 	if err != nil {
 		return err
 	}
@@ -96,6 +250,7 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 		return err
 	}
 	defer mgr.Close()
+	collectClusterVersion(ctx, mgr.GetPDClient())
 
 	client, err := backup.NewBackupClient(ctx, mgr)
 	if err != nil {
@@ -105,13 +260,16 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 		return err
 	}
 
-	backupRange := rtree.Range{StartKey: cfg.StartKey, EndKey: cfg.EndKey}
-
 	// The number of regions need to backup
-	approximateRegions, err := mgr.GetRegionCount(ctx, backupRange.StartKey, backupRange.EndKey)
-	if err != nil {
-		return err
+	approximateRegions := 0
+	for _, r := range cfg.Ranges {
+		regions, err := mgr.GetRegionCount(ctx, r.StartKey, r.EndKey)
+		if err != nil {
+			return err
+		}
+		approximateRegions += regions
 	}
+	approximateRegions *= len(cfg.CFs)
 
 	summary.CollectInt("backup total regions", approximateRegions)
 
@@ -126,21 +284,45 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 		RateLimit:    cfg.RateLimit,
 		Concurrency:  cfg.Concurrency,
 		IsRawKv:      true,
-		Cf:           cfg.CF,
 	}
 
-	err = client.BackupRange(ctx, backupRange.StartKey, backupRange.EndKey, req, updateCh)
-	if err != nil {
-		return err
+	// Every range of every cf is recorded as a raw range in the backup meta.
+	for _, cf := range cfg.CFs {
+		req.Cf = cf
+		for _, r := range cfg.Ranges {
+			err = client.BackupRange(ctx, r.StartKey, r.EndKey, req, updateCh)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	// Backup has finished
 	updateCh.Close()
 
-	// Checksum
+	// Checksum by scanning the backed up ranges.
+	var checksums []rawRangeChecksum
+	if cfg.Checksum {
+		updateCh = g.StartProgress(
+			ctx, "Checksum", int64(len(cfg.CFs)*len(cfg.Ranges)), !cfg.LogProgress)
+		checksums, err = calculateRawChecksums(ctx, mgr, cfg, updateCh)
+		if err != nil {
+			return err
+		}
+		// Checksum has finished
+		updateCh.Close()
+	} else {
+		log.Info("Skip raw checksum because user requirement.")
+	}
+
 	err = client.SaveBackupMeta(ctx, nil)
 	if err != nil {
 		return err
 	}
+	if len(checksums) != 0 {
+		if err = saveRawChecksums(ctx, s, checksums); err != nil {
+			return err
+		}
+	}
 
 	g.Record("Size", client.ArchiveSize())
 
@@ -148,3 +330,78 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 	summary.SetSuccessStatus(true)
 	return nil
 }
+
+// rawRangeChecksum is the checksum of a backed up raw range. The raw ranges in
+// backupmeta have no field for checksum, so the checksums are saved in
+// utils.RawChecksumFile next to backupmeta.
+type rawRangeChecksum struct {
+	StartKey []byte `json:"start-key"`
+	EndKey   []byte `json:"end-key"`
+	Cf       string `json:"cf"`
+	checksum.RawChecksum
+}
+
+func calculateRawChecksums(
+	ctx context.Context,
+	mgr *conn.Mgr,
+	cfg *RawKvConfig,
+	updateCh glue.Progress,
+) ([]rawRangeChecksum, error) {
+	start := time.Now()
+	defer func() {
+		summary.CollectDuration("backup checksum", time.Since(start))
+	}()
+	checksums := make([]rawRangeChecksum, 0, len(cfg.CFs)*len(cfg.Ranges))
+	for _, cf := range cfg.CFs {
+		for _, r := range cfg.Ranges {
+			rangeChecksum, err := checksum.CalculateRawChecksum(ctx, mgr.GetTiKV(), r.StartKey, r.EndKey, cf, nil)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			log.Info("raw range checksum",
+				zap.Binary("startKey", r.StartKey),
+				zap.Binary("endKey", r.EndKey),
+				zap.String("cf", cf),
+				zap.Uint64("crc64xor", rangeChecksum.Crc64Xor),
+				zap.Uint64("totalKvs", rangeChecksum.TotalKvs),
+				zap.Uint64("totalBytes", rangeChecksum.TotalBytes))
+			checksums = append(checksums, rawRangeChecksum{
+				StartKey:    r.StartKey,
+				EndKey:      r.EndKey,
+				Cf:          cf,
+				RawChecksum: rangeChecksum,
+			})
+			glue.IncWithWeight(updateCh, rangeChecksum.TotalKvs, rangeChecksum.TotalBytes)
+		}
+	}
+	return checksums, nil
+}
+
+func saveRawChecksums(ctx context.Context, s storage.ExternalStorage, checksums []rawRangeChecksum) error {
+	data, err := json.Marshal(checksums)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Annotate(s.Write(ctx, utils.RawChecksumFile, data), "save raw checksums failed")
+}
+
+// loadRawChecksums loads the checksums of raw ranges, it returns nil if the
+// backup was taken without checksum.
+func loadRawChecksums(ctx context.Context, s storage.ExternalStorage) ([]rawRangeChecksum, error) {
+	exist, err := s.FileExists(ctx, utils.RawChecksumFile)
+	if err != nil {
+		return nil, errors.Annotatef(err, "error occurred when checking %s file", utils.RawChecksumFile)
+	}
+	if !exist {
+		return nil, nil
+	}
+	data, err := s.Read(ctx, utils.RawChecksumFile)
+	if err != nil {
+		return nil, errors.Annotate(err, "load raw checksums failed")
+	}
+	var checksums []rawRangeChecksum
+	if err = json.Unmarshal(data, &checksums); err != nil {
+		return nil, errors.Annotate(err, "parse raw checksums failed")
+	}
+	return checksums, nil
+}
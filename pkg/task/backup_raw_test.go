@@ -0,0 +1,77 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testBackupRawSuite{})
+
+type testBackupRawSuite struct{}
+
+func (s *testBackupRawSuite) TestParseRawKeyRange(c *C) {
+	r, err := parseRawKeyRange("hex", "61, 62")
+	c.Assert(err, IsNil)
+	c.Assert(r, DeepEquals, RawKeyRange{StartKey: []byte("a"), EndKey: []byte("b")})
+
+	_, err = parseRawKeyRange("hex", "61")
+	c.Assert(err, ErrorMatches, ".*it should be in the form of start,end")
+	_, err = parseRawKeyRange("hex", "zz,62")
+	c.Assert(err, NotNil)
+}
+
+func (s *testBackupRawSuite) TestSortRawKeyRanges(c *C) {
+	ranges := []RawKeyRange{
+		{StartKey: []byte("c"), EndKey: []byte("d")},
+		{StartKey: []byte("a"), EndKey: []byte("b")},
+		{StartKey: []byte("b"), EndKey: []byte("c")},
+	}
+	c.Assert(sortRawKeyRanges(ranges), IsNil)
+	c.Assert(ranges, DeepEquals, []RawKeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("b")},
+		{StartKey: []byte("b"), EndKey: []byte("c")},
+		{StartKey: []byte("c"), EndKey: []byte("d")},
+	})
+
+	ranges = append(ranges, RawKeyRange{StartKey: []byte("a1"), EndKey: []byte("a2")})
+	c.Assert(sortRawKeyRanges(ranges), ErrorMatches, "raw ranges overlapped.*")
+
+	ranges = []RawKeyRange{{StartKey: []byte("b"), EndKey: []byte("a")}}
+	c.Assert(sortRawKeyRanges(ranges), ErrorMatches, "endKey must be greater than startKey")
+}
+
+func (s *testBackupRawSuite) TestFoldDeprecatedFields(c *C) {
+	cfg := &RawKvConfig{StartKey: []byte("a"), EndKey: []byte("b"), CF: "write"}
+	c.Assert(cfg.foldDeprecatedFields(), IsNil)
+	c.Assert(cfg.Ranges, DeepEquals, []RawKeyRange{{StartKey: []byte("a"), EndKey: []byte("b")}})
+	c.Assert(cfg.CFs, DeepEquals, []string{"write"})
+	c.Assert(cfg.StartKey, IsNil)
+	c.Assert(cfg.CF, Equals, "")
+	// Folding again changes nothing.
+	c.Assert(cfg.foldDeprecatedFields(), IsNil)
+	c.Assert(cfg.Ranges, HasLen, 1)
+
+	cfg = &RawKvConfig{
+		Ranges:   []RawKeyRange{{StartKey: []byte("a"), EndKey: []byte("b")}},
+		StartKey: []byte("c"),
+	}
+	c.Assert(cfg.foldDeprecatedFields(), ErrorMatches, "StartKey and EndKey cannot be used together with Ranges")
+	cfg = &RawKvConfig{CFs: []string{"default"}, CF: "write"}
+	c.Assert(cfg.foldDeprecatedFields(), ErrorMatches, "CF cannot be used together with CFs")
+}
+
+func (s *testBackupRawSuite) TestReadRawRangeFile(c *C) {
+	path := filepath.Join(c.MkDir(), "ranges")
+	content := "# ranges to backup\n61,62\n\n  63,64  \n"
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	ranges, err := readRawRangeFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(ranges, DeepEquals, []string{"61,62", "63,64"})
+
+	_, err = readRawRangeFile(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, ErrorMatches, "failed to read raw range file.*")
+}
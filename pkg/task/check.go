@@ -0,0 +1,482 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pingcap/errors"
+	kvproto "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/backup"
+	"github.com/pingcap/br/pkg/conn"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// The results of the preflight checks.
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+const (
+	checkPD             = "pd"
+	checkClusterVersion = "cluster-version"
+	checkStorage        = "storage"
+	checkBackupMeta     = "backupmeta"
+	checkBackupFiles    = "backup-files"
+	checkGCSafePoint    = "gc-safepoint"
+	checkCapacity       = "capacity"
+	checkTables         = "tables"
+	checkTiFlash        = "tiflash"
+	checkPlacementRules = "placement-rules"
+
+	checkProbeFilePrefix = "br-check-probe-"
+	// minGCSafePointGap is the minimal duration between the GC safepoint and
+	// the backup ts. GC may pass the backup ts before the backup starts if the
+	// gap is smaller.
+	minGCSafePointGap = 10 * time.Minute
+	// defaultMaxReplicas is the default max-replicas of PD.
+	defaultMaxReplicas = 3
+	// lowSpaceRatio is the same as the default low-space-ratio of PD, a store
+	// is in low space if its used space is larger than the ratio of capacity.
+	lowSpaceRatio = 0.8
+	// maxListedTables is the max number of tables listed in a check message.
+	maxListedTables = 10
+)
+
+// CheckResult is the result of a preflight check.
+type CheckResult struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Message string `json:"message"`
+}
+
+// CheckReport is the results of the preflight checks of a task.
+type CheckReport struct {
+	Results []CheckResult `json:"results"`
+}
+
+func (r *CheckReport) add(name, result, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Info("preflight check", zap.String("name", name),
+		zap.String("result", result), zap.String("message", message))
+	r.Results = append(r.Results, CheckResult{Name: name, Result: result, Message: message})
+}
+
+func (r *CheckReport) pass(name, format string, args ...interface{}) {
+	r.add(name, CheckPass, format, args...)
+}
+
+func (r *CheckReport) warn(name, format string, args ...interface{}) {
+	r.add(name, CheckWarn, format, args...)
+}
+
+func (r *CheckReport) fail(name, format string, args ...interface{}) {
+	r.add(name, CheckFail, format, args...)
+}
+
+func (r *CheckReport) has(result string) bool {
+	for _, res := range r.Results {
+		if res.Result == result {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns whether any check fails.
+func (r *CheckReport) Failed() bool {
+	return r.has(CheckFail)
+}
+
+// Warned returns whether any check warns.
+func (r *CheckReport) Warned() bool {
+	return r.has(CheckWarn)
+}
+
+// Print writes the results as a table.
+func (r *CheckReport) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tMESSAGE")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", res.Name, res.Result, res.Message)
+	}
+	return errors.Trace(tw.Flush())
+}
+
+// RunCheckBackup runs the preflight checks of a backup task without backing
+// up any data.
+func RunCheckBackup(c context.Context, g glue.Glue, cfg *BackupConfig) *CheckReport {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	report := &CheckReport{}
+
+	if s := checkStorageAccess(ctx, report, &cfg.Config, true); s != nil {
+		exists, err := s.FileExists(ctx, utils.MetaFile)
+		switch {
+		case err != nil:
+			report.fail(checkBackupMeta, "cannot check the backupmeta: %v", err)
+		case exists:
+			report.fail(checkBackupMeta, "backupmeta already exists in the storage, please use another path")
+		default:
+			report.pass(checkBackupMeta, "no backupmeta in the storage")
+		}
+	}
+
+	mgr := checkPDAccess(ctx, report, g, &cfg.Config)
+	if mgr == nil {
+		return report
+	}
+	defer mgr.Close()
+	checkVersion(ctx, report, mgr)
+	checkBackupTS(ctx, report, mgr, cfg)
+	tiflashStores := checkTiFlashStores(ctx, report, mgr)
+	if tiflashStores > 0 {
+		report.pass(checkTiFlash, "%d TiFlash stores are skipped by backup", tiflashStores)
+	}
+	return report
+}
+
+// RunCheckRestore runs the preflight checks of a restore task without
+// restoring any data.
+func RunCheckRestore(c context.Context, g glue.Glue, cfg *RestoreConfig) *CheckReport {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	report := &CheckReport{}
+
+	var backupMeta *kvproto.BackupMeta
+	// Restore only reads the storage, so write access is not required.
+	if s := checkStorageAccess(ctx, report, &cfg.Config, false); s != nil {
+		var err error
+		var u *kvproto.StorageBackend
+		u, _, backupMeta, err = ReadBackupMeta(ctx, utils.MetaFile, &cfg.Config)
+		switch {
+		case err != nil:
+			report.fail(checkBackupMeta, "%v", err)
+			backupMeta = nil
+		case backupMeta.IsRawKv:
+			report.fail(checkBackupMeta, "cannot do transactional restore from raw kv data")
+			backupMeta = nil
+		default:
+			report.pass(checkBackupMeta, "backup ts %d, %d files of %s",
+				backupMeta.EndVersion, len(backupMeta.Files), utils.FormatBytes(utils.ArchiveSize(backupMeta)))
+		}
+		switch {
+		case backupMeta == nil || (!cfg.VerifyFiles && !cfg.VerifySHA256):
+		case !canVerifyFiles(u):
+			report.pass(checkBackupFiles, "skipped, the backup files in local storage are on the TiKV nodes")
+		default:
+			checkFiles(ctx, report, s, backupMeta, cfg)
+		}
+	}
+
+	mgr := checkPDAccess(ctx, report, g, &cfg.Config)
+	if mgr == nil {
+		return report
+	}
+	defer mgr.Close()
+	checkVersion(ctx, report, mgr)
+	if backupMeta != nil {
+		checkStoreCapacity(ctx, report, mgr, utils.ArchiveSize(backupMeta))
+		checkTargetTables(report, mgr, backupMeta, cfg)
+	}
+
+	tiflashStores := checkTiFlashStores(ctx, report, mgr)
+	switch {
+	case tiflashStores == 0:
+	case cfg.RemoveTiFlash:
+		report.pass(checkTiFlash,
+			"%d TiFlash stores, the TiFlash replicas are removed during restore and recovered after it", tiflashStores)
+	default:
+		report.warn(checkTiFlash,
+			"%d TiFlash stores, the restore may fail on unsupported versions of TiFlash unless --%s is set",
+			tiflashStores, flagRemoveTiFlash)
+	}
+	checkPlacement(report, mgr, cfg)
+	return report
+}
+
+// checkFiles checks whether the backup files to restore are missing or corrupt.
+func checkFiles(
+	ctx context.Context, report *CheckReport, s storage.ExternalStorage, backupMeta *kvproto.BackupMeta, cfg *RestoreConfig,
+) {
+	dbs, err := utils.LoadBackupTables(backupMeta)
+	if err != nil {
+		report.fail(checkBackupFiles, "cannot load the tables in the backup: %v", err)
+		return
+	}
+	var files []*kvproto.File
+	for _, db := range dbs {
+		for _, table := range db.Tables {
+			if cfg.TableFilter.MatchTable(db.Info.Name.O, table.Info.Name.O) {
+				files = append(files, table.Files...)
+			}
+		}
+	}
+	err = restore.VerifyBackupFiles(ctx, s, files, cfg.VerifyFilesConcurrency, cfg.VerifySHA256)
+	if err != nil {
+		report.fail(checkBackupFiles, "%v", err)
+		return
+	}
+	report.pass(checkBackupFiles, "%d files to restore are intact", len(files))
+}
+
+// checkStorageAccess checks whether the storage is accessible. If write is
+// true, a probe file is written, read and deleted in the storage. It returns
+// nil if the storage is not accessible.
+func checkStorageAccess(ctx context.Context, report *CheckReport, cfg *Config, write bool) storage.ExternalStorage {
+	u, s, err := GetStorage(ctx, cfg)
+	if err != nil {
+		report.fail(checkStorage, "%v", err)
+		return nil
+	}
+	if u.GetNoop() != nil {
+		report.pass(checkStorage, "noop storage")
+		return s
+	}
+	if !write {
+		if _, err = s.FileExists(ctx, utils.MetaFile); err != nil {
+			report.fail(checkStorage, "cannot access the storage: %v", err)
+			return nil
+		}
+		report.pass(checkStorage, "the storage is accessible")
+		return s
+	}
+	name := fmt.Sprintf("%s%d", checkProbeFilePrefix, time.Now().UnixNano())
+	data := []byte(name)
+	if err = s.Write(ctx, name, data); err != nil {
+		report.fail(checkStorage, "cannot write %s: %v", name, err)
+		return nil
+	}
+	read, err := s.Read(ctx, name)
+	if err != nil {
+		report.fail(checkStorage, "cannot read %s: %v", name, err)
+		return nil
+	}
+	if !bytes.Equal(read, data) {
+		report.fail(checkStorage, "the content of %s read from the storage is different from the written one", name)
+		return nil
+	}
+	if err = s.DeleteFile(ctx, name); err != nil {
+		report.warn(checkStorage, "cannot delete %s, please delete it manually: %v", name, err)
+		return s
+	}
+	report.pass(checkStorage, "write, read and delete are allowed")
+	return s
+}
+
+func checkPDAccess(ctx context.Context, report *CheckReport, g glue.Glue, cfg *Config) *conn.Mgr {
+	// The requirements are checked by checkVersion, so they don't stop the
+	// other checks.
+	mgr, err := newMgr(ctx, g, cfg.PD, cfg.TLS, conn.SkipTiFlash, false)
+	if err != nil {
+		report.fail(checkPD, "cannot connect to the cluster: %v", err)
+		return nil
+	}
+	report.pass(checkPD, "connected to %s", strings.Join(cfg.PD, ","))
+	return mgr
+}
+
+func checkVersion(ctx context.Context, report *CheckReport, mgr *conn.Mgr) {
+	if err := utils.CheckClusterVersion(ctx, mgr.GetPDClient()); err != nil {
+		report.fail(checkClusterVersion, "%v", err)
+		return
+	}
+	report.pass(checkClusterVersion, "TiKV is compatible with BR %s", utils.BRReleaseVersion)
+}
+
+// checkBackupTS checks whether the backup ts and the last backup ts are newer
+// than the GC safepoint.
+func checkBackupTS(ctx context.Context, report *CheckReport, mgr *conn.Mgr, cfg *BackupConfig) {
+	client, err := backup.NewBackupClient(ctx, mgr)
+	if err != nil {
+		report.fail(checkGCSafePoint, "%v", err)
+		return
+	}
+	backupTS, err := client.GetTS(ctx, cfg.TimeAgo, cfg.BackupTS)
+	if err != nil {
+		report.fail(checkGCSafePoint, "%v", err)
+		return
+	}
+	if cfg.LastBackupTS > 0 && backupTS < cfg.LastBackupTS {
+		report.fail(checkGCSafePoint, "last backup ts %d is larger than backup ts %d", cfg.LastBackupTS, backupTS)
+		return
+	}
+	safePoint, err := backup.GetGCSafePoint(ctx, mgr.GetPDClient())
+	if err != nil {
+		report.warn(checkGCSafePoint, "cannot get GC safepoint: %v", err)
+		return
+	}
+	if cfg.LastBackupTS > 0 && cfg.LastBackupTS <= safePoint {
+		report.fail(checkGCSafePoint, "GC safepoint %d exceeds last backup ts %d", safePoint, cfg.LastBackupTS)
+		return
+	}
+	result, message := compareGCSafePoint(backupTS, safePoint)
+	report.add(checkGCSafePoint, result, "%s", message)
+}
+
+func compareGCSafePoint(backupTS, safePoint uint64) (string, string) {
+	if backupTS <= safePoint {
+		return CheckFail, fmt.Sprintf("GC safepoint %d exceeds backup ts %d", safePoint, backupTS)
+	}
+	gap := oracle.GetTimeFromTS(backupTS).Sub(oracle.GetTimeFromTS(safePoint))
+	if gap < minGCSafePointGap {
+		return CheckWarn, fmt.Sprintf(
+			"backup ts %d is only %s after GC safepoint %d, GC may exceed it before the backup starts, "+
+				"please use a larger GC life time or a smaller timeago", backupTS, gap, safePoint)
+	}
+	return CheckPass, fmt.Sprintf("backup ts %d is %s after GC safepoint %d", backupTS, gap, safePoint)
+}
+
+// checkStoreCapacity checks whether the TiKV stores have enough space for the
+// replicas of the backup data.
+func checkStoreCapacity(ctx context.Context, report *CheckReport, mgr *conn.Mgr, size uint64) {
+	stores, err := mgr.GetStoresStatus(ctx)
+	if err != nil {
+		report.warn(checkCapacity, "cannot get store status: %v", err)
+		return
+	}
+	replicas, err := mgr.GetMaxReplicas(ctx)
+	if err != nil || replicas == 0 {
+		log.Warn("cannot get max replicas, use the default one", zap.Error(err))
+		replicas = defaultMaxReplicas
+	}
+	result, message := compareCapacity(stores, size, replicas)
+	report.add(checkCapacity, result, "%s", message)
+}
+
+func compareCapacity(stores []conn.StoreStatus, size, replicas uint64) (string, string) {
+	var capacity, available uint64
+	for _, s := range stores {
+		if s.TiFlash || s.State != metapb.StoreState_Up {
+			continue
+		}
+		capacity += s.Capacity
+		available += s.Available
+	}
+	required := size * replicas
+	if available < required {
+		return CheckFail, fmt.Sprintf("%d replicas of %s need %s, but only %s is available on TiKV",
+			replicas, utils.FormatBytes(size), utils.FormatBytes(required), utils.FormatBytes(available))
+	}
+	used := float64(capacity) - float64(available-required)
+	if used > float64(capacity)*lowSpaceRatio {
+		return CheckWarn, fmt.Sprintf("%d replicas of %s need %s of %s available on TiKV, "+
+			"the stores would be in low space after restore",
+			replicas, utils.FormatBytes(size), utils.FormatBytes(required), utils.FormatBytes(available))
+	}
+	return CheckPass, fmt.Sprintf("%d replicas of %s need %s of %s available on TiKV",
+		replicas, utils.FormatBytes(size), utils.FormatBytes(required), utils.FormatBytes(available))
+}
+
+// checkTargetTables checks whether the tables to restore exist in the cluster.
+// They must not exist unless the backup is incremental or the schemas are not
+// created by BR.
+func checkTargetTables(report *CheckReport, mgr *conn.Mgr, backupMeta *kvproto.BackupMeta, cfg *RestoreConfig) {
+	if backupMeta.StartVersion != 0 && backupMeta.StartVersion != backupMeta.EndVersion {
+		report.pass(checkTables, "incremental backup is restored to the existing tables")
+		return
+	}
+	dbs, err := utils.LoadBackupTables(backupMeta)
+	if err != nil {
+		report.fail(checkTables, "cannot load the tables in the backup: %v", err)
+		return
+	}
+	ver, err := mgr.GetTiKV().CurrentVersion()
+	if err != nil {
+		report.fail(checkTables, "%v", err)
+		return
+	}
+	info, err := utils.GetSnapshotInfoSchema(mgr.GetDomain(), mgr.GetTiKV(), ver.Ver)
+	if err != nil {
+		report.fail(checkTables, "cannot load the schemas of the cluster: %v", err)
+		return
+	}
+
+	var conflicts, missing []string
+	total := 0
+	for _, db := range dbs {
+		for _, table := range db.Tables {
+			if !cfg.TableFilter.MatchTable(db.Info.Name.O, table.Info.Name.O) {
+				continue
+			}
+			total++
+			exists := info.TableExists(db.Info.Name, table.Info.Name)
+			name := utils.EncloseName(db.Info.Name.O) + "." + utils.EncloseName(table.Info.Name.O)
+			switch {
+			case exists && !cfg.NoSchema:
+				conflicts = append(conflicts, name)
+			case !exists && cfg.NoSchema:
+				missing = append(missing, name)
+			}
+		}
+	}
+	switch {
+	case len(conflicts) > 0:
+		report.fail(checkTables, "%d tables already exist: %s", len(conflicts), listTables(conflicts))
+	case len(missing) > 0:
+		report.fail(checkTables, "%d tables don't exist: %s", len(missing), listTables(missing))
+	default:
+		report.pass(checkTables, "%d tables to restore", total)
+	}
+}
+
+// checkTiFlashStores returns the number of TiFlash stores.
+func checkTiFlashStores(ctx context.Context, report *CheckReport, mgr *conn.Mgr) int {
+	stores, err := conn.GetAllTiKVStores(ctx, mgr.GetPDClient(), conn.TiFlashOnly)
+	if err != nil {
+		report.warn(checkTiFlash, "cannot get TiFlash stores: %v", err)
+		return 0
+	}
+	if len(stores) == 0 {
+		report.pass(checkTiFlash, "no TiFlash stores")
+	}
+	return len(stores)
+}
+
+// checkPlacement checks whether placement rules are enabled, which is required
+// by online restore.
+func checkPlacement(report *CheckReport, mgr *conn.Mgr, cfg *RestoreConfig) {
+	var lastErr error
+	for _, pdAddr := range cfg.PD {
+		rules, err := utils.GetPlacementRules(pdAddr, mgr.GetTLSConfig())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// PD returns no rules if placement rules are disabled.
+		enabled := len(rules) > 0
+		switch {
+		case enabled:
+			report.pass(checkPlacementRules, "placement rules are enabled")
+		case cfg.Online:
+			report.fail(checkPlacementRules, "online restore requires placement rules, please enable them in PD")
+		default:
+			report.pass(checkPlacementRules, "placement rules are disabled, they are not required by offline restore")
+		}
+		return
+	}
+	report.warn(checkPlacementRules, "cannot get placement rules: %v", lastErr)
+}
+
+func listTables(tables []string) string {
+	if len(tables) <= maxListedTables {
+		return strings.Join(tables, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(tables[:maxListedTables], ", "), len(tables)-maxListedTables)
+}
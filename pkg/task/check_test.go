@@ -0,0 +1,108 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/tidb/store/tikv/oracle"
+
+	"github.com/pingcap/br/pkg/conn"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testCheckSuite{})
+
+type testCheckSuite struct{}
+
+func (s *testCheckSuite) TestCheckReport(c *C) {
+	report := &CheckReport{}
+	report.pass(checkPD, "connected")
+	c.Assert(report.Failed(), IsFalse)
+	c.Assert(report.Warned(), IsFalse)
+	report.warn(checkTiFlash, "%d TiFlash stores", 2)
+	c.Assert(report.Failed(), IsFalse)
+	c.Assert(report.Warned(), IsTrue)
+	report.fail(checkCapacity, "no space")
+	c.Assert(report.Failed(), IsTrue)
+
+	var buf bytes.Buffer
+	c.Assert(report.Print(&buf), IsNil)
+	c.Assert(buf.String(), Equals, ""+
+		"CHECK     RESULT  MESSAGE\n"+
+		"pd        pass    connected\n"+
+		"tiflash   warn    2 TiFlash stores\n"+
+		"capacity  fail    no space\n")
+}
+
+func (s *testCheckSuite) TestCheckStorageAccess(c *C) {
+	ctx := context.Background()
+	dir := c.MkDir()
+	report := &CheckReport{}
+	cfg := &Config{Storage: "local://" + dir}
+	c.Assert(checkStorageAccess(ctx, report, cfg, true), NotNil)
+	c.Assert(report.Results, HasLen, 1)
+	c.Assert(report.Results[0].Result, Equals, CheckPass)
+	c.Assert(report.Results[0].Message, Equals, "write, read and delete are allowed")
+	// The probe file is deleted.
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	// Write access isn't checked for restore.
+	report = &CheckReport{}
+	c.Assert(checkStorageAccess(ctx, report, cfg, false), NotNil)
+	c.Assert(report.Results, HasLen, 1)
+	c.Assert(report.Results[0].Result, Equals, CheckPass)
+	c.Assert(report.Results[0].Message, Equals, "the storage is accessible")
+
+	report = &CheckReport{}
+	cfg = &Config{Storage: "unknown://" + dir}
+	c.Assert(checkStorageAccess(ctx, report, cfg, true), IsNil)
+	c.Assert(report.Failed(), IsTrue)
+}
+
+func (s *testCheckSuite) TestCompareGCSafePoint(c *C) {
+	now := time.Now()
+	backupTS := oracle.ComposeTS(oracle.GetPhysical(now), 0)
+
+	result, _ := compareGCSafePoint(backupTS, backupTS)
+	c.Assert(result, Equals, CheckFail)
+	result, _ = compareGCSafePoint(backupTS, oracle.ComposeTS(oracle.GetPhysical(now.Add(-time.Minute)), 0))
+	c.Assert(result, Equals, CheckWarn)
+	result, msg := compareGCSafePoint(backupTS, oracle.ComposeTS(oracle.GetPhysical(now.Add(-time.Hour)), 0))
+	c.Assert(result, Equals, CheckPass)
+	c.Assert(msg, Matches, ".* is 1h0m0s after GC safepoint .*")
+}
+
+func (s *testCheckSuite) TestCompareCapacity(c *C) {
+	stores := []conn.StoreStatus{
+		{ID: 1, State: metapb.StoreState_Up, Capacity: 100 * utils.GB, Available: 80 * utils.GB},
+		{ID: 2, State: metapb.StoreState_Up, Capacity: 100 * utils.GB, Available: 80 * utils.GB},
+		{ID: 3, State: metapb.StoreState_Up, Capacity: 100 * utils.GB, Available: 80 * utils.GB},
+		// The TiFlash stores and the offline stores are ignored.
+		{ID: 4, State: metapb.StoreState_Up, TiFlash: true, Capacity: utils.TB, Available: utils.TB},
+		{ID: 5, State: metapb.StoreState_Offline, Capacity: utils.TB, Available: utils.TB},
+	}
+	result, _ := compareCapacity(stores, 10*utils.GB, 3)
+	c.Assert(result, Equals, CheckPass)
+	result, _ = compareCapacity(stores, 70*utils.GB, 3)
+	c.Assert(result, Equals, CheckWarn)
+	result, msg := compareCapacity(stores, 100*utils.GB, 3)
+	c.Assert(result, Equals, CheckFail)
+	c.Assert(msg, Equals, "3 replicas of 100.00 GiB need 300.00 GiB, but only 240.00 GiB is available on TiKV")
+}
+
+func (s *testCheckSuite) TestListTables(c *C) {
+	c.Assert(listTables([]string{"`a`.`b`", "`a`.`c`"}), Equals, "`a`.`b`, `a`.`c`")
+	tables := make([]string, maxListedTables+2)
+	for i := range tables {
+		tables[i] = "t"
+	}
+	c.Assert(listTables(tables), Matches, "t(, t){9} and 2 more")
+}
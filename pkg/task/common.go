@@ -52,6 +52,18 @@ const (
 	flagCaseSensitive    = "case-sensitive"
 	flagRemoveTiFlash    = "remove-tiflash"
 	flagCheckRequirement = "check-requirements"
+	flagReportFile       = "report-file"
+	flagReportToStorage  = "report-to-storage"
+
+	// flagAnnotationRedact is the annotation of the flags whose values are
+	// redacted when logging the arguments.
+	flagAnnotationRedact = "br-redact"
+	redactSecret         = "secret"
+	redactURLQuery       = "url-query"
+
+	// flagAnnotationConfigKey is the annotation of the flags whose keys in the
+	// config file are different from their names.
+	flagAnnotationConfigKey = "br-config-key"
 )
 
 // TLSConfig is the common configuration for TLS connection.
@@ -108,18 +120,29 @@ type Config struct {
 	TableFilter       filter.Filter `json:"-" toml:"-"`
 	RemoveTiFlash     bool          `json:"remove-tiflash" toml:"remove-tiflash"`
 	CheckRequirements bool          `json:"check-requirements" toml:"check-requirements"`
+
+	// ReportFile is the local path to write the JSON report of the task.
+	ReportFile string `json:"report-file" toml:"report-file"`
+	// ReportToStorage is true means the JSON report is written to the storage next to the backupmeta.
+	ReportToStorage bool `json:"report-to-storage" toml:"report-to-storage"`
 }
 
 // DefineCommonFlags defines the flags common to all BRIE commands.
 func DefineCommonFlags(flags *pflag.FlagSet) {
+	flags.String(flagConfigFile, "", "Path of the TOML config file, "+
+		"the values in it are overridden by the flags set in the command line")
 	flags.BoolP(flagSendCreds, "c", true, "Whether send credentials to tikv")
 	flags.StringP(flagStorage, "s", "", `specify the url where backup storage, eg, "s3://bucket/path/prefix"`)
 	flags.StringSliceP(flagPD, "u", []string{"127.0.0.1:2379"}, "PD address")
 	flags.String(flagCA, "", "CA certificate path for TLS connection")
 	flags.String(flagCert, "", "Certificate path for TLS connection")
 	flags.String(flagKey, "", "Private key path for TLS connection")
+	setConfigKey(flags, flagCA, "tls.ca")
+	setConfigKey(flags, flagCert, "tls.cert")
+	setConfigKey(flags, flagKey, "tls.key")
 
 	flags.Uint64(flagRateLimit, 0, "The rate limit of the task, MB/s per node")
+	setConfigKey(flags, flagRateLimit, "rate-limit")
 	flags.Bool(flagChecksum, true, "Run checksum at end of task")
 	flags.Bool(flagRemoveTiFlash, true,
 		"Remove TiFlash replicas before backup or restore, for unsupported versions of TiFlash")
@@ -136,6 +159,9 @@ func DefineCommonFlags(flags *pflag.FlagSet) {
 	flags.Bool(flagCheckRequirement, true,
 		"Whether start version check before execute command")
 
+	flags.String(flagReportFile, "", "The path to write the JSON report of the task. If not set, no report is written")
+	flags.Bool(flagReportToStorage, false, "Whether write the JSON report of the task to the storage")
+
 	storage.DefineFlags(flags)
 }
 
@@ -260,6 +286,15 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	}
 	cfg.CheckRequirements = checkRequirements
 
+	cfg.ReportFile, err = flags.GetString(flagReportFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.ReportToStorage, err = flags.GetBool(flagReportToStorage)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
 	if err := cfg.BackendOptions.ParseFromFlags(flags); err != nil {
 		return err
 	}
@@ -340,10 +375,29 @@ func ReadBackupMeta(
 	return u, s, backupMeta, nil
 }
 
+// RedactFlag marks the flag as a secret, e.g. a password, its value is not
+// logged by LogArguments.
+func RedactFlag(flags *pflag.FlagSet, name string) {
+	_ = flags.SetAnnotation(name, flagAnnotationRedact, []string{redactSecret})
+}
+
+// RedactURLFlag marks the flag as a storage URL, the query of its value is not
+// logged by LogArguments, since it may contain the credentials.
+func RedactURLFlag(flags *pflag.FlagSet, name string) {
+	_ = flags.SetAnnotation(name, flagAnnotationRedact, []string{redactURLQuery})
+}
+
 // flagToZapField checks whether this flag can be logged,
 // if need to log, return its zap field. Or return a field with hidden value.
 func flagToZapField(f *pflag.Flag) zap.Field {
-	if f.Name == flagStorage {
+	var redact string
+	if values := f.Annotations[flagAnnotationRedact]; len(values) > 0 {
+		redact = values[0]
+	}
+	if redact == redactSecret {
+		return zap.String(f.Name, "<redacted>")
+	}
+	if f.Name == flagStorage || redact == redactURLQuery {
 		hiddenQuery, err := url.Parse(f.Value.String())
 		if err != nil {
 			return zap.String(f.Name, "<invalid URI>")
@@ -37,3 +37,25 @@ func (*testCommonSuite) TestUrlNoQuery(c *C) {
 	c.Assert(field.Key, Equals, flagStorage)
 	c.Assert(field.Interface.(fmt.Stringer).String(), Equals, "s3://some/what")
 }
+
+func (*testCommonSuite) TestRedactFlag(c *C) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("password", "", "")
+	RedactFlag(flags, "password")
+	c.Assert(flags.Parse([]string{"--password", "secret"}), IsNil)
+
+	field := flagToZapField(flags.Lookup("password"))
+	c.Assert(field.Key, Equals, "password")
+	c.Assert(field.String, Equals, "<redacted>")
+}
+
+func (*testCommonSuite) TestRedactURLFlag(c *C) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("to", "", "")
+	RedactURLFlag(flags, "to")
+	c.Assert(flags.Parse([]string{"--to", "s3://bucket/prefix?secret-access-key=secret"}), IsNil)
+
+	field := flagToZapField(flags.Lookup("to"))
+	c.Assert(field.Key, Equals, "to")
+	c.Assert(field.Interface.(fmt.Stringer).String(), Equals, "s3://bucket/prefix")
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// flagConfigFile is the name of config file flag.
+	flagConfigFile = "config"
+
+	configKeyRawRanges   = "ranges"
+	configKeyRawStartKey = "start-key"
+	configKeyRawEndKey   = "end-key"
+)
+
+// setConfigKey sets the key of the flag in the config file, which is the toml
+// tag of the field parsed from the flag. The key of a flag without it is the
+// flag name.
+func setConfigKey(flags *pflag.FlagSet, name, key string) {
+	_ = flags.SetAnnotation(name, flagAnnotationConfigKey, []string{key})
+}
+
+func flagConfigKey(f *pflag.Flag) string {
+	if values := f.Annotations[flagAnnotationConfigKey]; len(values) > 0 {
+		return values[0]
+	}
+	return f.Name
+}
+
+// configFileKeys returns the keys in the config file mapped to the flags they
+// set in the flag set.
+func configFileKeys(flags *pflag.FlagSet) map[string]string {
+	keys := make(map[string]string)
+	flags.VisitAll(func(f *pflag.Flag) {
+		keys[flagConfigKey(f)] = f.Name
+	})
+	return keys
+}
+
+// knownConfigFileKeys returns the keys of the flags of all BR commands. The
+// flags of the commands are defined in separate flag sets, since different
+// commands may define the flags with the same name.
+func knownConfigFileKeys(flags *pflag.FlagSet) map[string]struct{} {
+	defines := []func(*cobra.Command){
+		func(command *cobra.Command) {
+			DefineCommonFlags(command.Flags())
+			DefineFilterFlags(command)
+			DefineTableFlags(command)
+		},
+		func(command *cobra.Command) { DefineBackupFlags(command.Flags()) },
+		func(command *cobra.Command) { DefineRestoreFlags(command.Flags()) },
+		DefineRawBackupFlags,
+		DefineRawRestoreFlags,
+		func(command *cobra.Command) { DefineCopyFlags(command.Flags()) },
+	}
+	known := make(map[string]struct{})
+	addKeys := func(flags *pflag.FlagSet) {
+		for key := range configFileKeys(flags) {
+			known[key] = struct{}{}
+			// The prefixes of the dotted keys are the tables, e.g. [tls] and [s3].
+			parts := strings.Split(key, ".")
+			for i := 1; i < len(parts); i++ {
+				known[strings.Join(parts[:i], ".")] = struct{}{}
+			}
+		}
+	}
+	// The flags of the current command include the ones defined by the cmd
+	// package, e.g. --log-level.
+	addKeys(flags)
+	for _, define := range defines {
+		command := &cobra.Command{}
+		define(command)
+		addKeys(command.Flags())
+	}
+	return known
+}
+
+// LoadConfigFile reads the TOML file given by the --config flag, and sets the
+// flags which are not explicitly set in the command line with the values in
+// the file. Keys known to BR but without a flag in the current command are
+// ignored, while unknown keys are rejected.
+func LoadConfigFile(flags *pflag.FlagSet) error {
+	path, err := flags.GetString(flagConfigFile)
+	if err != nil {
+		// The flag set does not have the --config flag.
+		return nil
+	}
+	if len(path) == 0 {
+		return nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Annotatef(err, "failed to read config file %s", path)
+	}
+	if err = applyConfigFile(flags, content); err != nil {
+		return errors.Annotatef(err, "invalid config file %s", path)
+	}
+	return nil
+}
+
+// applyConfigFile sets the flags with the values in the TOML content.
+func applyConfigFile(flags *pflag.FlagSet, content []byte) error {
+	values := make(map[string]interface{})
+	meta, err := toml.Decode(string(content), &values)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = checkConfigFileKeys(meta, content, knownConfigFileKeys(flags)); err != nil {
+		return err
+	}
+
+	keys := configFileKeys(flags)
+	for _, key := range meta.Keys() {
+		name := key.String()
+		if key[0] == configKeyRawRanges {
+			// The keys in the raw ranges are handled together with the ranges table.
+			if len(key) == 1 {
+				if err = setRawRangesFromConfig(flags, values[configKeyRawRanges]); err != nil {
+					return errors.Annotatef(err, "failed to apply %s", name)
+				}
+			}
+			continue
+		}
+		flagName, ok := keys[name]
+		if !ok {
+			// The key is a table or is known to the other commands.
+			continue
+		}
+		if err = setFlagFromConfig(flags, flagName, lookupConfigValue(values, key)); err != nil {
+			return errors.Annotatef(err, "failed to apply %s", name)
+		}
+	}
+	return nil
+}
+
+// checkConfigFileKeys rejects the keys which are unknown to all BR commands.
+func checkConfigFileKeys(meta toml.MetaData, content []byte, known map[string]struct{}) error {
+	var unknown []string
+	for _, key := range meta.Keys() {
+		name := key.String()
+		if _, ok := known[name]; ok {
+			continue
+		}
+		if key[0] == configKeyRawRanges && (len(key) == 1 ||
+			len(key) == 2 && (key[1] == configKeyRawStartKey || key[1] == configKeyRawEndKey)) {
+			continue
+		}
+		unknown = append(unknown, name)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	lines := configKeyLines(content)
+	msgs := make([]string, 0, len(unknown))
+	for _, name := range unknown {
+		if line, ok := lines[name]; ok {
+			msgs = append(msgs, fmt.Sprintf("'%s' (line %d)", name, line))
+		} else {
+			msgs = append(msgs, fmt.Sprintf("'%s'", name))
+		}
+	}
+	return errors.Errorf("unknown configuration keys: %s", strings.Join(msgs, ", "))
+}
+
+var (
+	configTableRegexp = regexp.MustCompile(`^\s*\[\[?\s*([^\]]+?)\s*\]\]?`)
+	configKeyRegexp   = regexp.MustCompile(`^\s*("?)([A-Za-z0-9_.-]+)("?)\s*=`)
+)
+
+// configKeyLines returns the line number where each key first appears.
+func configKeyLines(content []byte) map[string]int {
+	lines := make(map[string]int)
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		var name string
+		if m := configTableRegexp.FindStringSubmatch(line); m != nil {
+			table = strings.Trim(m[1], `"`)
+			name = table
+		} else if m := configKeyRegexp.FindStringSubmatch(line); m != nil && m[1] == m[3] {
+			name = m[2]
+			if len(table) != 0 {
+				name = table + "." + name
+			}
+		} else {
+			continue
+		}
+		if _, ok := lines[name]; !ok {
+			lines[name] = lineNo
+		}
+	}
+	return lines
+}
+
+func lookupConfigValue(values map[string]interface{}, key toml.Key) interface{} {
+	var value interface{} = values
+	for _, k := range key {
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = table[k]
+	}
+	return value
+}
+
+// setFlagFromConfig sets the flag with the value in the config file, unless
+// the flag is explicitly set in the command line or the flag isn't defined
+// in the current command.
+func setFlagFromConfig(flags *pflag.FlagSet, name string, value interface{}) error {
+	flag := flags.Lookup(name)
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	values, err := configValueToStrings(value)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := flags.Set(name, v); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// setRawRangesFromConfig converts the `[[ranges]]` tables to --range flags.
+func setRawRangesFromConfig(flags *pflag.FlagSet, value interface{}) error {
+	flag := flags.Lookup(flagRawRange)
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	// --start and --end conflict with --range.
+	for _, name := range []string{flagStartKey, flagEndKey, flagRawRangeFile} {
+		if f := flags.Lookup(name); f != nil && f.Changed {
+			return nil
+		}
+	}
+	ranges, ok := value.([]map[string]interface{})
+	if !ok {
+		return errors.Errorf("%s must be an array of tables", configKeyRawRanges)
+	}
+	for _, r := range ranges {
+		start, _ := r[configKeyRawStartKey].(string)
+		end, _ := r[configKeyRawEndKey].(string)
+		if err := flags.Set(flagRawRange, start+","+end); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func configValueToStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case time.Time:
+		return []string{v.Format(time.RFC3339)}, nil
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, err := configValueToStrings(elem)
+			if err != nil {
+				return nil, err
+			}
+			if len(s) != 1 {
+				return nil, errors.New("nested arrays are not supported")
+			}
+			res = append(res, s[0])
+		}
+		return res, nil
+	default:
+		return nil, errors.Errorf("unsupported value type %T", value)
+	}
+}
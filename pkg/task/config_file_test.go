@@ -0,0 +1,154 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testConfigFileSuite{})
+
+type testConfigFileSuite struct{}
+
+func (s *testConfigFileSuite) TestApplyConfigFile(c *C) {
+	command := &cobra.Command{}
+	DefineCommonFlags(command.Flags())
+	DefineFilterFlags(command)
+	DefineBackupFlags(command.Flags())
+	c.Assert(command.Flags().Parse([]string{"--pd", "10.0.0.1:2379", "--ratelimit", "64"}), IsNil)
+
+	content := []byte(`
+storage = "s3://bucket/prefix"
+pd = ["127.0.0.2:2379"]
+rate-limit = 128
+checksum = false
+filter = ["db.*", "!db.tmp"]
+case-sensitive = true
+backup-ts = 400036290571534337
+online = true
+
+[tls]
+ca = "/path/to/ca.pem"
+
+[s3]
+endpoint = "http://10.0.0.2:9000"
+`)
+	c.Assert(applyConfigFile(command.Flags(), content), IsNil)
+
+	var cfg BackupConfig
+	c.Assert(cfg.ParseFromFlags(command.Flags()), IsNil)
+	c.Assert(cfg.Storage, Equals, "s3://bucket/prefix")
+	// The explicitly set flags override the config file.
+	c.Assert(cfg.PD, DeepEquals, []string{"10.0.0.1:2379"})
+	c.Assert(cfg.RateLimit, Equals, uint64(64*utils.MB))
+	c.Assert(cfg.Checksum, IsFalse)
+	c.Assert(cfg.BackupTS, Equals, uint64(400036290571534337))
+	c.Assert(cfg.TLS.CA, Equals, "/path/to/ca.pem")
+	c.Assert(cfg.S3.Endpoint, Equals, "http://10.0.0.2:9000")
+	c.Assert(cfg.TableFilter.MatchTable("db", "t"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("db", "tmp"), IsFalse)
+	c.Assert(cfg.TableFilter.MatchTable("DB", "t"), IsFalse)
+}
+
+func (s *testConfigFileSuite) TestApplyRawRanges(c *C) {
+	command := &cobra.Command{}
+	DefineCommonFlags(command.Flags())
+	DefineRawBackupFlags(command)
+
+	content := []byte(`
+format = "raw"
+cfs = ["default", "write"]
+
+[[ranges]]
+start-key = "a"
+end-key = "b"
+
+[[ranges]]
+start-key = "c"
+end-key = "d"
+`)
+	c.Assert(applyConfigFile(command.Flags(), content), IsNil)
+	var cfg RawKvConfig
+	c.Assert(cfg.ParseFromFlags(command.Flags()), IsNil)
+	c.Assert(cfg.CFs, DeepEquals, []string{"default", "write"})
+	c.Assert(cfg.Ranges, DeepEquals, []RawKeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("b")},
+		{StartKey: []byte("c"), EndKey: []byte("d")},
+	})
+}
+
+func (s *testConfigFileSuite) TestUnknownKeys(c *C) {
+	command := &cobra.Command{}
+	DefineCommonFlags(command.Flags())
+
+	content := []byte(`
+storage = "local:///tmp/backup"
+# backup-ts isn't defined in this command, but it's known.
+backup-ts = 1
+ratelimit = 10
+
+[s3]
+endpoint = "http://10.0.0.2:9000"
+region2 = "us-east-1"
+`)
+	err := applyConfigFile(command.Flags(), content)
+	c.Assert(err, ErrorMatches, `unknown configuration keys: 'ratelimit' \(line 5\), 's3.region2' \(line 9\)`)
+
+	// Syntax errors carry the line number too.
+	err = applyConfigFile(command.Flags(), []byte("storage = \"a\"\npd = [\n"))
+	c.Assert(err, ErrorMatches, "(?s).*line 2.*")
+}
+
+func (s *testConfigFileSuite) TestConfigFileRedacted(c *C) {
+	command := &cobra.Command{}
+	DefineCommonFlags(command.Flags())
+	DefineCopyFlags(command.Flags())
+	command.Flags().String("tidb-password", "", "")
+	RedactFlag(command.Flags(), "tidb-password")
+
+	content := []byte(`
+storage = "s3://bucket/prefix?secret-access-key=secret"
+from = "s3://bucket/from?access-key=secret"
+tidb-password = "secret"
+`)
+	c.Assert(applyConfigFile(command.Flags(), content), IsNil)
+	// The flags set by the config file are logged like the ones set in the
+	// command line, and the secrets in them are redacted.
+	logged := make(map[string]string)
+	command.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			field := flagToZapField(f)
+			if field.Interface != nil {
+				logged[field.Key] = field.Interface.(fmt.Stringer).String()
+			} else {
+				logged[field.Key] = field.String
+			}
+		}
+	})
+	c.Assert(logged, DeepEquals, map[string]string{
+		flagStorage:     "s3://bucket/prefix",
+		flagCopyFrom:    "s3://bucket/from",
+		"tidb-password": "<redacted>",
+	})
+}
+
+func (s *testConfigFileSuite) TestLoadConfigFile(c *C) {
+	command := &cobra.Command{}
+	DefineCommonFlags(command.Flags())
+	path := filepath.Join(c.MkDir(), "br.toml")
+	c.Assert(ioutil.WriteFile(path, []byte(`storage = "local:///tmp/backup"`), 0644), IsNil)
+	c.Assert(command.Flags().Parse([]string{"--config", path}), IsNil)
+
+	c.Assert(LoadConfigFile(command.Flags()), IsNil)
+	storage, err := command.Flags().GetString(flagStorage)
+	c.Assert(err, IsNil)
+	c.Assert(storage, Equals, "local:///tmp/backup")
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// sstFileSuffix is the suffix of the backup files written by TiKV.
+const sstFileSuffix = ".sst"
+
+// StorageFile is a file in the storage.
+type StorageFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// GCCheckReport is the result of cross-referencing the files in the storage
+// with the backupmeta files in it.
+type GCCheckReport struct {
+	// Backups are the directories that have backupmeta, "." is the root of
+	// the storage.
+	Backups []string `json:"backups"`
+	// Orphans are the backup files not referenced by the backupmeta in the
+	// same directory, e.g. the files of interrupted or overwritten backups.
+	Orphans    []StorageFile `json:"orphans"`
+	OrphanSize uint64        `json:"orphan-size"`
+	// Missing are the files referenced by backupmeta but not in the storage.
+	Missing []string `json:"missing"`
+}
+
+// Clean returns whether there is neither orphan nor missing file.
+func (r *GCCheckReport) Clean() bool {
+	return len(r.Orphans) == 0 && len(r.Missing) == 0
+}
+
+// CheckBackupGC lists the files in the storage, and cross-references them with
+// the files in all backupmeta of the storage. Only the SST files are regarded
+// as orphans, the other files, e.g. the reports, are ignored.
+func CheckBackupGC(ctx context.Context, s storage.ExternalStorage) (*GCCheckReport, error) {
+	var (
+		files       []StorageFile
+		backupDirs  = []string{}
+		fileExisted = make(map[string]bool)
+	)
+	err := s.WalkDir(ctx, func(name string, size int64) error {
+		files = append(files, StorageFile{Name: name, Size: size})
+		fileExisted[name] = true
+		if path.Base(name) == utils.MetaFile {
+			backupDirs = append(backupDirs, path.Dir(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotate(err, "list the storage failed")
+	}
+	sort.Strings(backupDirs)
+
+	report := &GCCheckReport{Backups: backupDirs, Orphans: []StorageFile{}, Missing: []string{}}
+	referenced := make(map[string]bool)
+	for _, dir := range backupDirs {
+		metaName := path.Join(dir, utils.MetaFile)
+		data, err := s.Read(ctx, metaName)
+		if err != nil {
+			return nil, errors.Annotatef(err, "load %s failed", metaName)
+		}
+		meta := &backup.BackupMeta{}
+		if err = proto.Unmarshal(data, meta); err != nil {
+			return nil, errors.Annotatef(err, "parse %s failed", metaName)
+		}
+		for _, file := range meta.Files {
+			name := path.Join(dir, file.Name)
+			referenced[name] = true
+			if !fileExisted[name] {
+				report.Missing = append(report.Missing, name)
+			}
+		}
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file.Name, sstFileSuffix) && !referenced[file.Name] {
+			report.Orphans = append(report.Orphans, file)
+			report.OrphanSize += uint64(file.Size)
+		}
+	}
+	sort.Slice(report.Orphans, func(i, j int) bool {
+		return report.Orphans[i].Name < report.Orphans[j].Name
+	})
+	sort.Strings(report.Missing)
+	log.Info("check backup gc",
+		zap.Int("files", len(files)),
+		zap.Int("backups", len(backupDirs)),
+		zap.Int("orphans", len(report.Orphans)),
+		zap.Uint64("orphan-size", report.OrphanSize),
+		zap.Int("missing", len(report.Missing)))
+	return report, nil
+}
+
+// DeleteOrphans deletes the orphan files in the report. The files of running
+// backups are orphans before the backupmeta is written, so it must not be
+// called when any backup is writing to the storage.
+func DeleteOrphans(ctx context.Context, s storage.ExternalStorage, report *GCCheckReport) error {
+	for _, file := range report.Orphans {
+		if err := s.DeleteFile(ctx, file.Name); err != nil {
+			return errors.Annotatef(err, "delete %s failed", file.Name)
+		}
+		log.Info("delete orphan file", zap.String("name", file.Name), zap.Int64("size", file.Size))
+	}
+	return nil
+}
+
+// PrintJSON writes the report as JSON.
+func (r *GCCheckReport) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Trace(err)
+}
+
+// PrintTable writes the report as human readable text.
+func (r *GCCheckReport) PrintTable(w io.Writer) error {
+	fmt.Fprintf(w, "Backups: %d, orphan files: %d (%s), missing files: %d\n",
+		len(r.Backups), len(r.Orphans), utils.FormatBytes(r.OrphanSize), len(r.Missing))
+	if r.Clean() {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tFILE\tSIZE")
+	for _, file := range r.Orphans {
+		fmt.Fprintf(tw, "orphan\t%s\t%d\n", file.Name, file.Size)
+	}
+	for _, name := range r.Missing {
+		fmt.Fprintf(tw, "missing\t%s\t-\n", name)
+	}
+	return errors.Trace(tw.Flush())
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testGCCheckSuite{})
+
+type testGCCheckSuite struct{}
+
+func (s *testGCCheckSuite) TestCheckBackupGC(c *C) {
+	ctx := context.Background()
+	backend, err := storage.ParseBackend("local://"+c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	stg, err := storage.Create(ctx, backend, false)
+	c.Assert(err, IsNil)
+
+	writeMeta := func(name string, files ...string) {
+		meta := &backup.BackupMeta{}
+		for _, file := range files {
+			meta.Files = append(meta.Files, &backup.File{Name: file})
+		}
+		data, err := proto.Marshal(meta)
+		c.Assert(err, IsNil)
+		c.Assert(stg.Write(ctx, name, data), IsNil)
+	}
+	// The root is a backup overwritten by a backup of fewer files, and the
+	// other directory is an interrupted backup without backupmeta.
+	for _, name := range []string{"1.sst", "2.sst", "3.sst"} {
+		c.Assert(stg.Write(ctx, name, []byte("data")), IsNil)
+	}
+	c.Assert(stg.Write(ctx, utils.BackupReportFile, []byte("{}")), IsNil)
+	writeMeta(utils.MetaFile, "1.sst", "missing.sst")
+	report, err := CheckBackupGC(ctx, stg)
+	c.Assert(err, IsNil)
+	c.Assert(report.Backups, DeepEquals, []string{"."})
+	c.Assert(report.Orphans, DeepEquals, []StorageFile{{Name: "2.sst", Size: 4}, {Name: "3.sst", Size: 4}})
+	c.Assert(report.OrphanSize, Equals, uint64(8))
+	c.Assert(report.Missing, DeepEquals, []string{"missing.sst"})
+	c.Assert(report.Clean(), IsFalse)
+
+	var buf bytes.Buffer
+	c.Assert(report.PrintTable(&buf), IsNil)
+	c.Assert(buf.String(), Equals, "Backups: 1, orphan files: 2 (8 B), missing files: 1\n\n"+
+		"STATUS   FILE         SIZE\n"+
+		"orphan   2.sst        4\n"+
+		"orphan   3.sst        4\n"+
+		"missing  missing.sst  -\n")
+
+	c.Assert(DeleteOrphans(ctx, stg, report), IsNil)
+	exists, err := stg.FileExists(ctx, "2.sst")
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsFalse)
+	report, err = CheckBackupGC(ctx, stg)
+	c.Assert(err, IsNil)
+	c.Assert(report.Orphans, HasLen, 0)
+	c.Assert(report.Missing, HasLen, 1)
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	pd "github.com/pingcap/pd/v4/client"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// reportEnabled returns whether the JSON report of the task is written.
+func (cfg *Config) reportEnabled() bool {
+	return len(cfg.ReportFile) != 0 || cfg.ReportToStorage
+}
+
+// collectClusterVersion records the versions of TiKV in the task report.
+func collectClusterVersion(ctx context.Context, client pd.Client) {
+	stores, err := client.GetAllStores(ctx, pd.WithExcludeTombstone())
+	if err != nil {
+		log.Warn("failed to get cluster version for report", zap.Error(err))
+		return
+	}
+	versions := make(map[string]struct{})
+	for _, s := range stores {
+		versions[s.Version] = struct{}{}
+	}
+	versionList := make([]string, 0, len(versions))
+	for v := range versions {
+		versionList = append(versionList, v)
+	}
+	sort.Strings(versionList)
+	summary.SetClusterVersion(strings.Join(versionList, ","))
+}
+
+// collectTableReports records the size and checksum of the tables in the
+// task report.
+func collectTableReports(tables []*utils.Table) {
+	for _, tbl := range tables {
+		summary.CollectTable(summary.TableReport{
+			DB:         tbl.Db.Name.O,
+			Table:      tbl.Info.Name.O,
+			Crc64Xor:   tbl.Crc64Xor,
+			TotalKVs:   tbl.TotalKvs,
+			TotalBytes: tbl.TotalBytes,
+		})
+	}
+}
+
+// buildReport builds the JSON report of the task, taskErr is the error
+// returned by the task.
+func buildReport(cmdName string, taskErr error) ([]byte, error) {
+	report := summary.GetReport(cmdName)
+	report.BRVersion = utils.BRReleaseVersion
+	report.BRGitHash = utils.BRGitHash
+	if taskErr != nil {
+		report.Success = false
+		report.Error = taskErr.Error()
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	return data, errors.Trace(err)
+}
+
+// writeReport writes the JSON report of the task to the report file and the
+// storage if required. It must be called before the summary is printed.
+// Failing to write the report doesn't fail the task.
+func writeReport(ctx context.Context, cfg *Config, cmdName, fileName string, taskErr error) {
+	if !cfg.reportEnabled() {
+		return
+	}
+	data, err := buildReport(cmdName, taskErr)
+	if err != nil {
+		log.Warn("failed to build report", zap.Error(err))
+		return
+	}
+	if len(cfg.ReportFile) != 0 {
+		if err = ioutil.WriteFile(cfg.ReportFile, data, 0644); err != nil {
+			log.Warn("failed to write report", zap.String("path", cfg.ReportFile), zap.Error(err))
+		}
+	}
+	if cfg.ReportToStorage {
+		_, s, err := GetStorage(ctx, cfg)
+		if err == nil {
+			err = s.Write(ctx, fileName, data)
+		}
+		if err != nil {
+			log.Warn("failed to write report to storage", zap.String("file", fileName), zap.Error(err))
+		}
+	}
+}
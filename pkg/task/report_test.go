@@ -0,0 +1,50 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+var _ = Suite(&testReportSuite{})
+
+type testReportSuite struct{}
+
+func (s *testReportSuite) TestWriteReport(c *C) {
+	dir := c.MkDir()
+	cfg := &Config{
+		Storage:         "local://" + dir,
+		ReportFile:      filepath.Join(dir, "report.json"),
+		ReportToStorage: true,
+	}
+	summary.SetUnit(summary.BackupUnit)
+	summary.SetBackupTS(42)
+	summary.CollectTable(summary.TableReport{DB: "test", Table: "t", TotalKVs: 10})
+	summary.SetSuccessStatus(true)
+	defer summary.Summary("test")
+
+	c.Assert(cfg.reportEnabled(), IsTrue)
+	c.Assert((&Config{}).reportEnabled(), IsFalse)
+	writeReport(context.Background(), cfg, "test", utils.BackupReportFile, errors.New("injected"))
+	for _, path := range []string{cfg.ReportFile, filepath.Join(dir, utils.BackupReportFile)} {
+		data, err := ioutil.ReadFile(path)
+		c.Assert(err, IsNil)
+		var report summary.Report
+		c.Assert(json.Unmarshal(data, &report), IsNil)
+		c.Assert(report.Name, Equals, "test")
+		c.Assert(report.Success, IsFalse)
+		c.Assert(report.Error, Equals, "injected")
+		c.Assert(report.BackupTS, Equals, uint64(42))
+		c.Assert(report.BRVersion, Equals, utils.BRReleaseVersion)
+		c.Assert(report.Tables, DeepEquals, []summary.TableReport{{DB: "test", Table: "t", TotalKVs: 10}})
+	}
+}
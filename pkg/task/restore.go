@@ -5,6 +5,7 @@ package task
 import (
 	"context"
 	"math"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/backup"
@@ -12,6 +13,8 @@ import (
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/config"
 	"github.com/spf13/pflag"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/conn"
@@ -24,11 +27,24 @@ import (
 )
 
 const (
-	flagOnline   = "online"
-	flagNoSchema = "no-schema"
-
-	defaultRestoreConcurrency = 128
-	maxRestoreBatchSizeLimit  = 256
+	flagOnline                 = "online"
+	flagNoSchema               = "no-schema"
+	flagVerifyFiles            = "verify-files"
+	flagVerifyFilesConcurrency = "verify-files-concurrency"
+	flagVerifySHA256           = "verify-sha256"
+	flagTunePDSchedule         = "tune-pd-schedule"
+
+	defaultRestoreConcurrency     = 128
+	defaultVerifyFilesConcurrency = 16
+	maxRestoreBatchSizeLimit      = 256
+
+	// schedulerPauseTTL is the duration PD keeps the schedulers paused, PD
+	// resumes them automatically if BR stops refreshing, e.g. killed.
+	schedulerPauseTTL = 5 * time.Minute
+	// clusterKeepAliveInterval is the interval of pausing schedulers and
+	// switching TiKV to import mode again during restore. It must be smaller
+	// than schedulerPauseTTL and the import mode timeout of TiKV.
+	clusterKeepAliveInterval = time.Minute
 )
 
 var (
@@ -58,6 +74,62 @@ type RestoreConfig struct {
 
 	Online   bool `json:"online" toml:"online"`
 	NoSchema bool `json:"no-schema" toml:"no-schema"`
+	// TunePDSchedule is true means region merge is disabled and the schedule
+	// limits are enlarged during restore. They are not reverted automatically
+	// if BR exits abnormally.
+	TunePDSchedule bool `json:"tune-pd-schedule" toml:"tune-pd-schedule"`
+
+	VerifyFilesConfig
+}
+
+// VerifyFilesConfig is the configuration of verifying the backup files before
+// restore.
+type VerifyFilesConfig struct {
+	// VerifyFiles is true means the existence and the sizes of the backup
+	// files are checked before restore starts.
+	VerifyFiles            bool `json:"verify-files" toml:"verify-files"`
+	VerifyFilesConcurrency uint `json:"verify-files-concurrency" toml:"verify-files-concurrency"`
+	// VerifySHA256 is true means the sha256 of the backup files are also
+	// checked, which reads all the backup files.
+	VerifySHA256 bool `json:"verify-sha256" toml:"verify-sha256"`
+}
+
+func (cfg *VerifyFilesConfig) parseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	cfg.VerifyFiles, err = flags.GetBool(flagVerifyFiles)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.VerifyFilesConcurrency, err = flags.GetUint(flagVerifyFilesConcurrency)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.VerifyFilesConcurrency == 0 {
+		return errors.Errorf("--%s must be positive", flagVerifyFilesConcurrency)
+	}
+	cfg.VerifySHA256, err = flags.GetBool(flagVerifySHA256)
+	return errors.Trace(err)
+}
+
+// canVerifyFiles returns whether BR can read the backup files. The backup
+// files in local storage are written by TiKV to its own node, and they are
+// read by TiKV on restore, so they may be not on the node running BR.
+func canVerifyFiles(u *backup.StorageBackend) bool {
+	return u.GetLocal() == nil
+}
+
+// verifyFiles finds the missing or corrupt backup files if it is enabled.
+func (cfg *VerifyFilesConfig) verifyFiles(
+	ctx context.Context, u *backup.StorageBackend, s storage.ExternalStorage, files []*backup.File,
+) error {
+	if !cfg.VerifyFiles && !cfg.VerifySHA256 {
+		return nil
+	}
+	if !canVerifyFiles(u) {
+		log.Info("skip verifying the backup files in local storage, which are on the TiKV nodes")
+		return nil
+	}
+	return restore.VerifyBackupFiles(ctx, s, files, cfg.VerifyFilesConcurrency, cfg.VerifySHA256)
 }
 
 // DefineRestoreFlags defines common flags for the restore command.
@@ -65,6 +137,14 @@ func DefineRestoreFlags(flags *pflag.FlagSet) {
 	// TODO remove experimental tag if it's stable
 	flags.Bool(flagOnline, false, "(experimental) Whether online when restore")
 	flags.Bool(flagNoSchema, false, "skip creating schemas and tables, reuse existing empty ones")
+	flags.Bool(flagVerifyFiles, true, "Check whether all backup files exist and have the right sizes before restore, "+
+		"the files in local storage are not checked since they are on the TiKV nodes")
+	flags.Uint(flagVerifyFilesConcurrency, defaultVerifyFilesConcurrency,
+		"The number of backup files checked concurrently before restore")
+	flags.Bool(flagVerifySHA256, false, "Also check the sha256 of all backup files before restore, "+
+		"which reads all backup files")
+	flags.Bool(flagTunePDSchedule, false, "Disable region merge and enlarge the schedule limits of PD during restore, "+
+		"PD has no TTL for them, so they are kept until `br restore recover-cluster` runs if BR exits abnormally")
 
 	// Do not expose this flag
 	_ = flags.MarkHidden(flagNoSchema)
@@ -85,6 +165,13 @@ func (cfg *RestoreConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.TunePDSchedule, err = flags.GetBool(flagTunePDSchedule)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.VerifyFilesConfig.parseFromFlags(flags); err != nil {
+		return err
+	}
 	err = cfg.Config.ParseFromFlags(flags)
 	if err != nil {
 		return errors.Trace(err)
@@ -97,8 +184,11 @@ func (cfg *RestoreConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 }
 
 // RunRestore starts a restore task inside the current goroutine.
-func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConfig) error {
+func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConfig) (err error) {
 	defer summary.Summary(cmdName)
+	defer func() {
+		writeReport(c, &cfg.Config, cmdName, utils.RestoreReportFile, err)
+	}()
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
@@ -107,6 +197,7 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return err
 	}
 	defer mgr.Close()
+	collectClusterVersion(ctx, mgr.GetPDClient())
 
 	client, err := restore.NewRestoreClient(ctx, g, mgr.GetPDClient(), mgr.GetTiKV(), mgr.GetTLSConfig())
 	if err != nil {
@@ -134,10 +225,11 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return err
 	}
 
-	u, _, backupMeta, err := ReadBackupMeta(ctx, utils.MetaFile, &cfg.Config)
+	u, s, backupMeta, err := ReadBackupMeta(ctx, utils.MetaFile, &cfg.Config)
 	if err != nil {
 		return err
 	}
+	summary.SetBackupTS(backupMeta.EndVersion)
 	g.Record("Size", utils.ArchiveSize(backupMeta))
 	if err = client.InitBackupMeta(backupMeta, u); err != nil {
 		return err
@@ -148,9 +240,16 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	}
 
 	files, tables, dbs := filterRestoreFiles(client, cfg)
+	if cfg.reportEnabled() {
+		collectTableReports(tables)
+	}
 	if len(dbs) == 0 && len(tables) != 0 {
 		return errors.New("invalid backup, contain tables but no databases")
 	}
+	// Find the missing or corrupt files before changing the cluster.
+	if err = cfg.verifyFiles(ctx, u, s, files); err != nil {
+		return err
+	}
 
 	var newTS uint64
 	if client.IsIncremental() {
@@ -161,8 +260,11 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	}
 	ddlJobs := restore.FilterDDLJobs(client.GetDDLJobs(), tables)
 
-	// pre-set TiDB config for restore
-	enableTiDBConfig()
+	// pre-set TiDB config for restore, which only affects the TiDB embedded in
+	// BR. The DDLs executed on a TiDB server follow the config of the server.
+	if mgr.GetDomain() != nil {
+		enableTiDBConfig()
+	}
 
 	// execute DDL first
 	err = client.ExecDDLs(ddlJobs)
@@ -215,8 +317,14 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		// Split/Scatter + Download/Ingest
 		int64(len(ranges)+len(files)),
 		!cfg.LogProgress)
+	glue.SetTotalBytes(updateCh, filesTotalBytes(files))
 
-	clusterCfg, err := restorePreWork(ctx, client, mgr)
+	etcdCli, err := newClusterConfigClient(ctx, cfg.PD, mgr.GetTLSConfig())
+	if err != nil {
+		return err
+	}
+	defer etcdCli.Close()
+	clusterCfg, err := restorePreWork(ctx, client, mgr, etcdCli, cfg.TunePDSchedule)
 	if err != nil {
 		return err
 	}
@@ -226,7 +334,7 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	restorePostWork := func() {
 		if shouldRestorePostWork {
 			shouldRestorePostWork = false
-			restorePostWork(ctx, client, mgr, clusterCfg)
+			restorePostWork(ctx, client, mgr, clusterCfg, etcdCli)
 		}
 	}
 	defer restorePostWork()
@@ -308,6 +416,11 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	if cfg.Checksum {
 		updateCh = g.StartProgress(
 			ctx, "Checksum", int64(len(newTables)), !cfg.LogProgress)
+		var totalBytes uint64
+		for _, table := range tables {
+			totalBytes += table.TotalBytes
+		}
+		glue.SetTotalBytes(updateCh, totalBytes)
 		err = client.ValidateChecksum(
 			ctx, mgr.GetTiKV().GetClient(), tables, newTables, updateCh)
 		if err != nil {
@@ -344,46 +457,75 @@ func filterRestoreFiles(
 }
 
 type clusterConfig struct {
-	// Enable PD schedulers before restore
-	scheduler []string
-	// Original scheudle configuration
-	scheduleCfg map[string]interface{}
+	// Enable PD schedulers before restore, they are paused during restore
+	Schedulers []string `json:"schedulers"`
+	// Original scheudle configuration, it is empty if the schedule config is
+	// not changed.
+	ScheduleCfg map[string]interface{} `json:"schedule-config"`
+
+	// key is the PD etcd key saving the config of this restore.
+	key string
+
+	// stopKeepAlive stops refreshing the paused schedulers and import mode.
+	stopKeepAlive func()
 }
 
 // restorePreWork executes some prepare work before restore.
-func restorePreWork(ctx context.Context, client *restore.Client, mgr *conn.Mgr) (clusterConfig, error) {
+// The paused schedulers and the import mode of TiKV expire by themselves if
+// BR exits without running restorePostWork, e.g. killed. PD has no TTL for the
+// schedule config, so region merge is disabled and the schedule limits are
+// enlarged only if tunePDSchedule is true, then they are kept until recovered
+// by `br restore recover-cluster` or by the next restore. The original cluster
+// config is saved to the etcd of PD before any change for that.
+func restorePreWork(
+	ctx context.Context, client *restore.Client, mgr *conn.Mgr, kv clientv3.KV, tunePDSchedule bool,
+) (clusterConfig, error) {
 	if client.IsOnline() {
 		return clusterConfig{}, nil
 	}
 
-	// Switch TiKV cluster to import mode (adjust rocksdb configuration).
-	if err := client.SwitchToImportMode(ctx); err != nil {
-		return clusterConfig{}, nil
-	}
-
-	// Remove default PD scheduler that may affect restore process.
-	existSchedulers, err := mgr.ListSchedulers(ctx)
-	if err != nil {
-		return clusterConfig{}, nil
-	}
-	needRemoveSchedulers := make([]string, 0, len(existSchedulers))
-	for _, s := range existSchedulers {
-		if _, ok := schedulers[s]; ok {
-			needRemoveSchedulers = append(needRemoveSchedulers, s)
+	cluster, key, err := prepareClusterConfig(ctx, kv, func() (clusterConfig, error) {
+		// Pause default PD scheduler that may affect restore process.
+		existSchedulers, err := mgr.ListSchedulers(ctx)
+		if err != nil {
+			return clusterConfig{}, err
 		}
-	}
-	scheduler, err := removePDLeaderScheduler(ctx, mgr, needRemoveSchedulers)
+		needPauseSchedulers := make([]string, 0, len(existSchedulers))
+		for _, scheduler := range existSchedulers {
+			if _, ok := schedulers[scheduler]; ok {
+				needPauseSchedulers = append(needPauseSchedulers, scheduler)
+			}
+		}
+		cfg := clusterConfig{Schedulers: needPauseSchedulers}
+		if tunePDSchedule {
+			cfg.ScheduleCfg, err = mgr.GetPDScheduleConfig(ctx)
+			if err != nil {
+				return clusterConfig{}, err
+			}
+		}
+		return cfg, nil
+	})
 	if err != nil {
-		return clusterConfig{}, nil
+		return clusterConfig{}, err
 	}
+	cluster.key = key
+	needPauseSchedulers, scheduleCfg := cluster.Schedulers, cluster.ScheduleCfg
 
-	stores, err := mgr.GetPDClient().GetAllStores(ctx)
-	if err != nil {
+	// Switch TiKV cluster to import mode (adjust rocksdb configuration).
+	if err = client.SwitchToImportMode(ctx); err != nil {
+		return clusterConfig{}, err
+	}
+	if err = mgr.PauseSchedulers(ctx, needPauseSchedulers, schedulerPauseTTL); err != nil {
 		return clusterConfig{}, err
 	}
+	cluster.stopKeepAlive = keepClusterPaused(ctx, client, mgr, needPauseSchedulers)
+	if !tunePDSchedule {
+		return cluster, nil
+	}
 
-	scheduleCfg, err := mgr.GetPDScheduleConfig(ctx)
+	stores, err := mgr.GetPDClient().GetAllStores(ctx)
 	if err != nil {
+		cluster.stopKeepAlive()
 		return clusterConfig{}, err
 	}
 
@@ -399,6 +541,7 @@ func restorePreWork(ctx context.Context, client *restore.Client, mgr *conn.Mgr)
 	}
 	err = mgr.UpdatePDScheduleConfig(ctx, disableMergeCfg)
 	if err != nil {
+		cluster.stopKeepAlive()
 		return clusterConfig{}, err
 	}
 
@@ -418,44 +561,96 @@ func restorePreWork(ctx context.Context, client *restore.Client, mgr *conn.Mgr)
 	}
 	err = mgr.UpdatePDScheduleConfig(ctx, scheduleLimitCfg)
 	if err != nil {
+		cluster.stopKeepAlive()
 		return clusterConfig{}, err
 	}
 
-	cluster := clusterConfig{
-		scheduler:   scheduler,
-		scheduleCfg: scheduleCfg,
-	}
 	return cluster, nil
 }
 
-func removePDLeaderScheduler(ctx context.Context, mgr *conn.Mgr, existSchedulers []string) ([]string, error) {
-	removedSchedulers := make([]string, 0, len(existSchedulers))
-	for _, scheduler := range existSchedulers {
-		err := mgr.RemoveScheduler(ctx, scheduler)
-		if err != nil {
-			return nil, err
+// keepClusterPaused pauses the schedulers and switches TiKV to import mode
+// periodically in background, until the returned function is called.
+// If BR stops refreshing, PD resumes the schedulers and TiKV switches back to
+// normal mode automatically after the timeout.
+func keepClusterPaused(
+	ctx context.Context, client *restore.Client, mgr *conn.Mgr, schedulers []string,
+) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(clusterKeepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := client.SwitchToImportMode(ctx); err != nil {
+					log.Warn("fail to keep import mode", zap.Error(err))
+				}
+				if err := mgr.PauseSchedulers(ctx, schedulers, schedulerPauseTTL); err != nil {
+					log.Warn("fail to keep PD schedulers paused", zap.Error(err))
+				}
+			}
 		}
-		removedSchedulers = append(removedSchedulers, scheduler)
+	}()
+	return func() {
+		cancel()
+		<-done
 	}
-	return removedSchedulers, nil
 }
 
 // restorePostWork executes some post work after restore.
 func restorePostWork(
-	ctx context.Context, client *restore.Client, mgr *conn.Mgr, clusterCfg clusterConfig,
+	ctx context.Context,
+	client *restore.Client,
+	mgr *conn.Mgr,
+	clusterCfg clusterConfig,
+	kv clientv3.KV,
 ) {
 	if client.IsOnline() {
 		return
 	}
+	if clusterCfg.stopKeepAlive != nil {
+		clusterCfg.stopKeepAlive()
+	}
+	if err := recoverClusterConfig(ctx, client, mgr, clusterCfg); err != nil {
+		log.Warn("fail to recover cluster config, "+
+			"please run `br restore recover-cluster` to recover the cluster", zap.Error(err))
+		return
+	}
+	removeClusterConfig(ctx, kv, clusterCfg.key)
+}
+
+// recoverClusterConfig switches TiKV back to normal mode and restores the PD
+// schedulers and schedule config changed by restorePreWork.
+func recoverClusterConfig(
+	ctx context.Context, client *restore.Client, mgr *conn.Mgr, clusterCfg clusterConfig,
+) error {
+	var allErrors error
 	if err := client.SwitchToNormalMode(ctx); err != nil {
-		log.Warn("fail to switch to normal mode")
+		log.Warn("fail to switch to normal mode", zap.Error(err))
+		allErrors = multierr.Append(allErrors, err)
+	}
+	return multierr.Append(allErrors, recoverPDConfig(ctx, mgr, clusterCfg))
+}
+
+// recoverPDConfig resumes the PD schedulers and restores the schedule config
+// changed by restorePreWork.
+func recoverPDConfig(ctx context.Context, mgr *conn.Mgr, clusterCfg clusterConfig) error {
+	var allErrors error
+	if err := mgr.ResumeSchedulers(ctx, clusterCfg.Schedulers); err != nil {
+		log.Warn("fail to resume PD schedulers", zap.Error(err))
+		allErrors = multierr.Append(allErrors, err)
 	}
-	if err := addPDLeaderScheduler(ctx, mgr, clusterCfg.scheduler); err != nil {
-		log.Warn("fail to add PD schedulers")
+	if len(clusterCfg.ScheduleCfg) == 0 {
+		// The schedule config is not changed.
+		return allErrors
 	}
 	mergeCfg := make(map[string]interface{})
 	for _, cfgKey := range pdRegionMergeCfg {
-		value := clusterCfg.scheduleCfg[cfgKey]
+		value := clusterCfg.ScheduleCfg[cfgKey]
 		if value == nil {
 			// Ignore non-exist config.
 			continue
@@ -463,12 +658,13 @@ func restorePostWork(
 		mergeCfg[cfgKey] = value
 	}
 	if err := mgr.UpdatePDScheduleConfig(ctx, mergeCfg); err != nil {
-		log.Warn("fail to update PD region merge config")
+		log.Warn("fail to update PD region merge config", zap.Error(err))
+		allErrors = multierr.Append(allErrors, err)
 	}
 
 	scheduleLimitCfg := make(map[string]interface{})
 	for _, cfgKey := range pdScheduleLimitCfg {
-		value := clusterCfg.scheduleCfg[cfgKey]
+		value := clusterCfg.ScheduleCfg[cfgKey]
 		if value == nil {
 			// Ignore non-exist config.
 			continue
@@ -476,18 +672,10 @@ func restorePostWork(
 		scheduleLimitCfg[cfgKey] = value
 	}
 	if err := mgr.UpdatePDScheduleConfig(ctx, scheduleLimitCfg); err != nil {
-		log.Warn("fail to update PD schedule config")
+		log.Warn("fail to update PD schedule config", zap.Error(err))
+		allErrors = multierr.Append(allErrors, err)
 	}
-}
-
-func addPDLeaderScheduler(ctx context.Context, mgr *conn.Mgr, removedSchedulers []string) error {
-	for _, scheduler := range removedSchedulers {
-		err := mgr.AddScheduler(ctx, scheduler)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return allErrors
 }
 
 func splitPrepareWork(ctx context.Context, client *restore.Client, tables []*model.TableInfo) error {
@@ -585,3 +773,12 @@ func enableTiDBConfig() {
 
 	config.StoreGlobalConfig(conf)
 }
+
+// filesTotalBytes returns the total size of the kv pairs in the files.
+func filesTotalBytes(files []*backup.File) uint64 {
+	var total uint64
+	for _, file := range files {
+		total += file.GetTotalBytes()
+	}
+	return total
+}
@@ -3,32 +3,58 @@
 package task
 
 import (
+	"bytes"
 	"context"
+	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/checksum"
 	"github.com/pingcap/br/pkg/conn"
 	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/utils"
 )
 
+const flagRewritePrefix = "rewrite-prefix"
+
 // RestoreRawConfig is the configuration specific for raw kv restore tasks.
 type RestoreRawConfig struct {
 	RawKvConfig
 
 	Online bool `json:"online" toml:"online"`
+	// OldKeyPrefix and NewKeyPrefix rewrite the prefix of restored keys,
+	// only the keys with OldKeyPrefix are restored if it's not empty.
+	OldKeyPrefix []byte `json:"old-key-prefix" toml:"old-key-prefix"`
+	NewKeyPrefix []byte `json:"new-key-prefix" toml:"new-key-prefix"`
+	// TunePDSchedule is true means region merge is disabled and the schedule
+	// limits are enlarged during restore.
+	TunePDSchedule bool `json:"tune-pd-schedule" toml:"tune-pd-schedule"`
+	VerifyFilesConfig
 }
 
 // DefineRawRestoreFlags defines common flags for the backup command.
 func DefineRawRestoreFlags(command *cobra.Command) {
 	command.Flags().StringP(flagKeyFormat, "", "hex", "start/end key format, support raw|escaped|hex")
-	command.Flags().StringP(flagTiKVColumnFamily, "", "default", "restore specify cf, correspond to tikv cf")
+	command.Flags().StringSliceP(flagTiKVColumnFamily, "", []string{"default"},
+		"restore specify cf, correspond to tikv cf, can be repeated to restore several cfs")
+	setConfigKey(command.Flags(), flagTiKVColumnFamily, "cfs")
 	command.Flags().StringP(flagStartKey, "", "", "restore raw kv start key, key is inclusive")
 	command.Flags().StringP(flagEndKey, "", "", "restore raw kv end key, key is exclusive")
+	command.Flags().StringArray(flagRawRange, nil,
+		"restore raw kv range in the form of start,end, can be repeated to restore several ranges")
+	command.Flags().String(flagRawRangeFile, "",
+		"the file contains raw kv ranges to restore, one range in the form of start,end per line")
+	command.Flags().String(flagRewritePrefix, "",
+		"rewrite the prefix of restored keys in the form of old=new, keys are in the given format")
 
 	command.Flags().Bool(flagOnline, false, "Whether online when restore")
 	// TODO remove hidden flag if it's stable
@@ -42,20 +68,78 @@ func (cfg *RestoreRawConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.TunePDSchedule, err = flags.GetBool(flagTunePDSchedule)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.VerifyFilesConfig.parseFromFlags(flags); err != nil {
+		return err
+	}
+	rewritePrefix, err := flags.GetString(flagRewritePrefix)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(rewritePrefix) != 0 {
+		format, err := flags.GetString(flagKeyFormat)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		cfg.OldKeyPrefix, cfg.NewKeyPrefix, err = parseRewritePrefix(format, rewritePrefix)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
 	return cfg.RawKvConfig.ParseFromFlags(flags)
 }
 
+// parseRewritePrefix parses the old and new key prefixes from "old=new".
+func parseRewritePrefix(format, rewritePrefix string) (oldPrefix, newPrefix []byte, err error) {
+	parts := strings.SplitN(rewritePrefix, "=", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.Errorf("invalid --%s %q, it should be in the form of old=new",
+			flagRewritePrefix, rewritePrefix)
+	}
+	oldPrefix, err = utils.ParseKey(format, parts[0])
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if len(oldPrefix) == 0 {
+		return nil, nil, errors.Errorf("invalid --%s %q, the old prefix must not be empty",
+			flagRewritePrefix, rewritePrefix)
+	}
+	newPrefix, err = utils.ParseKey(format, parts[1])
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return oldPrefix, newPrefix, nil
+}
+
+// rawRestoreRange is a raw kv range to restore with the files of a cf.
+type rawRestoreRange struct {
+	startKey []byte
+	endKey   []byte
+	cf       string
+	files    []*backup.File
+}
+
 // RunRestoreRaw starts a raw kv restore task inside the current goroutine.
 func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreRawConfig) (err error) {
 	defer summary.Summary(cmdName)
+	defer func() {
+		writeReport(c, &cfg.Config, cmdName, utils.RestoreReportFile, err)
+	}()
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	if err = cfg.foldDeprecatedFields(); err != nil {
+		return err
+	}
 	mgr, err := newMgr(ctx, g, cfg.PD, cfg.TLS, conn.ErrorOnTiFlash, cfg.CheckRequirements)
 	if err != nil {
 		return err
 	}
 	defer mgr.Close()
+	collectClusterVersion(ctx, mgr.GetPDClient())
 
 	client, err := restore.NewRestoreClient(ctx, g, mgr.GetPDClient(), mgr.GetTiKV(), mgr.GetTLSConfig())
 	if err != nil {
@@ -68,7 +152,7 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 		client.EnableOnline()
 	}
 
-	u, _, backupMeta, err := ReadBackupMeta(ctx, utils.MetaFile, &cfg.Config)
+	u, s, backupMeta, err := ReadBackupMeta(ctx, utils.MetaFile, &cfg.Config)
 	if err != nil {
 		return err
 	}
@@ -81,50 +165,198 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 		return errors.New("cannot do raw restore from transactional data")
 	}
 
-	files, err := client.GetFilesInRawRange(cfg.StartKey, cfg.EndKey, cfg.CF)
-	if err != nil {
-		return errors.Trace(err)
+	var rewriteRules *restore.RewriteRules
+	if len(cfg.OldKeyPrefix) != 0 {
+		rewriteRules = restore.NewRawRewriteRules(cfg.OldKeyPrefix, cfg.NewKeyPrefix)
+	}
+
+	// The files of every range in every cf are restored separately.
+	restoreRanges := make([]rawRestoreRange, 0, len(cfg.CFs)*len(cfg.Ranges))
+	files := make([]*backup.File, 0)
+	for _, cf := range cfg.CFs {
+		for _, r := range cfg.Ranges {
+			if restore.IsEmptyRawRange(r.StartKey, r.EndKey, rewriteRules) {
+				// No key in the range has the prefix to rewrite.
+				continue
+			}
+			startKey, endKey := restore.ClipRawRange(r.StartKey, r.EndKey, rewriteRules)
+			rangeFiles, err := client.GetFilesInRawRange(startKey, endKey, cf)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			restoreRanges = append(restoreRanges, rawRestoreRange{
+				startKey: startKey,
+				endKey:   endKey,
+				cf:       cf,
+				files:    rangeFiles,
+			})
+			files = append(files, rangeFiles...)
+		}
+	}
+	if len(restoreRanges) == 0 {
+		return errors.New("no key in the restoring range has the prefix to rewrite")
 	}
 
 	if len(files) == 0 {
 		return errors.New("all files are filtered out from the backup archive, nothing to restore")
 	}
 	summary.CollectInt("restore files", len(files))
+	// Find the missing or corrupt files before changing the cluster.
+	if err = cfg.verifyFiles(ctx, u, s, files); err != nil {
+		return err
+	}
 
 	ranges, err := restore.ValidateFileRanges(files, nil)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
+	// Split/Scatter + Download/Ingest
 	// Redirect to log if there is no log file to avoid unreadable output.
-	// TODO: How to show progress?
 	updateCh := g.StartProgress(
 		ctx,
 		"Raw Restore",
-		// Split/Scatter + Download/Ingest
 		int64(len(ranges)+len(files)),
 		!cfg.LogProgress)
+	glue.SetTotalBytes(updateCh, filesTotalBytes(files))
 
-	err = restore.SplitRanges(ctx, client, ranges, nil, updateCh)
+	// The regions are split on the rewritten ranges, where the keys are
+	// restored to.
+	err = restore.SplitRanges(ctx, client, restore.RewriteRawRanges(ranges, rewriteRules), nil, updateCh)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	removedSchedulers, err := restorePreWork(ctx, client, mgr)
+	etcdCli, err := newClusterConfigClient(ctx, cfg.PD, mgr.GetTLSConfig())
 	if err != nil {
 		return errors.Trace(err)
 	}
-	defer restorePostWork(ctx, client, mgr, removedSchedulers)
-
-	err = client.RestoreRaw(cfg.StartKey, cfg.EndKey, files, updateCh)
+	defer etcdCli.Close()
+	clusterCfg, err := restorePreWork(ctx, client, mgr, etcdCli, cfg.TunePDSchedule)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	defer restorePostWork(ctx, client, mgr, clusterCfg, etcdCli)
+
+	for _, r := range restoreRanges {
+		err = client.RestoreRaw(r.startKey, r.endKey, r.files, rewriteRules, updateCh)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
 
 	// Restore has finished.
 	updateCh.Close()
 
+	// Checksum
+	if cfg.Checksum {
+		updateCh = g.StartProgress(
+			ctx, "Checksum", int64(len(restoreRanges)), !cfg.LogProgress)
+		err = validateRawChecksums(ctx, mgr, s, cfg, restoreRanges, rewriteRules, updateCh)
+		if err != nil {
+			return err
+		}
+		updateCh.Close()
+	}
+
 	// Set task summary to success status.
 	summary.SetSuccessStatus(true)
 	return nil
 }
+
+// validateRawChecksums scans the restored ranges and compares their checksums
+// with the ones calculated during backup. A range is skipped if the backup has
+// no checksum, or if it's not exactly composed of the backed up ranges.
+func validateRawChecksums(
+	ctx context.Context,
+	mgr *conn.Mgr,
+	s storage.ExternalStorage,
+	cfg *RestoreRawConfig,
+	restoreRanges []rawRestoreRange,
+	rewriteRules *restore.RewriteRules,
+	updateCh glue.Progress,
+) error {
+	start := time.Now()
+	defer func() {
+		summary.CollectDuration("restore checksum", time.Since(start))
+	}()
+	checksums, err := loadRawChecksums(ctx, s)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if checksums == nil {
+		log.Warn("skip raw checksum because the backup has no checksum")
+		return nil
+	}
+	var rewrite checksum.RawKeyRewriter
+	if rewriteRules != nil {
+		rewrite = func(key []byte) []byte {
+			if !bytes.HasPrefix(key, cfg.NewKeyPrefix) {
+				return key
+			}
+			return append(append([]byte{}, cfg.OldKeyPrefix...), key[len(cfg.NewKeyPrefix):]...)
+		}
+	}
+	for _, r := range restoreRanges {
+		expected, ok := expectedRawChecksum(checksums, r)
+		if !ok {
+			log.Warn("skip raw checksum because the range is not composed of backed up ranges",
+				zap.Binary("startKey", r.startKey),
+				zap.Binary("endKey", r.endKey),
+				zap.String("cf", r.cf))
+			updateCh.Inc()
+			continue
+		}
+		startKey, endKey := restore.RewriteRawRange(r.startKey, r.endKey, rewriteRules)
+		actual, err := checksum.CalculateRawChecksum(ctx, mgr.GetTiKV(), startKey, endKey, r.cf, rewrite)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		glue.AddWeight(updateCh, actual.TotalKvs, actual.TotalBytes)
+		if actual != expected {
+			log.Error("failed in validate raw checksum",
+				zap.Binary("startKey", r.startKey),
+				zap.Binary("endKey", r.endKey),
+				zap.String("cf", r.cf),
+				zap.Uint64("origin crc64", expected.Crc64Xor),
+				zap.Uint64("calculated crc64", actual.Crc64Xor),
+				zap.Uint64("origin total kvs", expected.TotalKvs),
+				zap.Uint64("calculated total kvs", actual.TotalKvs),
+				zap.Uint64("origin total bytes", expected.TotalBytes),
+				zap.Uint64("calculated total bytes", actual.TotalBytes))
+			return errors.New("mismatched checksum")
+		}
+		updateCh.Inc()
+	}
+	log.Info("validate raw checksum passed!!")
+	return nil
+}
+
+// expectedRawChecksum merges the checksums of the backed up ranges which
+// compose the restoring range. It returns false if any backed up range is
+// partially restored or has no checksum recorded, in which case the checksum
+// of the restoring range is unknown.
+func expectedRawChecksum(checksums []rawRangeChecksum, r rawRestoreRange) (checksum.RawChecksum, bool) {
+	var expected checksum.RawChecksum
+	found := false
+	for _, c := range checksums {
+		if c.Cf != r.cf {
+			continue
+		}
+		if (len(c.EndKey) > 0 && bytes.Compare(r.startKey, c.EndKey) >= 0) ||
+			(len(r.endKey) > 0 && bytes.Compare(c.StartKey, r.endKey) >= 0) {
+			// The backed up range is out of the restoring range.
+			continue
+		}
+		if bytes.Compare(c.StartKey, r.startKey) < 0 || utils.CompareEndKey(c.EndKey, r.endKey) > 0 {
+			return checksum.RawChecksum{}, false
+		}
+		if c.Crc64Xor == 0 && c.TotalKvs != 0 {
+			// No checksum is recorded for the range.
+			return checksum.RawChecksum{}, false
+		}
+		expected.Merge(c.RawChecksum)
+		found = true
+	}
+	return expected, found
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/checksum"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+var _ = Suite(&testRestoreRawSuite{})
+
+type testRestoreRawSuite struct{}
+
+func (*testRestoreRawSuite) TestParseRewritePrefix(c *C) {
+	oldPrefix, newPrefix, err := parseRewritePrefix("raw", "old=new")
+	c.Assert(err, IsNil)
+	c.Assert(oldPrefix, DeepEquals, []byte("old"))
+	c.Assert(newPrefix, DeepEquals, []byte("new"))
+
+	oldPrefix, newPrefix, err = parseRewritePrefix("hex", "6161=")
+	c.Assert(err, IsNil)
+	c.Assert(oldPrefix, DeepEquals, []byte("aa"))
+	c.Assert(newPrefix, HasLen, 0)
+
+	_, _, err = parseRewritePrefix("raw", "old")
+	c.Assert(err, ErrorMatches, ".*it should be in the form of old=new")
+	_, _, err = parseRewritePrefix("raw", "=new")
+	c.Assert(err, ErrorMatches, ".*the old prefix must not be empty")
+	_, _, err = parseRewritePrefix("hex", "zz=00")
+	c.Assert(err, NotNil)
+}
+
+func (*testRestoreRawSuite) TestExpectedRawChecksum(c *C) {
+	checksums := []rawRangeChecksum{
+		{StartKey: []byte("a"), EndKey: []byte("c"), Cf: "default", RawChecksum: checksum.RawChecksum{
+			Crc64Xor: 1, TotalKvs: 10, TotalBytes: 100,
+		}},
+		{StartKey: []byte("c"), EndKey: []byte("e"), Cf: "default", RawChecksum: checksum.RawChecksum{
+			Crc64Xor: 2, TotalKvs: 20, TotalBytes: 200,
+		}},
+		{StartKey: []byte("a"), EndKey: []byte("e"), Cf: "write", RawChecksum: checksum.RawChecksum{
+			Crc64Xor: 4, TotalKvs: 40, TotalBytes: 400,
+		}},
+	}
+
+	expected, ok := expectedRawChecksum(checksums, rawRestoreRange{
+		startKey: []byte("a"), endKey: []byte("e"), cf: "default",
+	})
+	c.Assert(ok, IsTrue)
+	c.Assert(expected, Equals, checksum.RawChecksum{Crc64Xor: 3, TotalKvs: 30, TotalBytes: 300})
+
+	expected, ok = expectedRawChecksum(checksums, rawRestoreRange{
+		startKey: []byte("c"), endKey: []byte("e"), cf: "default",
+	})
+	c.Assert(ok, IsTrue)
+	c.Assert(expected, Equals, checksums[1].RawChecksum)
+
+	// The range [a, c) is partially restored.
+	_, ok = expectedRawChecksum(checksums, rawRestoreRange{
+		startKey: []byte("b"), endKey: []byte("e"), cf: "default",
+	})
+	c.Assert(ok, IsFalse)
+	_, ok = expectedRawChecksum(checksums, rawRestoreRange{
+		startKey: []byte("a"), endKey: []byte("e"), cf: "lock",
+	})
+	c.Assert(ok, IsFalse)
+	// No checksum is recorded for the range.
+	_, ok = expectedRawChecksum([]rawRangeChecksum{
+		{StartKey: []byte("a"), EndKey: []byte("c"), Cf: "default", RawChecksum: checksum.RawChecksum{
+			TotalKvs: 10, TotalBytes: 100,
+		}},
+	}, rawRestoreRange{startKey: []byte("a"), endKey: []byte("c"), cf: "default"})
+	c.Assert(ok, IsFalse)
+}
+
+func (*testRestoreRawSuite) TestSaveLoadRawChecksums(c *C) {
+	ctx := context.Background()
+	backend, err := storage.ParseBackend("local://"+c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	s, err := storage.Create(ctx, backend, false)
+	c.Assert(err, IsNil)
+
+	checksums, err := loadRawChecksums(ctx, s)
+	c.Assert(err, IsNil)
+	c.Assert(checksums, IsNil)
+
+	saved := []rawRangeChecksum{
+		{StartKey: []byte("a"), EndKey: []byte("c"), Cf: "default", RawChecksum: checksum.RawChecksum{
+			Crc64Xor: 1, TotalKvs: 10, TotalBytes: 100,
+		}},
+	}
+	c.Assert(saveRawChecksums(ctx, s, saved), IsNil)
+	checksums, err = loadRawChecksums(ctx, s)
+	c.Assert(err, IsNil)
+	c.Assert(checksums, DeepEquals, saved)
+}
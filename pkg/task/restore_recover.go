@@ -0,0 +1,176 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/conn"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/summary"
+)
+
+// clusterConfigKeyPrefix is the prefix of the PD etcd keys saving the cluster
+// config before restore. Every restore saves its own key, so the concurrent
+// restores don't overwrite or remove the config of each other.
+const clusterConfigKeyPrefix = "/br/restore/cluster-config/"
+
+const clusterConfigDialTimeout = 10 * time.Second
+
+// newClusterConfigClient connects to the etcd embedded in PD, which keeps the
+// cluster config saved by restore. It is kept by the cluster rather than the
+// BR host, so the cluster can be recovered from any host.
+func newClusterConfigClient(
+	ctx context.Context, pds []string, tlsConf *tls.Config,
+) (*clientv3.Client, error) {
+	endpoints := make([]string, 0, len(pds))
+	for _, addr := range pds {
+		if addr != "" && !strings.HasPrefix(addr, "http") {
+			if tlsConf != nil {
+				addr = "https://" + addr
+			} else {
+				addr = "http://" + addr
+			}
+		}
+		endpoints = append(endpoints, addr)
+	}
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: clusterConfigDialTimeout,
+		TLS:         tlsConf,
+		Context:     ctx,
+	})
+	return cli, errors.Annotatef(err, "cannot connect to the etcd of pd %v", pds)
+}
+
+// prepareClusterConfig returns the original cluster config, and saves it
+// before the cluster config is changed. If a running or interrupted restore
+// has saved the config, the oldest saved one is returned since the cluster
+// may still have the config changed by that restore, so that the original
+// config is recovered after this restore.
+// The current config is saved before the saved configs are listed, so the
+// config of the restore started first is always taken, even if several
+// restores start at the same time.
+// It returns the key saving the config of this restore.
+func prepareClusterConfig(
+	ctx context.Context, kv clientv3.KV, current func() (clusterConfig, error),
+) (clusterConfig, string, error) {
+	cfg, err := current()
+	if err != nil {
+		return clusterConfig{}, "", err
+	}
+	key := clusterConfigKeyPrefix + uuid.New().String()
+	if err = saveClusterConfig(ctx, kv, key, cfg); err != nil {
+		return clusterConfig{}, "", err
+	}
+	oldestKey, oldest, err := loadOldestClusterConfig(ctx, kv)
+	if err != nil {
+		removeClusterConfig(ctx, kv, key)
+		return clusterConfig{}, "", err
+	}
+	if oldestKey != key {
+		log.Warn("found the cluster config saved by another restore, "+
+			"it is recovered after this restore", zap.String("key", oldestKey))
+		if err = saveClusterConfig(ctx, kv, key, oldest); err != nil {
+			removeClusterConfig(ctx, kv, key)
+			return clusterConfig{}, "", err
+		}
+		cfg = oldest
+	}
+	return cfg, key, nil
+}
+
+func saveClusterConfig(ctx context.Context, kv clientv3.KV, key string, cfg clusterConfig) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("save cluster config", zap.String("key", key), zap.ByteString("config", data))
+	_, err = kv.Put(ctx, key, string(data))
+	return errors.Annotate(err, "save cluster config failed")
+}
+
+// loadOldestClusterConfig returns the cluster config saved by the earliest
+// restore, along with its key. The key is empty if no config is saved.
+func loadOldestClusterConfig(ctx context.Context, kv clientv3.KV) (string, clusterConfig, error) {
+	cfg := clusterConfig{}
+	resp, err := kv.Get(ctx, clusterConfigKeyPrefix, clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend), clientv3.WithLimit(1))
+	if err != nil {
+		return "", cfg, errors.Annotate(err, "load cluster config failed")
+	}
+	if len(resp.Kvs) == 0 {
+		return "", cfg, nil
+	}
+	if err = json.Unmarshal(resp.Kvs[0].Value, &cfg); err != nil {
+		return "", cfg, errors.Annotate(err, "parse cluster config failed")
+	}
+	return string(resp.Kvs[0].Key), cfg, nil
+}
+
+// removeClusterConfig removes the saved cluster config after the cluster is
+// recovered. It is best-effort, since the config is recovered already.
+func removeClusterConfig(ctx context.Context, kv clientv3.KV, key string, opts ...clientv3.OpOption) {
+	log.Info("remove saved cluster config", zap.String("key", key))
+	if _, err := kv.Delete(ctx, key, opts...); err != nil {
+		log.Warn("fail to remove saved cluster config", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// RunRestoreRecoverCluster recovers the cluster config changed by a restore
+// which exited without cleaning up, e.g. killed or out of memory.
+// It reads the cluster config saved by the restore, switches TiKV back to
+// normal mode and restores the PD schedulers and schedule config.
+func RunRestoreRecoverCluster(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConfig) error {
+	defer summary.Summary(cmdName)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	mgr, err := newMgr(ctx, g, cfg.PD, cfg.TLS, conn.SkipTiFlash, cfg.CheckRequirements)
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	etcdCli, err := newClusterConfigClient(ctx, cfg.PD, mgr.GetTLSConfig())
+	if err != nil {
+		return err
+	}
+	defer etcdCli.Close()
+	key, clusterCfg, err := loadOldestClusterConfig(ctx, etcdCli)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("no saved cluster config found, there is no interrupted restore to recover")
+	}
+
+	client, err := restore.NewRestoreClient(ctx, g, mgr.GetPDClient(), mgr.GetTiKV(), mgr.GetTLSConfig())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err = recoverClusterConfig(ctx, client, mgr, clusterCfg); err != nil {
+		return err
+	}
+	// All the saved configs are removed, since the restores saving them are
+	// not running and the original config is recovered.
+	removeClusterConfig(ctx, etcdCli, clusterConfigKeyPrefix, clientv3.WithPrefix())
+	summary.CollectInt("recover schedulers", len(clusterCfg.Schedulers))
+
+	// Set task summary to success status.
+	summary.SetSuccessStatus(true)
+	return nil
+}
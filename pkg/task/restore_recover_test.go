@@ -0,0 +1,258 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/pingcap/check"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
+
+	"github.com/pingcap/br/pkg/conn"
+)
+
+var _ = Suite(&testRestoreRecoverSuite{})
+
+type testRestoreRecoverSuite struct {
+	etcd    *embed.Etcd
+	etcdCli *clientv3.Client
+}
+
+func allocURL(c *C) *url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+	u, err := url.Parse("http://" + l.Addr().String())
+	c.Assert(err, IsNil)
+	return u
+}
+
+func (s *testRestoreRecoverSuite) SetUpSuite(c *C) {
+	cfg := embed.NewConfig()
+	cfg.Dir = c.MkDir()
+	cfg.Logger = "zap"
+	cfg.LogOutputs = []string{"stderr"}
+	cfg.LogLevel = "error"
+	clientURL, peerURL := allocURL(c), allocURL(c)
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	etcd, err := embed.StartEtcd(cfg)
+	c.Assert(err, IsNil)
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(time.Minute):
+		etcd.Close()
+		c.Fatal("embedded etcd is not ready")
+	}
+	s.etcd = etcd
+
+	// The PD address is given without scheme like the --pd flag.
+	s.etcdCli, err = newClusterConfigClient(context.Background(), []string{clientURL.Host}, nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *testRestoreRecoverSuite) TearDownSuite(c *C) {
+	if s.etcdCli != nil {
+		s.etcdCli.Close()
+	}
+	if s.etcd != nil {
+		s.etcd.Close()
+	}
+}
+
+func (s *testRestoreRecoverSuite) TearDownTest(c *C) {
+	_, err := s.etcdCli.Delete(context.Background(), clusterConfigKeyPrefix, clientv3.WithPrefix())
+	c.Assert(err, IsNil)
+}
+
+func (s *testRestoreRecoverSuite) savedKeys(c *C) []string {
+	resp, err := s.etcdCli.Get(context.Background(), clusterConfigKeyPrefix,
+		clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	c.Assert(err, IsNil)
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *testRestoreRecoverSuite) TestSaveLoadClusterConfig(c *C) {
+	ctx := context.Background()
+	key, _, err := loadOldestClusterConfig(ctx, s.etcdCli)
+	c.Assert(err, IsNil)
+	c.Assert(key, Equals, "")
+
+	cfg := clusterConfig{
+		Schedulers: []string{"balance-leader-scheduler", "balance-region-scheduler"},
+		ScheduleCfg: map[string]interface{}{
+			"max-merge-region-keys": float64(200000),
+			"leader-schedule-limit": float64(4),
+		},
+	}
+	first, second := clusterConfigKeyPrefix+"b", clusterConfigKeyPrefix+"a"
+	c.Assert(saveClusterConfig(ctx, s.etcdCli, first, cfg), IsNil)
+	c.Assert(saveClusterConfig(ctx, s.etcdCli, second, clusterConfig{}), IsNil)
+	// The config saved first is loaded, whatever the keys are.
+	key, loaded, err := loadOldestClusterConfig(ctx, s.etcdCli)
+	c.Assert(err, IsNil)
+	c.Assert(key, Equals, first)
+	c.Assert(loaded, DeepEquals, cfg)
+
+	removeClusterConfig(ctx, s.etcdCli, first)
+	c.Assert(s.savedKeys(c), DeepEquals, []string{second})
+	removeClusterConfig(ctx, s.etcdCli, clusterConfigKeyPrefix, clientv3.WithPrefix())
+	c.Assert(s.savedKeys(c), HasLen, 0)
+}
+
+func (s *testRestoreRecoverSuite) TestPrepareClusterConfig(c *C) {
+	ctx := context.Background()
+	original := clusterConfig{Schedulers: []string{"balance-leader-scheduler"}}
+	cfg, key, err := prepareClusterConfig(ctx, s.etcdCli, func() (clusterConfig, error) {
+		return original, nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg, DeepEquals, original)
+	c.Assert(s.savedKeys(c), DeepEquals, []string{key})
+
+	// The restore is interrupted or still running, the cluster config saved
+	// by it is reused by the next restore instead of the changed one.
+	cfg, nextKey, err := prepareClusterConfig(ctx, s.etcdCli, func() (clusterConfig, error) {
+		return clusterConfig{}, nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg, DeepEquals, original)
+	c.Assert(nextKey, Not(Equals), key)
+
+	// The first restore finishes, which doesn't remove the config saved by the
+	// next one, and the next one still has the original config.
+	removeClusterConfig(ctx, s.etcdCli, key)
+	c.Assert(s.savedKeys(c), DeepEquals, []string{nextKey})
+	_, loaded, err := loadOldestClusterConfig(ctx, s.etcdCli)
+	c.Assert(err, IsNil)
+	c.Assert(loaded, DeepEquals, original)
+
+	// Removing the config is best-effort.
+	removeClusterConfig(ctx, s.etcdCli, nextKey)
+	removeClusterConfig(ctx, s.etcdCli, nextKey)
+	cfg, _, err = prepareClusterConfig(ctx, s.etcdCli, func() (clusterConfig, error) {
+		return clusterConfig{}, nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg, DeepEquals, clusterConfig{})
+}
+
+// fakePDConfig is the PD HTTP API of the schedulers and the schedule config.
+type fakePDConfig struct {
+	mu          sync.Mutex
+	paused      map[string]int64
+	scheduleCfg map[string]interface{}
+	// scheduleUpdates is the number of requests updating the schedule config.
+	scheduleUpdates int
+}
+
+func (pd *fakePDConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.URL.Path == "/pd/api/v1/config/schedule" && r.Method == http.MethodPost:
+		pd.scheduleUpdates++
+		cfg := make(map[string]interface{})
+		if json.Unmarshal(body, &cfg) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for k, v := range cfg {
+			pd.scheduleCfg[k] = v
+		}
+	case strings.HasPrefix(r.URL.Path, "/pd/api/v1/schedulers/") && r.Method == http.MethodPost:
+		var input map[string]int64
+		if json.Unmarshal(body, &input) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pd.paused[strings.TrimPrefix(r.URL.Path, "/pd/api/v1/schedulers/")] = input["delay"]
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *testRestoreRecoverSuite) TestRecoverPDConfig(c *C) {
+	ctx := context.Background()
+	original := clusterConfig{
+		Schedulers: []string{"balance-leader-scheduler", "balance-region-scheduler"},
+		ScheduleCfg: map[string]interface{}{
+			"max-merge-region-keys": float64(200000),
+			"max-merge-region-size": float64(20),
+			"leader-schedule-limit": float64(4),
+			"region-schedule-limit": float64(2048),
+			"max-snapshot-count":    float64(3),
+		},
+	}
+	c.Assert(saveClusterConfig(ctx, s.etcdCli, clusterConfigKeyPrefix+"1", original), IsNil)
+
+	// The restore exits after changing the cluster, the schedule config is
+	// kept by PD.
+	pd := &fakePDConfig{
+		paused: map[string]int64{"balance-leader-scheduler": 300, "balance-region-scheduler": 300},
+		scheduleCfg: map[string]interface{}{
+			"max-merge-region-keys": float64(0),
+			"max-merge-region-size": float64(0),
+			"leader-schedule-limit": float64(12),
+			"region-schedule-limit": float64(40),
+			"max-snapshot-count":    float64(9),
+		},
+	}
+	server := httptest.NewServer(pd)
+	defer server.Close()
+	mgr := &conn.Mgr{}
+	mgr.SetPDHTTP([]string{server.URL}, &http.Client{})
+
+	_, saved, err := loadOldestClusterConfig(ctx, s.etcdCli)
+	c.Assert(err, IsNil)
+	c.Assert(recoverPDConfig(ctx, mgr, saved), IsNil)
+	c.Assert(pd.scheduleCfg, DeepEquals, original.ScheduleCfg)
+	c.Assert(pd.paused, DeepEquals, map[string]int64{
+		"balance-leader-scheduler": 0,
+		"balance-region-scheduler": 0,
+	})
+}
+
+func (s *testRestoreRecoverSuite) TestRecoverPDConfigWithoutScheduleConfig(c *C) {
+	ctx := context.Background()
+	// The restore doesn't tune the schedule config, so it's not saved.
+	saved := clusterConfig{Schedulers: []string{"balance-leader-scheduler"}}
+	scheduleCfg := map[string]interface{}{
+		"max-merge-region-keys": float64(200000),
+		"leader-schedule-limit": float64(4),
+	}
+	pd := &fakePDConfig{
+		paused:      map[string]int64{"balance-leader-scheduler": 300},
+		scheduleCfg: map[string]interface{}{},
+	}
+	for k, v := range scheduleCfg {
+		pd.scheduleCfg[k] = v
+	}
+	server := httptest.NewServer(pd)
+	defer server.Close()
+	mgr := &conn.Mgr{}
+	mgr.SetPDHTTP([]string{server.URL}, &http.Client{})
+
+	c.Assert(recoverPDConfig(ctx, mgr, saved), IsNil)
+	c.Assert(pd.paused, DeepEquals, map[string]int64{"balance-leader-scheduler": 0})
+	c.Assert(pd.scheduleCfg, DeepEquals, scheduleCfg)
+	c.Assert(pd.scheduleUpdates, Equals, 0)
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+)
+
+var _ = Suite(&testRestoreSuite{})
+
+type testRestoreSuite struct{}
+
+func (s *testRestoreSuite) TestVerifyFiles(c *C) {
+	ctx := context.Background()
+	_, stg := createLocalStorage(c, ctx)
+	files := []*backup.File{{Name: "1_write.sst", Size_: 10}}
+	cfg := VerifyFilesConfig{VerifyFiles: true, VerifyFilesConcurrency: 1}
+
+	s3 := &backup.StorageBackend{Backend: &backup.StorageBackend_S3{S3: &backup.S3{Bucket: "bucket"}}}
+	err := cfg.verifyFiles(ctx, s3, stg, files)
+	c.Assert(err, ErrorMatches, "1 of 1 backup files are missing or corrupt:\n1_write.sst is missing")
+
+	// The files in local storage are on the TiKV nodes.
+	local := &backup.StorageBackend{Backend: &backup.StorageBackend_Local{Local: &backup.Local{Path: "/tmp"}}}
+	c.Assert(cfg.verifyFiles(ctx, local, stg, files), IsNil)
+
+	cfg.VerifyFiles = false
+	c.Assert(cfg.verifyFiles(ctx, s3, stg, files), IsNil)
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+)
+
+// readableBackupMeta is the human readable form of backup.BackupMeta. The
+// schemas and DDL jobs are expanded as JSON, and the keys are in hex. The
+// decoded keys are only for reading, they are ignored when decoding.
+type readableBackupMeta struct {
+	ClusterID      uint64              `json:"cluster_id,omitempty"`
+	ClusterVersion string              `json:"cluster_version,omitempty"`
+	Files          []*readableFile     `json:"files,omitempty"`
+	StartVersion   uint64              `json:"start_version,omitempty"`
+	EndVersion     uint64              `json:"end_version,omitempty"`
+	Schemas        []*readableSchema   `json:"schemas,omitempty"`
+	IsRawKv        bool                `json:"is_raw_kv,omitempty"`
+	RawRanges      []*readableRawRange `json:"raw_ranges,omitempty"`
+	Ddls           json.RawMessage     `json:"ddls,omitempty"`
+}
+
+type readableFile struct {
+	Name            string `json:"name,omitempty"`
+	Sha256          string `json:"sha256,omitempty"`
+	StartKey        string `json:"start_key,omitempty"`
+	StartKeyDecoded string `json:"start_key_decoded,omitempty"`
+	EndKey          string `json:"end_key,omitempty"`
+	EndKeyDecoded   string `json:"end_key_decoded,omitempty"`
+	StartVersion    uint64 `json:"start_version,omitempty"`
+	EndVersion      uint64 `json:"end_version,omitempty"`
+	Crc64Xor        uint64 `json:"crc64xor,omitempty"`
+	TotalKvs        uint64 `json:"total_kvs,omitempty"`
+	TotalBytes      uint64 `json:"total_bytes,omitempty"`
+	Cf              string `json:"cf,omitempty"`
+	Size            uint64 `json:"size,omitempty"`
+}
+
+type readableSchema struct {
+	Db              json.RawMessage `json:"db,omitempty"`
+	Table           json.RawMessage `json:"table,omitempty"`
+	Crc64Xor        uint64          `json:"crc64xor,omitempty"`
+	TotalKvs        uint64          `json:"total_kvs,omitempty"`
+	TotalBytes      uint64          `json:"total_bytes,omitempty"`
+	TiflashReplicas uint32          `json:"tiflash_replicas,omitempty"`
+}
+
+type readableRawRange struct {
+	StartKey string `json:"start_key,omitempty"`
+	EndKey   string `json:"end_key,omitempty"`
+	Cf       string `json:"cf,omitempty"`
+}
+
+// MarshalBackupMetaReadable encodes the backupmeta as human readable JSON,
+// which can be decoded by UnmarshalBackupMetaReadable.
+func MarshalBackupMetaReadable(meta *backup.BackupMeta) ([]byte, error) {
+	readable := &readableBackupMeta{
+		ClusterID:      meta.ClusterId,
+		ClusterVersion: meta.ClusterVersion,
+		StartVersion:   meta.StartVersion,
+		EndVersion:     meta.EndVersion,
+		IsRawKv:        meta.IsRawKv,
+	}
+	var err error
+	if readable.Ddls, err = toRawMessage(meta.Ddls); err != nil {
+		return nil, errors.Annotate(err, "ddls is not json")
+	}
+	for _, file := range meta.Files {
+		readable.Files = append(readable.Files, &readableFile{
+			Name:            file.Name,
+			Sha256:          hex.EncodeToString(file.Sha256),
+			StartKey:        hex.EncodeToString(file.StartKey),
+			StartKeyDecoded: DescribeTableKey(file.StartKey),
+			EndKey:          hex.EncodeToString(file.EndKey),
+			EndKeyDecoded:   DescribeTableKey(file.EndKey),
+			StartVersion:    file.StartVersion,
+			EndVersion:      file.EndVersion,
+			Crc64Xor:        file.Crc64Xor,
+			TotalKvs:        file.TotalKvs,
+			TotalBytes:      file.TotalBytes,
+			Cf:              file.Cf,
+			Size:            file.Size_,
+		})
+	}
+	for i, schema := range meta.Schemas {
+		db, err := toRawMessage(schema.Db)
+		if err != nil {
+			return nil, errors.Annotatef(err, "db of schema %d is not json", i)
+		}
+		table, err := toRawMessage(schema.Table)
+		if err != nil {
+			return nil, errors.Annotatef(err, "table of schema %d is not json", i)
+		}
+		readable.Schemas = append(readable.Schemas, &readableSchema{
+			Db:              db,
+			Table:           table,
+			Crc64Xor:        schema.Crc64Xor,
+			TotalKvs:        schema.TotalKvs,
+			TotalBytes:      schema.TotalBytes,
+			TiflashReplicas: schema.TiflashReplicas,
+		})
+	}
+	for _, r := range meta.RawRanges {
+		readable.RawRanges = append(readable.RawRanges, &readableRawRange{
+			StartKey: hex.EncodeToString(r.StartKey),
+			EndKey:   hex.EncodeToString(r.EndKey),
+			Cf:       r.Cf,
+		})
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// Keep the schemas as they are in the backupmeta.
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(readable); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBackupMetaReadable decodes the JSON encoded by
+// MarshalBackupMetaReadable.
+func UnmarshalBackupMetaReadable(data []byte) (*backup.BackupMeta, error) {
+	readable := &readableBackupMeta{}
+	if err := json.Unmarshal(data, readable); err != nil {
+		return nil, errors.Trace(err)
+	}
+	meta := &backup.BackupMeta{
+		ClusterId:      readable.ClusterID,
+		ClusterVersion: readable.ClusterVersion,
+		StartVersion:   readable.StartVersion,
+		EndVersion:     readable.EndVersion,
+		IsRawKv:        readable.IsRawKv,
+	}
+	var err error
+	if meta.Ddls, err = fromRawMessage(readable.Ddls); err != nil {
+		return nil, err
+	}
+	for _, file := range readable.Files {
+		f := &backup.File{
+			Name:         file.Name,
+			StartVersion: file.StartVersion,
+			EndVersion:   file.EndVersion,
+			Crc64Xor:     file.Crc64Xor,
+			TotalKvs:     file.TotalKvs,
+			TotalBytes:   file.TotalBytes,
+			Cf:           file.Cf,
+			Size_:        file.Size,
+		}
+		if f.Sha256, err = decodeHex(file.Sha256); err != nil {
+			return nil, errors.Annotatef(err, "invalid sha256 of %s", file.Name)
+		}
+		if f.StartKey, err = decodeHex(file.StartKey); err != nil {
+			return nil, errors.Annotatef(err, "invalid start key of %s", file.Name)
+		}
+		if f.EndKey, err = decodeHex(file.EndKey); err != nil {
+			return nil, errors.Annotatef(err, "invalid end key of %s", file.Name)
+		}
+		meta.Files = append(meta.Files, f)
+	}
+	for _, schema := range readable.Schemas {
+		s := &backup.Schema{
+			Crc64Xor:        schema.Crc64Xor,
+			TotalKvs:        schema.TotalKvs,
+			TotalBytes:      schema.TotalBytes,
+			TiflashReplicas: schema.TiflashReplicas,
+		}
+		if s.Db, err = fromRawMessage(schema.Db); err != nil {
+			return nil, err
+		}
+		if s.Table, err = fromRawMessage(schema.Table); err != nil {
+			return nil, err
+		}
+		meta.Schemas = append(meta.Schemas, s)
+	}
+	for _, r := range readable.RawRanges {
+		rawRange := &backup.RawRange{Cf: r.Cf}
+		if rawRange.StartKey, err = decodeHex(r.StartKey); err != nil {
+			return nil, errors.Annotate(err, "invalid start key of raw range")
+		}
+		if rawRange.EndKey, err = decodeHex(r.EndKey); err != nil {
+			return nil, errors.Annotate(err, "invalid end key of raw range")
+		}
+		meta.RawRanges = append(meta.RawRanges, rawRange)
+	}
+	return meta, nil
+}
+
+func toRawMessage(data []byte) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, errors.New("invalid json")
+	}
+	return json.RawMessage(data), nil
+}
+
+// fromRawMessage compacts the indented JSON, so the JSON encoded by
+// json.Marshal is restored as is.
+func fromRawMessage(data json.RawMessage) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(s)
+	return data, errors.Trace(err)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"encoding/json"
+
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/tablecodec"
+)
+
+type testBackupMetaJSONSuite struct{}
+
+var _ = Suite(&testBackupMetaJSONSuite{})
+
+func (r *testBackupMetaJSONSuite) TestRoundTrip(c *C) {
+	dbData, err := json.Marshal(&model.DBInfo{ID: 1, Name: model.NewCIStr("test")})
+	c.Assert(err, IsNil)
+	tableData, err := json.Marshal(&model.TableInfo{ID: 2, Name: model.NewCIStr("t"), Comment: "<a&b>"})
+	c.Assert(err, IsNil)
+	meta := &backup.BackupMeta{
+		ClusterId:      1,
+		ClusterVersion: `"4.0.0"`,
+		StartVersion:   10,
+		EndVersion:     20,
+		Files: []*backup.File{{
+			Name:       "1_2_default.sst",
+			Sha256:     []byte{1, 2, 3},
+			StartKey:   tablecodec.GenTableRecordPrefix(2),
+			EndKey:     tablecodec.GenTableRecordPrefix(3),
+			Crc64Xor:   4,
+			TotalKvs:   5,
+			TotalBytes: 6,
+			Cf:         "default",
+			Size_:      7,
+		}},
+		Schemas: []*backup.Schema{
+			{Db: dbData, Table: tableData, Crc64Xor: 4, TotalKvs: 5, TotalBytes: 6, TiflashReplicas: 1},
+			{Db: dbData},
+		},
+		RawRanges: []*backup.RawRange{{StartKey: []byte("a"), Cf: "default"}},
+		Ddls:      []byte(`[{"id":1,"query":"create table t (a int)"}]`),
+	}
+
+	data, err := MarshalBackupMetaReadable(meta)
+	c.Assert(err, IsNil)
+	var readable map[string]interface{}
+	c.Assert(json.Unmarshal(data, &readable), IsNil)
+	file := readable["files"].([]interface{})[0].(map[string]interface{})
+	c.Assert(file["sha256"], Equals, "010203")
+	c.Assert(file["start_key_decoded"], Equals, "t2_r")
+	c.Assert(file["end_key_decoded"], Equals, "t3_r")
+	schema := readable["schemas"].([]interface{})[0].(map[string]interface{})
+	c.Assert(schema["table"].(map[string]interface{})["comment"], Equals, "<a&b>")
+	c.Assert(readable["ddls"].([]interface{})[0].(map[string]interface{})["query"], Equals, "create table t (a int)")
+
+	decoded, err := UnmarshalBackupMetaReadable(data)
+	c.Assert(err, IsNil)
+	expected, err := proto.Marshal(meta)
+	c.Assert(err, IsNil)
+	actual, err := proto.Marshal(decoded)
+	c.Assert(err, IsNil)
+	c.Assert(actual, BytesEquals, expected)
+
+	meta.Schemas[0].Table = []byte("not json")
+	_, err = MarshalBackupMetaReadable(meta)
+	c.Assert(err, ErrorMatches, "table of schema 0 is not json.*")
+}
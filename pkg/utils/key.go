@@ -8,8 +8,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap"
 )
 
 // ParseKey parse key by given format.
@@ -88,3 +93,227 @@ func CompareEndKey(a, b []byte) int {
 
 	return bytes.Compare(a, b)
 }
+
+// DescribeTableKey decodes the key of a table, a row or an index into a human
+// readable form, e.g. `t42`, `t42_r100` or `t42_i1_[a,1]`. The key may be a
+// prefix, like the start and end keys of a backup file. An empty string is
+// returned if the key is not a table key.
+func DescribeTableKey(key []byte) string {
+	return decodeTableKey(key).String()
+}
+
+// tableKey is a decoded table, row or index key.
+type tableKey struct {
+	tableID    int64
+	isIndex    bool
+	hasIndexID bool
+	indexID    int64
+	// desc is the readable form of the key, it is empty if the key is not a
+	// table key.
+	desc string
+}
+
+func (k tableKey) String() string {
+	return k.desc
+}
+
+func decodeTableKey(key []byte) (k tableKey) {
+	if !bytes.HasPrefix(key, tablecodec.TablePrefix()) {
+		return
+	}
+	rest, tableID, err := codec.DecodeInt(key[len(tablecodec.TablePrefix()):])
+	if err != nil {
+		return
+	}
+	k.tableID = tableID
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "t%d", tableID)
+	switch {
+	case len(rest) == 0:
+	case bytes.HasPrefix(rest, []byte("_r")):
+		buf.WriteString("_r")
+		rest = rest[2:]
+		if len(rest) == 8 {
+			_, handle, _ := codec.DecodeInt(rest)
+			fmt.Fprintf(&buf, "%d", handle)
+			rest = nil
+			break
+		}
+		// It is a common handle.
+		rest = describeDatums(&buf, "", rest)
+	case bytes.HasPrefix(rest, []byte("_i")):
+		k.isIndex = true
+		buf.WriteString("_i")
+		rest = rest[2:]
+		values, indexID, err := codec.DecodeInt(rest)
+		if err != nil {
+			break
+		}
+		k.hasIndexID, k.indexID = true, indexID
+		fmt.Fprintf(&buf, "%d", indexID)
+		rest = describeDatums(&buf, "_", values)
+	}
+	// Append the bytes can not be decoded.
+	if len(rest) > 0 {
+		fmt.Fprintf(&buf, "_0x%s", hex.EncodeToString(rest))
+	}
+	k.desc = buf.String()
+	return
+}
+
+// describeDatums writes the memcomparable encoded datums as `[a,1]` after the
+// prefix, and returns the bytes can not be decoded.
+func describeDatums(buf *strings.Builder, prefix string, data []byte) []byte {
+	var values []string
+	for len(data) > 0 {
+		rest, d, err := codec.DecodeOne(data)
+		if err != nil {
+			break
+		}
+		value, err := d.ToString()
+		if err != nil {
+			break
+		}
+		values = append(values, value)
+		data = rest
+	}
+	if len(values) > 0 {
+		fmt.Fprintf(buf, "%s[%s]", prefix, strings.Join(values, ","))
+	}
+	return data
+}
+
+// KeyFormatter formats keys as hex with their decoded forms. It recognizes the
+// table, row and index keys, and the memcomparable encoded keys used by PD and
+// TiKV. The table and index IDs are resolved to names if the formatter is
+// created from a backupmeta. A nil formatter formats keys without names.
+type KeyFormatter struct {
+	// tables maps the table and partition IDs to names.
+	tables map[int64]string
+	// indices maps the table and partition IDs to the names of indices.
+	indices map[int64]map[int64]string
+}
+
+// NewKeyFormatter creates a KeyFormatter which resolves the IDs of the tables
+// in the backup.
+func NewKeyFormatter(meta *backup.BackupMeta) (*KeyFormatter, error) {
+	dbs, err := LoadBackupTables(meta)
+	if err != nil {
+		return nil, err
+	}
+	f := &KeyFormatter{tables: make(map[int64]string), indices: make(map[int64]map[int64]string)}
+	for _, db := range dbs {
+		for _, table := range db.Tables {
+			name := EncloseName(db.Info.Name.O) + "." + EncloseName(table.Info.Name.O)
+			indices := make(map[int64]string, len(table.Info.Indices))
+			for _, index := range table.Info.Indices {
+				indices[index.ID] = index.Name.O
+			}
+			f.tables[table.Info.ID] = name
+			f.indices[table.Info.ID] = indices
+			if table.Info.Partition == nil {
+				continue
+			}
+			for _, def := range table.Info.Partition.Definitions {
+				f.tables[def.ID] = name + " partition " + EncloseName(def.Name.O)
+				f.indices[def.ID] = indices
+			}
+		}
+	}
+	return f, nil
+}
+
+// Describe returns the decoded form of the key, or an empty string if the key
+// can not be decoded. For example, the row key 100 of the table 42 is
+// described as "t42_r100 `test`.`t`", and it is described as
+// "encoded t42_r100 `test`.`t`" after memcomparable encoded.
+func (f *KeyFormatter) Describe(key []byte) string {
+	// The data keys in TiKV are prefixed by 'z', and suffixed by the TS if they
+	// are MVCC keys.
+	for _, prefix := range []string{"", "z"} {
+		if !bytes.HasPrefix(key, []byte(prefix)) {
+			continue
+		}
+		rest, decoded, err := codec.DecodeBytes(key[len(prefix):], nil)
+		if err != nil || (len(rest) != 0 && len(rest) != 8) {
+			continue
+		}
+		desc := f.describeRaw(decoded)
+		if desc == "" {
+			desc = hex.EncodeToString(decoded)
+		}
+		desc = "encoded " + desc
+		if prefix != "" {
+			desc = "data key " + desc
+		}
+		if len(rest) == 8 {
+			_, ts, _ := codec.DecodeUintDesc(rest)
+			desc += fmt.Sprintf(" ts %d", ts)
+		}
+		return desc
+	}
+	return f.describeRaw(key)
+}
+
+func (f *KeyFormatter) describeRaw(key []byte) string {
+	k := decodeTableKey(key)
+	if k.desc == "" || f == nil {
+		return k.desc
+	}
+	name, ok := f.tables[k.tableID]
+	if !ok {
+		return k.desc
+	}
+	desc := k.desc + " " + name
+	if k.hasIndexID {
+		if index, ok := f.indices[k.tableID][k.indexID]; ok {
+			desc += " index " + EncloseName(index)
+		}
+	}
+	return desc
+}
+
+// Format returns the key in hex, followed by its decoded form in parentheses
+// if it can be decoded.
+func (f *KeyFormatter) Format(key []byte) string {
+	desc := f.Describe(key)
+	if desc == "" {
+		return hex.EncodeToString(key)
+	}
+	return hex.EncodeToString(key) + " (" + desc + ")"
+}
+
+// FormatKey formats the key by a KeyFormatter without names.
+func FormatKey(key []byte) string {
+	return (*KeyFormatter)(nil).Format(key)
+}
+
+type formattedKey []byte
+
+func (k formattedKey) String() string {
+	return FormatKey(k)
+}
+
+// logDecodedKeys is 1 if the keys logged by ZapKey are formatted.
+var logDecodedKeys int32
+
+// SetLogDecodedKeys sets whether the keys logged by ZapKey are formatted by
+// FormatKey, they are logged as is by default.
+func SetLogDecodedKeys(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&logDecodedKeys, v)
+}
+
+// ZapKey returns a log field of the key, which is formatted by FormatKey if it
+// is enabled by SetLogDecodedKeys. The key is only formatted if the log is
+// written, so it is used by the error logs to help mapping a failing range to
+// a table.
+func ZapKey(name string, key []byte) zap.Field {
+	if atomic.LoadInt32(&logDecodedKeys) == 0 {
+		return zap.Binary(name, key)
+	}
+	return zap.Stringer(name, formattedKey(key))
+}
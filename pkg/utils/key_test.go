@@ -4,8 +4,17 @@ package utils
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap/zapcore"
 )
 
 type testKeySuite struct{}
@@ -51,3 +60,84 @@ func (r *testKeySuite) TestCompareEndKey(c *C) {
 	res = CompareEndKey([]byte(""), []byte("1"))
 	c.Assert(res, Greater, 0)
 }
+
+func (r *testKeySuite) TestDescribeTableKey(c *C) {
+	indexKey := func(values ...types.Datum) []byte {
+		encoded, err := codec.EncodeKey(nil, nil, values...)
+		c.Assert(err, IsNil)
+		return tablecodec.EncodeIndexSeekKey(42, 1, encoded)
+	}
+	commonHandle, err := codec.EncodeKey(nil, nil, types.NewStringDatum("a"), types.NewIntDatum(1))
+	c.Assert(err, IsNil)
+	cases := []struct {
+		key      []byte
+		expected string
+	}{
+		{nil, ""},
+		{[]byte("a"), ""},
+		{[]byte("t"), ""},
+		{tablecodec.EncodeTablePrefix(42), "t42"},
+		{tablecodec.GenTableRecordPrefix(42), "t42_r"},
+		{tablecodec.EncodeRowKeyWithHandle(42, kv.IntHandle(-100)), "t42_r-100"},
+		{tablecodec.EncodeRowKey(42, commonHandle), "t42_r[a,1]"},
+		{tablecodec.GenTableIndexPrefix(42), "t42_i"},
+		{tablecodec.EncodeTableIndexPrefix(42, 1), "t42_i1"},
+		{indexKey(types.NewStringDatum("a"), types.NewIntDatum(1)), "t42_i1_[a,1]"},
+		{append(tablecodec.EncodeTableIndexPrefix(42, 1), 0xff), "t42_i1_0xff"},
+		{append(tablecodec.EncodeTablePrefix(42), 'x'), "t42_0x78"},
+	}
+	for _, ca := range cases {
+		c.Assert(DescribeTableKey(ca.key), Equals, ca.expected, Commentf("%x", ca.key))
+	}
+}
+
+func (r *testKeySuite) TestKeyFormatter(c *C) {
+	dbData, err := json.Marshal(&model.DBInfo{Name: model.NewCIStr("test")})
+	c.Assert(err, IsNil)
+	tableData, err := json.Marshal(&model.TableInfo{
+		ID:      42,
+		Name:    model.NewCIStr("t"),
+		Indices: []*model.IndexInfo{{ID: 1, Name: model.NewCIStr("idx")}},
+		Partition: &model.PartitionInfo{
+			Definitions: []model.PartitionDefinition{{ID: 43, Name: model.NewCIStr("p0")}},
+		},
+	})
+	c.Assert(err, IsNil)
+	formatter, err := NewKeyFormatter(&backup.BackupMeta{
+		Schemas: []*backup.Schema{{Db: dbData, Table: tableData}},
+	})
+	c.Assert(err, IsNil)
+
+	rowKey := tablecodec.EncodeRowKeyWithHandle(42, kv.IntHandle(100))
+	encoded := codec.EncodeBytes(nil, rowKey)
+	cases := []struct {
+		key      []byte
+		expected string
+	}{
+		{rowKey, "t42_r100 `test`.`t`"},
+		{tablecodec.EncodeTableIndexPrefix(42, 1), "t42_i1 `test`.`t` index `idx`"},
+		{tablecodec.EncodeTableIndexPrefix(43, 1), "t43_i1 `test`.`t` partition `p0` index `idx`"},
+		{tablecodec.EncodeTableIndexPrefix(42, 2), "t42_i2 `test`.`t`"},
+		{tablecodec.EncodeTablePrefix(44), "t44"},
+		{encoded, "encoded t42_r100 `test`.`t`"},
+		{codec.EncodeUintDesc(append([]byte("z"), encoded...), 5), "data key encoded t42_r100 `test`.`t` ts 5"},
+		{codec.EncodeBytes(nil, []byte("a")), "encoded 61"},
+		{[]byte("a"), ""},
+	}
+	for _, ca := range cases {
+		c.Assert(formatter.Describe(ca.key), Equals, ca.expected, Commentf("%x", ca.key))
+	}
+
+	c.Assert(formatter.Format(rowKey), Equals, hex.EncodeToString(rowKey)+" (t42_r100 `test`.`t`)")
+	c.Assert(FormatKey(rowKey), Equals, hex.EncodeToString(rowKey)+" (t42_r100)")
+	c.Assert(FormatKey([]byte("a")), Equals, "61")
+
+	// The keys are logged as is by default.
+	field := ZapKey("key", encoded)
+	c.Assert(field.Type, Equals, zapcore.BinaryType)
+	c.Assert(field.Interface, DeepEquals, encoded)
+	SetLogDecodedKeys(true)
+	defer SetLogDecodedKeys(false)
+	c.Assert(ZapKey("key", encoded).Interface.(fmt.Stringer).String(), Equals,
+		hex.EncodeToString(encoded)+" (encoded t42_r100)")
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"encoding/binary"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+)
+
+// The column families of the backup files of transactional kv.
+const (
+	DefaultCFName = "default"
+	WriteCFName   = "write"
+)
+
+// The types of the records in the write CF of TiKV.
+const (
+	WriteTypePut      byte = 'P'
+	WriteTypeDelete   byte = 'D'
+	WriteTypeLock     byte = 'L'
+	WriteTypeRollback byte = 'R'
+)
+
+const (
+	dataKeyPrefix      = 'z'
+	encodedTSLen       = 8
+	shortValuePrefix   = 'v'
+	overlappedRollback = 'R'
+	gcFencePrefix      = 'F'
+	gcFenceLen         = 8
+)
+
+// TrimDataKeyPrefix removes the 'z' prefix of the data keys of TiKV.
+func TrimDataKeyPrefix(key []byte) []byte {
+	if len(key) > 0 && key[0] == dataKeyPrefix {
+		return key[1:]
+	}
+	return key
+}
+
+// DecodeMVCCKey decodes the data key of transactional kv in TiKV, which is the
+// memcomparable encoded user key suffixed by the TS, and optionally prefixed
+// by 'z'. The TS is the start TS in the default CF, and the commit TS in the
+// write CF.
+func DecodeMVCCKey(key []byte) (userKey []byte, ts uint64, err error) {
+	rest, userKey, err := codec.DecodeBytes(TrimDataKeyPrefix(key), nil)
+	if err != nil || len(rest) != encodedTSLen {
+		return nil, 0, errors.Errorf("invalid mvcc key %x", key)
+	}
+	_, ts, err = codec.DecodeUintDesc(rest)
+	return userKey, ts, errors.Trace(err)
+}
+
+// EncodeMVCCKey encodes the user key and the TS as the key of transactional
+// kv in TiKV without the 'z' prefix, it is the reverse of DecodeMVCCKey.
+func EncodeMVCCKey(userKey []byte, ts uint64) []byte {
+	return codec.EncodeUintDesc(codec.EncodeBytes(nil, userKey), ts)
+}
+
+// Write is a record in the write CF of TiKV.
+type Write struct {
+	Type    byte
+	StartTS uint64
+	// ShortValue is the value stored in the write CF instead of the default
+	// CF, it is valid only if HasShortValue is true.
+	ShortValue    []byte
+	HasShortValue bool
+}
+
+// ParseWrite parses the value in the write CF of TiKV: the write type, the
+// start TS in varint, and the optional fields, e.g. the short value prefixed
+// by 'v' and its length.
+func ParseWrite(value []byte) (*Write, error) {
+	if len(value) == 0 {
+		return nil, errors.New("empty write")
+	}
+	w := &Write{Type: value[0]}
+	startTS, n := binary.Uvarint(value[1:])
+	if n <= 0 {
+		return nil, errors.New("invalid start ts")
+	}
+	w.StartTS = startTS
+	value = value[1+n:]
+	for len(value) > 0 {
+		switch value[0] {
+		case shortValuePrefix:
+			if len(value) < 2 || len(value) < 2+int(value[1]) {
+				return nil, errors.New("invalid short value")
+			}
+			end := 2 + int(value[1])
+			w.ShortValue, w.HasShortValue = value[2:end], true
+			value = value[end:]
+		case overlappedRollback:
+			value = value[1:]
+		case gcFencePrefix:
+			if len(value) < 1+gcFenceLen {
+				return nil, errors.New("invalid gc fence")
+			}
+			value = value[1+gcFenceLen:]
+		default:
+			// Ignore the unknown fields added by the later versions of TiKV.
+			return w, nil
+		}
+	}
+	return w, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testMVCCSuite{})
+
+type testMVCCSuite struct{}
+
+func (s *testMVCCSuite) TestMVCCKey(c *C) {
+	key := EncodeMVCCKey([]byte("key"), 42)
+	for _, k := range [][]byte{key, append([]byte{'z'}, key...)} {
+		userKey, ts, err := DecodeMVCCKey(k)
+		c.Assert(err, IsNil)
+		c.Assert(userKey, BytesEquals, []byte("key"))
+		c.Assert(ts, Equals, uint64(42))
+	}
+	_, _, err := DecodeMVCCKey(key[:len(key)-1])
+	c.Assert(err, ErrorMatches, "invalid mvcc key .*")
+}
+
+func (s *testMVCCSuite) TestParseWrite(c *C) {
+	// The put of start TS 300 with the short value "abc", followed by an
+	// overlapped rollback flag and a gc fence.
+	value := []byte{'P', 0xac, 0x02, 'v', 3, 'a', 'b', 'c', 'R', 'F', 0, 0, 0, 0, 0, 0, 0, 1}
+	w, err := ParseWrite(value)
+	c.Assert(err, IsNil)
+	c.Assert(w, DeepEquals, &Write{Type: WriteTypePut, StartTS: 300, ShortValue: []byte("abc"), HasShortValue: true})
+
+	w, err = ParseWrite([]byte{'D', 1})
+	c.Assert(err, IsNil)
+	c.Assert(w, DeepEquals, &Write{Type: WriteTypeDelete, StartTS: 1})
+
+	_, err = ParseWrite(nil)
+	c.Assert(err, ErrorMatches, "empty write")
+	_, err = ParseWrite([]byte{'P', 1, 'v', 3, 'a'})
+	c.Assert(err, ErrorMatches, "invalid short value")
+}
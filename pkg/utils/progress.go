@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"sync/atomic"
 	"time"
 
 	"github.com/cheggaaa/pb/v3"
@@ -19,6 +20,11 @@ type ProgressPrinter struct {
 	total       int64
 	redirectLog bool
 
+	// totalBytes and bytes are accessed atomically. The bar shows the
+	// progress by bytes once the total bytes is set.
+	totalBytes uint64
+	bytes      uint64
+
 	updateCh chan struct{}
 }
 
@@ -41,6 +47,29 @@ func (pp *ProgressPrinter) UpdateCh() chan<- struct{} {
 	return pp.updateCh
 }
 
+// SetTotalBytes sets the total bytes to process, so the progress is shown by
+// the bytes processed instead of the units.
+func (pp *ProgressPrinter) SetTotalBytes(total uint64) {
+	atomic.StoreUint64(&pp.totalBytes, total)
+}
+
+// AddBytes records the bytes processed. This method is goroutine-safe.
+func (pp *ProgressPrinter) AddBytes(bytes uint64) {
+	atomic.AddUint64(&pp.bytes, bytes)
+}
+
+// current returns the total and the current progress of the bar.
+func (pp *ProgressPrinter) current(counter int64) (int64, int64) {
+	total := pp.total
+	if totalBytes := atomic.LoadUint64(&pp.totalBytes); totalBytes != 0 {
+		total, counter = int64(totalBytes), int64(atomic.LoadUint64(&pp.bytes))
+	}
+	if counter > total {
+		counter = total
+	}
+	return total, counter
+}
+
 // goPrintProgress starts a gorouinte and prints progress.
 func (pp *ProgressPrinter) goPrintProgress(
 	ctx context.Context,
@@ -80,18 +109,18 @@ func (pp *ProgressPrinter) goPrintProgress(
 				return
 			case _, ok := <-pp.updateCh:
 				if !ok {
-					bar.SetCurrent(pp.total)
+					total, _ := pp.current(counter)
+					bar.SetTotal(total)
+					bar.SetCurrent(total)
 					return
 				}
 				counter++
 			case <-t.C:
 			}
 
-			if counter <= pp.total {
-				bar.SetCurrent(counter)
-			} else {
-				bar.SetCurrent(pp.total)
-			}
+			total, current := pp.current(counter)
+			bar.SetTotal(total)
+			bar.SetCurrent(current)
 		}
 	}()
 }
@@ -128,7 +157,17 @@ func StartProgress(
 	total int64,
 	redirectLog bool,
 ) chan<- struct{} {
+	return StartProgressPrinter(ctx, name, total, redirectLog).UpdateCh()
+}
+
+// StartProgressPrinter starts progress bar and returns the printer.
+func StartProgressPrinter(
+	ctx context.Context,
+	name string,
+	total int64,
+	redirectLog bool,
+) *ProgressPrinter {
 	progress := NewProgressPrinter(name, total, redirectLog)
 	progress.goPrintProgress(ctx, nil)
-	return progress.UpdateCh()
+	return progress
 }
@@ -0,0 +1,258 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// The types of progress events.
+const (
+	ProgressEventStart  = "start"
+	ProgressEventUpdate = "update"
+	ProgressEventFinish = "finish"
+)
+
+var progressEventInterval = time.Second
+
+// ProgressEvent is an event of the progress of a phase, e.g. "Full backup" or
+// "Checksum". The events are written as JSON lines.
+type ProgressEvent struct {
+	Time  time.Time `json:"time"`
+	Phase string    `json:"phase"`
+	Type  string    `json:"type"`
+
+	Total      int64  `json:"total"`
+	Current    int64  `json:"current"`
+	TotalBytes uint64 `json:"total-bytes,omitempty"`
+	Bytes      uint64 `json:"bytes"`
+	KVs        uint64 `json:"kvs"`
+
+	ElapsedSeconds float64 `json:"elapsed-seconds"`
+	// ETASeconds is estimated by bytes if the total bytes is known, or by
+	// units otherwise.
+	ETASeconds float64 `json:"eta-seconds"`
+}
+
+// progressEventBufferSize is the number of events buffered for each writer,
+// events are dropped when the buffer is full, so a slow writer never blocks
+// the task.
+const progressEventBufferSize = 64
+
+type progressEventHub struct {
+	mu      sync.Mutex
+	nextID  int
+	writers map[int]chan []byte
+}
+
+var progressEvents = &progressEventHub{writers: make(map[int]chan []byte)}
+
+// AddProgressEventWriter registers a writer receiving the progress events as
+// JSON lines, and returns a function to unregister the writer. The events are
+// written in a background goroutine, which exits before remove returns.
+func AddProgressEventWriter(w io.Writer) (remove func()) {
+	ch := make(chan []byte, progressEventBufferSize)
+	progressEvents.mu.Lock()
+	id := progressEvents.nextID
+	progressEvents.nextID++
+	progressEvents.writers[id] = ch
+	progressEvents.mu.Unlock()
+
+	unregister := func() {
+		progressEvents.mu.Lock()
+		defer progressEvents.mu.Unlock()
+		if _, ok := progressEvents.writers[id]; ok {
+			delete(progressEvents.writers, id)
+			close(ch)
+		}
+	}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		failed := false
+		for data := range ch {
+			if failed {
+				continue
+			}
+			if _, err := w.Write(data); err != nil {
+				log.Warn("failed to write progress event, stop writing", zap.Error(err))
+				failed = true
+				unregister()
+			}
+		}
+	}()
+	return func() {
+		unregister()
+		<-exited
+	}
+}
+
+func emitProgressEvent(event ProgressEvent) {
+	progressEvents.mu.Lock()
+	defer progressEvents.mu.Unlock()
+	if len(progressEvents.writers) == 0 {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Warn("failed to marshal progress event", zap.Error(err))
+		return
+	}
+	data = append(data, '\n')
+	for _, ch := range progressEvents.writers {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+// ProgressEventHandler returns an HTTP handler which streams the progress
+// events as JSON lines until the client disconnects.
+func ProgressEventHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		// Flush the header so the client doesn't wait for the first event.
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		remove := AddProgressEventWriter(flushWriter{w: w})
+		defer remove()
+		<-r.Context().Done()
+	})
+}
+
+// ProgressTracker tracks the progress of a phase by units, KVs and bytes, and
+// emits progress events periodically.
+type ProgressTracker struct {
+	phase string
+	total int64
+	start time.Time
+
+	current    int64
+	kvs        uint64
+	bytes      uint64
+	totalBytes uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	// finish is the last event, set before done is closed.
+	finish ProgressEvent
+}
+
+// StartProgressTracker starts tracking the progress of a phase.
+func StartProgressTracker(ctx context.Context, phase string, total int64) *ProgressTracker {
+	t := &ProgressTracker{
+		phase: phase,
+		total: total,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	progressStatus.add(t)
+	emitProgressEvent(t.Event(ProgressEventStart))
+	go func() {
+		ticker := time.NewTicker(progressEventInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.done:
+				return
+			case <-ticker.C:
+				emitProgressEvent(t.Event(ProgressEventUpdate))
+			}
+		}
+	}()
+	return t
+}
+
+// Inc increases the progress by a unit.
+func (t *ProgressTracker) Inc() {
+	atomic.AddInt64(&t.current, 1)
+}
+
+// AddWeight records the processed KVs and bytes.
+func (t *ProgressTracker) AddWeight(kvs, bytes uint64) {
+	atomic.AddUint64(&t.kvs, kvs)
+	atomic.AddUint64(&t.bytes, bytes)
+}
+
+// SetTotalBytes sets the total bytes of the phase.
+func (t *ProgressTracker) SetTotalBytes(total uint64) {
+	atomic.StoreUint64(&t.totalBytes, total)
+}
+
+// Close finishes the phase.
+func (t *ProgressTracker) Close() {
+	t.closeOnce.Do(func() {
+		t.finish = t.Event(ProgressEventFinish)
+		close(t.done)
+		emitProgressEvent(t.finish)
+	})
+}
+
+// Finished returns whether the phase is finished.
+func (t *ProgressTracker) Finished() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Event returns the current progress as an event.
+func (t *ProgressTracker) Event(eventType string) ProgressEvent {
+	now := time.Now()
+	event := ProgressEvent{
+		Time:           now,
+		Phase:          t.phase,
+		Type:           eventType,
+		Total:          t.total,
+		Current:        atomic.LoadInt64(&t.current),
+		TotalBytes:     atomic.LoadUint64(&t.totalBytes),
+		Bytes:          atomic.LoadUint64(&t.bytes),
+		KVs:            atomic.LoadUint64(&t.kvs),
+		ElapsedSeconds: now.Sub(t.start).Seconds(),
+	}
+	if eventType == ProgressEventFinish {
+		event.Current = event.Total
+		return event
+	}
+	if event.Current > event.Total {
+		event.Current = event.Total
+	}
+	var done, total float64
+	if event.TotalBytes != 0 && event.Bytes != 0 {
+		done, total = float64(event.Bytes), float64(event.TotalBytes)
+	} else {
+		done, total = float64(event.Current), float64(event.Total)
+	}
+	if done > 0 && total > done {
+		event.ETASeconds = event.ElapsedSeconds * (total - done) / done
+	}
+	return event
+}
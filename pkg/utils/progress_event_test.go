@@ -0,0 +1,133 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/pingcap/check"
+)
+
+type testProgressEventSuite struct{}
+
+var _ = Suite(&testProgressEventSuite{})
+
+func (s *testProgressEventSuite) SetUpTest(c *C) {
+	progressStatus.reset()
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) events(c *C) []ProgressEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var events []ProgressEvent
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var event ProgressEvent
+		c.Assert(json.Unmarshal(scanner.Bytes(), &event), IsNil)
+		events = append(events, event)
+	}
+	return events
+}
+
+func (s *testProgressEventSuite) TestProgressEvents(c *C) {
+	buf := &syncBuffer{}
+	remove := AddProgressEventWriter(buf)
+	defer remove()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := StartProgressTracker(ctx, "test", 4)
+	tracker.SetTotalBytes(1000)
+	tracker.AddWeight(10, 100)
+	tracker.Inc()
+
+	event := tracker.Event(ProgressEventUpdate)
+	c.Assert(event.Phase, Equals, "test")
+	c.Assert(event.Current, Equals, int64(1))
+	c.Assert(event.KVs, Equals, uint64(10))
+	c.Assert(event.Bytes, Equals, uint64(100))
+	// The ETA is estimated by bytes: 10% is done.
+	c.Assert(math.Abs(event.ETASeconds-event.ElapsedSeconds*9) < 1e-9, IsTrue)
+
+	tracker.Close()
+	tracker.Close()
+	// Wait for the events to be written.
+	remove()
+	events := buf.events(c)
+	c.Assert(len(events) >= 2, IsTrue)
+	c.Assert(events[0].Type, Equals, ProgressEventStart)
+	last := events[len(events)-1]
+	c.Assert(last.Type, Equals, ProgressEventFinish)
+	c.Assert(last.Current, Equals, int64(4))
+	c.Assert(last.Bytes, Equals, uint64(100))
+
+	// No more events after the writer is removed.
+	StartProgressTracker(ctx, "test2", 1).Close()
+	c.Assert(buf.events(c), HasLen, len(events))
+}
+
+func (s *testProgressEventSuite) TestETAByUnits(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := StartProgressTracker(ctx, "test", 4)
+	defer tracker.Close()
+	tracker.Inc()
+	event := tracker.Event(ProgressEventUpdate)
+	c.Assert(event.ETASeconds, Equals, event.ElapsedSeconds*3)
+}
+
+func (s *testProgressEventSuite) TestProgressEventHandler(c *C) {
+	server := httptest.NewServer(ProgressEventHandler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	c.Assert(err, IsNil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+
+	// Emit until the handler registers its writer.
+	lines := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				StartProgressTracker(ctx, "http", 1).Close()
+			}
+		}
+	}()
+	var event ProgressEvent
+	c.Assert(json.Unmarshal([]byte(<-lines), &event), IsNil)
+	close(done)
+	c.Assert(event.Phase, Equals, "http")
+}
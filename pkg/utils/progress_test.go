@@ -72,3 +72,24 @@ func (r *testProgressSuite) TestProgress(c *C) {
 	p = <-pCh8
 	c.Assert(p, Matches, `.*"P":"25\.00%".*`)
 }
+
+func (r *testProgressSuite) TestProgressWithBytes(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pCh := make(chan string, 2)
+	progress := NewProgressPrinter("test", 2, false)
+	progress.SetTotalBytes(400)
+	progress.goPrintProgress(ctx, &testWriter{
+		fn: func(p string) { pCh <- p },
+	})
+	updateCh := progress.UpdateCh()
+	// The progress is shown by bytes, though half of the units are done.
+	progress.AddBytes(100)
+	updateCh <- struct{}{}
+	p := <-pCh
+	c.Assert(p, Matches, `.*"P":"25\.00%".*`)
+	close(updateCh)
+	p = <-pCh
+	c.Assert(p, Matches, `.*"P":"100\.00%".*`)
+}
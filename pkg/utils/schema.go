@@ -10,6 +10,11 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/config"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/meta"
 	"github.com/pingcap/tidb/tablecodec"
 )
 
@@ -20,6 +25,12 @@ const (
 	MetaJSONFile = "backupmeta.json"
 	// SavedMetaFile represents saved meta file name for recovering later
 	SavedMetaFile = "backupmeta.bak"
+	// RawChecksumFile represents the file name of the checksums of raw kv ranges
+	RawChecksumFile = "backupmeta.rawchecksum"
+	// BackupReportFile represents the file name of the JSON report of backup
+	BackupReportFile = "backup.report.json"
+	// RestoreReportFile represents the file name of the JSON report of restore
+	RestoreReportFile = "restore.report.json"
 )
 
 // Table wraps the schema and files of a table.
@@ -127,3 +138,64 @@ func ArchiveSize(meta *backup.BackupMeta) uint64 {
 func EncloseName(name string) string {
 	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
 }
+
+// GetSnapshotMeta returns the meta of the schemas at the ts.
+func GetSnapshotMeta(store kv.Storage, ts uint64) (*meta.Meta, error) {
+	snapshot, err := store.GetSnapshot(kv.NewVersion(ts))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return meta.NewSnapshotMeta(snapshot), nil
+}
+
+// GetSnapshotInfoSchema returns the info schema at the ts. If the domain is
+// nil, i.e. the DDLs are not executed by a TiDB embedded in BR, the info schema
+// is loaded from the meta in TiKV.
+func GetSnapshotInfoSchema(dom *domain.Domain, store kv.Storage, ts uint64) (infoschema.InfoSchema, error) {
+	if dom != nil {
+		info, err := dom.GetSnapshotInfoSchema(ts)
+		return info, errors.Trace(err)
+	}
+	m, err := GetSnapshotMeta(store, ts)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	schemaVersion, err := m.GetSchemaVersion()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	allSchemas, err := m.ListDatabases()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Only the public schemas and tables are loaded, like the domain does.
+	schemas := make([]*model.DBInfo, 0, len(allSchemas))
+	for _, dbInfo := range allSchemas {
+		if dbInfo.State != model.StatePublic {
+			continue
+		}
+		tables, err := m.ListTables(dbInfo.ID)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		dbInfo.Tables = make([]*model.TableInfo, 0, len(tables))
+		for _, tableInfo := range tables {
+			if tableInfo.State != model.StatePublic {
+				continue
+			}
+			if config.GetGlobalConfig().TreatOldVersionUTF8AsUTF8MB4 {
+				infoschema.ConvertOldVersionUTF8ToUTF8MB4IfNeed(tableInfo)
+			}
+			infoschema.ConvertCharsetCollateToLowerCaseIfNeed(tableInfo)
+			dbInfo.Tables = append(dbInfo.Tables, tableInfo)
+		}
+		schemas = append(schemas, dbInfo)
+	}
+	handle := infoschema.NewHandle(store)
+	builder, err := infoschema.NewBuilder(handle).InitWithDBInfos(schemas, schemaVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	builder.Build()
+	return handle.Get(), nil
+}
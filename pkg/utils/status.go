@@ -0,0 +1,100 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// maxFinishedPhases is the number of finished phases kept for the status.
+const maxFinishedPhases = 16
+
+var processStartTime = time.Now()
+
+// Status is the status of BR returned by the status endpoint.
+type Status struct {
+	StartTime time.Time `json:"start-time"`
+	// Phase is the latest started phase which is not finished yet, it is empty
+	// if no phase is running.
+	Phase string `json:"phase"`
+	// Phases are the running phases and the recently finished phases, in the
+	// order of starting.
+	Phases []ProgressEvent `json:"phases"`
+}
+
+type progressStatusRegistry struct {
+	mu       sync.Mutex
+	trackers []*ProgressTracker
+}
+
+var progressStatus = &progressStatusRegistry{}
+
+func (r *progressStatusRegistry) add(t *ProgressTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trackers = append(r.trackers, t)
+
+	// Drop the oldest finished phases.
+	finished := 0
+	for _, tracker := range r.trackers {
+		if tracker.Finished() {
+			finished++
+		}
+	}
+	trackers := r.trackers[:0]
+	for _, tracker := range r.trackers {
+		if finished > maxFinishedPhases && tracker.Finished() {
+			finished--
+			continue
+		}
+		trackers = append(trackers, tracker)
+	}
+	r.trackers = trackers
+}
+
+// reset forgets all the trackers, it is used by tests.
+func (r *progressStatusRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trackers = nil
+}
+
+func (r *progressStatusRegistry) status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := Status{
+		StartTime: processStartTime,
+		Phases:    make([]ProgressEvent, 0, len(r.trackers)),
+	}
+	for _, t := range r.trackers {
+		if t.Finished() {
+			status.Phases = append(status.Phases, t.finish)
+			continue
+		}
+		status.Phase = t.phase
+		status.Phases = append(status.Phases, t.Event(ProgressEventUpdate))
+	}
+	return status
+}
+
+// CurrentStatus returns the current status of BR.
+func CurrentStatus() Status {
+	return progressStatus.status()
+}
+
+// StatusHandler returns an HTTP handler which returns the current status as
+// JSON.
+func StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(CurrentStatus()); err != nil {
+			log.Warn("failed to write status", zap.Error(err))
+		}
+	})
+}
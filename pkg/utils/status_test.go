@@ -0,0 +1,72 @@
+// Copyright 2020 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/pingcap/check"
+)
+
+type testStatusSuite struct{}
+
+var _ = Suite(&testStatusSuite{})
+
+func (s *testStatusSuite) SetUpTest(c *C) {
+	progressStatus.reset()
+}
+
+func (s *testStatusSuite) TestStatus(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := StartProgressTracker(ctx, "status-1", 2)
+	first.Inc()
+	first.Close()
+	second := StartProgressTracker(ctx, "status-2", 3)
+	defer second.Close()
+	second.Inc()
+	second.AddWeight(10, 100)
+
+	status := CurrentStatus()
+	c.Assert(status.Phase, Equals, "status-2")
+	c.Assert(status.Phases, HasLen, 2)
+	last := status.Phases
+	c.Assert(last[0].Phase, Equals, "status-1")
+	c.Assert(last[0].Type, Equals, ProgressEventFinish)
+	c.Assert(last[0].Current, Equals, int64(2))
+	c.Assert(last[1].Phase, Equals, "status-2")
+	c.Assert(last[1].Type, Equals, ProgressEventUpdate)
+	c.Assert(last[1].Current, Equals, int64(1))
+	c.Assert(last[1].Bytes, Equals, uint64(100))
+
+	server := httptest.NewServer(StatusHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	var got Status
+	c.Assert(json.NewDecoder(resp.Body).Decode(&got), IsNil)
+	c.Assert(got.Phase, Equals, "status-2")
+}
+
+func (s *testStatusSuite) TestStatusDropsFinishedPhases(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < maxFinishedPhases*2; i++ {
+		StartProgressTracker(ctx, "finished", 1).Close()
+	}
+	StartProgressTracker(ctx, "finished", 1).Close()
+	running := StartProgressTracker(ctx, "running", 1)
+	defer running.Close()
+
+	status := CurrentStatus()
+	c.Assert(status.Phase, Equals, "running")
+	c.Assert(status.Phases, HasLen, maxFinishedPhases+1)
+	for _, phase := range status.Phases[:maxFinishedPhases] {
+		c.Assert(phase.Type, Equals, ProgressEventFinish)
+	}
+}
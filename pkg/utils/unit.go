@@ -2,6 +2,8 @@
 
 package utils
 
+import "fmt"
+
 const (
 	// B is number of bytes in one byte.
 	B = uint64(1) << (iota * 10)
@@ -14,3 +16,20 @@ const (
 	// TB is number of bytes in one tebibyte.
 	TB
 )
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+// FormatBytes formats the number of bytes in the largest unit which keeps the
+// value not less than 1, e.g. "1.50 GiB".
+func FormatBytes(n uint64) string {
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.2f %s", value, byteUnits[unit])
+}